@@ -7,6 +7,7 @@ import (
 	"syscall"
 
 	cconst "github.com/cherry-game/cherry/const"
+	cerr "github.com/cherry-game/cherry/error"
 	ctime "github.com/cherry-game/cherry/extend/time"
 	cutils "github.com/cherry-game/cherry/extend/utils"
 	cfacade "github.com/cherry-game/cherry/facade"
@@ -31,6 +32,7 @@ type (
 		startTime    ctime.CherryTime     // application start time
 		running      int32                // is running
 		dieChan      chan bool            // wait for end application
+		stopChan     chan struct{}        // closed when application stopped
 		onShutdownFn []func()             // on shutdown execute functions
 		components   []cfacade.IComponent // all components
 		serializer   cfacade.ISerializer  // serializer
@@ -66,6 +68,7 @@ func NewAppNode(node cfacade.INode, isFrontend bool, mode NodeMode) *Application
 		startTime:   ctime.Now(),
 		running:     0,
 		dieChan:     make(chan bool),
+		stopChan:    make(chan struct{}),
 		actorSystem: cactor.New(),
 	}
 
@@ -122,6 +125,38 @@ func (a *Application) Find(name string) cfacade.IComponent {
 	return nil
 }
 
+// FindByType 根据组件类型获取第一个匹配的组件对象,如 FindByType[*cherryDataConfig.Component](app)
+func FindByType[T cfacade.IComponent](app cfacade.IApplication) (T, bool) {
+	for _, component := range app.All() {
+		if c, ok := component.(T); ok {
+			return c, true
+		}
+	}
+
+	var zero T
+	return zero, false
+}
+
+// Health 获取实现了IHealthCheck接口的组件健康状态(key:组件名称,value:nil表示健康)
+func (a *Application) Health() map[string]error {
+	result := make(map[string]error)
+
+	for _, component := range a.components {
+		check, ok := component.(cfacade.IHealthCheck)
+		if !ok {
+			continue
+		}
+
+		cutils.Try(func() {
+			result[component.Name()] = check.HealthCheck()
+		}, func(errString string) {
+			result[component.Name()] = cerr.Error(errString)
+		})
+	}
+
+	return result
+}
+
 // Remove component by name
 func (a *Application) Remove(name string) cfacade.IComponent {
 	if name == "" {
@@ -147,17 +182,49 @@ func (a *Application) OnShutdown(fn ...func()) {
 	a.onShutdownFn = append(a.onShutdownFn, fn...)
 }
 
-// Startup load components before startup
+// Startup load components before startup, 阻塞直到收到关闭信号或调用Shutdown()
 func (a *Application) Startup() {
+	a.Run()
+}
+
+// Run 启动应用并阻塞,收到SIGINT/SIGTERM/SIGQUIT或调用Shutdown()后按注册的逆序停止组件
+func (a *Application) Run() {
+	if !a.start() {
+		return
+	}
+
+	a.waitShutdown()
+	a.stop()
+}
+
+// Start 启动应用后立即返回,在后台等待关闭信号或Shutdown(),停止完成后StopChan()被关闭
+func (a *Application) Start() {
+	if !a.start() {
+		return
+	}
+
+	go func() {
+		a.waitShutdown()
+		a.stop()
+	}()
+}
+
+// StopChan 应用停止完成后关闭
+func (a *Application) StopChan() <-chan struct{} {
+	return a.stopChan
+}
+
+func (a *Application) start() (started bool) {
 	defer func() {
 		if r := recover(); r != nil {
 			clog.Error(r)
+			started = false
 		}
 	}()
 
 	if a.Running() {
 		clog.Error("Application has running.")
-		return
+		return false
 	}
 
 	defer func() {
@@ -174,6 +241,13 @@ func (a *Application) Startup() {
 		}
 	}
 
+	// sort components by declared depends
+	components, err := sortComponents(a.components)
+	if err != nil {
+		clog.Panic(err)
+	}
+	a.components = components
+
 	clog.Info("-------------------------------------------------")
 	clog.Infof("[nodeId      = %s] application is starting...", a.NodeId())
 	clog.Infof("[nodeType    = %s]", a.NodeType())
@@ -226,8 +300,13 @@ func (a *Application) Startup() {
 	// set application is running
 	atomic.AddInt32(&a.running, 1)
 
+	return true
+}
+
+func (a *Application) waitShutdown() {
 	sg := make(chan os.Signal, 1)
 	signal.Notify(sg, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM)
+	defer signal.Stop(sg)
 
 	select {
 	case <-a.dieChan:
@@ -235,6 +314,13 @@ func (a *Application) Startup() {
 	case s := <-sg:
 		clog.Infof("receive shutdown signal = %v.", s)
 	}
+}
+
+func (a *Application) stop() {
+	defer func() {
+		clog.Flush()
+		close(a.stopChan)
+	}()
 
 	// stop status
 	atomic.StoreInt32(&a.running, 0)
@@ -273,6 +359,65 @@ func (a *Application) Startup() {
 	clog.Info("------- application has been shutdown... -------")
 }
 
+// sortComponents 按组件声明的依赖进行拓扑排序,无依赖关系的组件保持注册顺序
+func sortComponents(components []cfacade.IComponent) ([]cfacade.IComponent, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	var (
+		nameMap = make(map[string]cfacade.IComponent, len(components))
+		state   = make(map[string]int, len(components))
+		sorted  = make([]cfacade.IComponent, 0, len(components))
+		visit   func(c cfacade.IComponent, path []string) error
+	)
+
+	for _, c := range components {
+		nameMap[c.Name()] = c
+	}
+
+	visit = func(c cfacade.IComponent, path []string) error {
+		name := c.Name()
+		path = append(path, name)
+
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return cerr.Errorf("[component = %s] depends cycle. %v", name, path)
+		}
+
+		state[name] = visiting
+
+		if d, ok := c.(cfacade.IComponentDepends); ok {
+			for _, dependName := range d.Depends() {
+				depend, found := nameMap[dependName]
+				if !found {
+					return cerr.Errorf("[component = %s] depend component not found. [depend = %s]", name, dependName)
+				}
+
+				if err := visit(depend, path); err != nil {
+					return err
+				}
+			}
+		}
+
+		state[name] = visited
+		sorted = append(sorted, c)
+		return nil
+	}
+
+	for _, c := range components {
+		if err := visit(c, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
+
 func (a *Application) Shutdown() {
 	a.dieChan <- true
 }
@@ -32,6 +32,23 @@ func ConfigureNode(node cfacade.INode, isFrontend bool, mode NodeMode) *AppBuild
 }
 
 func (p *AppBuilder) Startup() {
+	p.register()
+	p.Application.Startup()
+}
+
+// Run 注册组件后启动应用并阻塞,直到收到关闭信号
+func (p *AppBuilder) Run() {
+	p.register()
+	p.Application.Run()
+}
+
+// Start 注册组件后启动应用并立即返回
+func (p *AppBuilder) Start() {
+	p.register()
+	p.Application.Start()
+}
+
+func (p *AppBuilder) register() {
 	app := p.Application
 
 	if app.NodeMode() == Cluster {
@@ -46,9 +63,6 @@ func (p *AppBuilder) Startup() {
 
 	// Register custom components
 	app.Register(p.components...)
-
-	// startup
-	app.Startup()
 }
 
 func (p *AppBuilder) Register(component ...cfacade.IComponent) {
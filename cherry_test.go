@@ -1 +1,197 @@
 package cherry
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	cerr "github.com/cherry-game/cherry/error"
+	ctime "github.com/cherry-game/cherry/extend/time"
+	cfacade "github.com/cherry-game/cherry/facade"
+	cactor "github.com/cherry-game/cherry/net/actor"
+	cserializer "github.com/cherry-game/cherry/net/serializer"
+)
+
+type testComponent struct {
+	cfacade.Component
+	name string
+}
+
+func (p *testComponent) Name() string {
+	return p.name
+}
+
+type otherComponent struct {
+	cfacade.Component
+}
+
+func (*otherComponent) Name() string {
+	return "other"
+}
+
+func TestFindByType(t *testing.T) {
+	app := &Application{}
+	app.Register(&otherComponent{}, &testComponent{name: "first"}, &testComponent{name: "second"})
+
+	c, found := FindByType[*testComponent](app)
+	if !found || c.name != "first" {
+		t.Fatalf("found = %v, component = %+v", found, c)
+	}
+
+	if _, found = FindByType[*testActorComponent](app); found {
+		t.Fatal("unexpected component")
+	}
+}
+
+type testActorComponent struct {
+	cfacade.Component
+}
+
+func (*testActorComponent) Name() string {
+	return "actor"
+}
+
+type dependComponent struct {
+	cfacade.Component
+	name    string
+	depends []string
+}
+
+func (p *dependComponent) Name() string {
+	return p.name
+}
+
+func (p *dependComponent) Depends() []string {
+	return p.depends
+}
+
+func TestSortComponents(t *testing.T) {
+	handler := &dependComponent{name: "handler", depends: []string{"data_config"}}
+	gate := &dependComponent{name: "gate", depends: []string{"handler"}}
+	dataConfig := &dependComponent{name: "data_config"}
+
+	sorted, err := sortComponents([]cfacade.IComponent{gate, handler, dataConfig})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, c := range sorted {
+		names = append(names, c.Name())
+	}
+
+	if strings.Join(names, ",") != "data_config,handler,gate" {
+		t.Fatalf("sorted = %v", names)
+	}
+
+	dataConfig.depends = []string{"gate"}
+	if _, err = sortComponents([]cfacade.IComponent{gate, handler, dataConfig}); err == nil {
+		t.Fatal("expected cycle error")
+	}
+
+	dataConfig.depends = []string{"not_found"}
+	if _, err = sortComponents([]cfacade.IComponent{gate, handler, dataConfig}); err == nil {
+		t.Fatal("expected not found error")
+	}
+}
+
+type testNode struct {
+	cfacade.INode
+}
+
+func (*testNode) NodeId() string {
+	return "test-1"
+}
+
+func (*testNode) NodeType() string {
+	return "test"
+}
+
+type stopComponent struct {
+	cfacade.Component
+	name    string
+	stopped *[]string
+}
+
+func (p *stopComponent) Name() string {
+	return p.name
+}
+
+func (p *stopComponent) OnStop() {
+	*p.stopped = append(*p.stopped, p.name)
+}
+
+func TestApplicationStart(t *testing.T) {
+	app := &Application{
+		INode:       &testNode{},
+		serializer:  cserializer.NewJSON(),
+		startTime:   ctime.Now(),
+		dieChan:     make(chan bool),
+		stopChan:    make(chan struct{}),
+		actorSystem: cactor.New(),
+	}
+
+	var stopped []string
+	app.Register(
+		&stopComponent{name: "first", stopped: &stopped},
+		&stopComponent{name: "second", stopped: &stopped},
+	)
+
+	shutdown := false
+	app.OnShutdown(func() {
+		shutdown = true
+	})
+
+	app.Start()
+	if !app.Running() {
+		t.Fatal("application is not running")
+	}
+
+	app.Shutdown()
+
+	select {
+	case <-app.StopChan():
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait stop timeout")
+	}
+
+	if !shutdown || strings.Join(stopped, ",") != "second,first" {
+		t.Fatalf("shutdown = %v, stopped = %v", shutdown, stopped)
+	}
+}
+
+type healthComponent struct {
+	cfacade.Component
+	name string
+	err  error
+}
+
+func (p *healthComponent) Name() string {
+	return p.name
+}
+
+func (p *healthComponent) HealthCheck() error {
+	return p.err
+}
+
+func TestApplicationHealth(t *testing.T) {
+	app := &Application{}
+	app.Register(
+		&healthComponent{name: "healthy"},
+		&healthComponent{name: "unhealthy", err: cerr.Error("reload fail")},
+		&otherComponent{},
+	)
+
+	health := app.Health()
+	if len(health) != 2 {
+		t.Fatalf("health = %v", health)
+	}
+
+	if err, found := health["healthy"]; !found || err != nil {
+		t.Fatalf("healthy = %v", err)
+	}
+
+	if err := health["unhealthy"]; err == nil || err.Error() != "reload fail" {
+		t.Fatalf("unhealthy = %v", err)
+	}
+}
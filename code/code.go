@@ -19,6 +19,10 @@ const (
 	ActorSourceEqualTarget  int32 = 30 // source equal target
 	ActorPublishRemoteError int32 = 31 // actor publish remote error
 	ActorChildIDNotFound    int32 = 32 // actor child id not found
+	RouteNotFound           int32 = 33 // route not found
+	LocalExecuteError       int32 = 34 // local handler return error
+	ActorFilterRejected     int32 = 35 // rejected by the before filter
+	RouteRateLimited        int32 = 36 // rejected by the route rate limit
 
 )
 
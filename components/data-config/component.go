@@ -1,8 +1,11 @@
 package cherryDataConfig
 
 import (
+	"sort"
 	"sync"
+	"time"
 
+	cerr "github.com/cherry-game/cherry/error"
 	cutils "github.com/cherry-game/cherry/extend/utils"
 	cfacade "github.com/cherry-game/cherry/facade"
 	clog "github.com/cherry-game/cherry/logger"
@@ -10,15 +13,36 @@ import (
 )
 
 const (
-	Name = "data_config_component"
+	Name        = "data_config_component"
+	LoggerName  = "data_config" // 日志名称,可通过日志配置的levels单独设置该组件的日志等级
+	previewSize = 64            // 解析失败时日志中打印的数据长度
 )
 
+// Component 需要实现IDataConfig接口,接口与实现不一致时编译失败
+var _ IDataConfig = (*Component)(nil)
+
 type Component struct {
 	sync.RWMutex
 	cfacade.Component
 	dataSource IDataSource
 	parser     IDataParser
 	configs    []IConfig
+	reloadFns  []ReloadFn
+	loadErrs   map[string]error         // 各配置最后一次加载的错误,key:配置名称(Init异常时为组件名称)
+	loadStats  map[string]time.Duration // 最后一次加载(解析+OnLoad)的耗时
+	diffFns    []ReloadDiffFn
+	lastObject map[string]interface{} // 实现了IDiffableConfig的配置最后一次加载的解析数据
+}
+
+// ReloadFn 配置重载成功后触发该函数
+type ReloadFn func(configName string)
+
+// ReloadDiffFn 配置重载成功后触发该函数,changes为IDiffableConfig.Diff()的变更记录
+type ReloadDiffFn func(configName string, changes []ChangeRecord)
+
+// logger 返回data-config的子日志对象
+func logger() *clog.CherryLogger {
+	return clog.Named(LoggerName)
 }
 
 func New() *Component {
@@ -34,21 +58,21 @@ func (d *Component) Init() {
 	// read data_config node in profile-{env}.json
 	dataConfig := cprofile.GetConfig("data_config")
 	if dataConfig.LastError() != nil {
-		clog.Fatalf("`data_config` node in `%s` file not found.", cprofile.Name())
+		logger().Fatalf("`data_config` node in `%s` file not found.", cprofile.Name())
 	}
 
 	// get data source
 	sourceName := dataConfig.GetString("data_source")
 	d.dataSource = GetDataSource(sourceName)
 	if d.dataSource == nil {
-		clog.Fatalf("[sourceName = %s] data source not found.", sourceName)
+		logger().Fatalf("[sourceName = %s] data source not found.", sourceName)
 	}
 
 	// get parser
 	parserName := dataConfig.GetString("parser")
 	d.parser = GetParser(parserName)
 	if d.parser == nil {
-		clog.Fatalf("[parserName = %s] parser not found.", parserName)
+		logger().Fatalf("[parserName = %s] parser not found.", parserName)
 	}
 
 	cutils.Try(func() {
@@ -60,58 +84,382 @@ func (d *Component) Init() {
 		}
 
 		// read register IConfig
-		for _, cfg := range d.configs {
-			data, found := d.GetBytes(cfg.Name())
-			if !found {
-				clog.Warnf("[config = %s] load data fail.", cfg.Name())
-				continue
-			}
-
-			cutils.Try(func() {
-				d.onLoadConfig(cfg, data, false)
-			}, func(errString string) {
-				clog.Errorf("[config = %s] init config error. [error = %s]", cfg.Name(), errString)
-			})
-		}
+		d.loadConfigs(dataConfig.GetInt("load_workers", 1))
 
 		// on after load
 		for _, cfg := range d.configs {
 			cfg.OnAfterLoad(false)
 		}
 
+		// check references
+		d.checkReferences()
+
 		// on change process
 		d.dataSource.OnChange(func(configName string, data []byte) {
 			iConfig := d.GetIConfig(configName)
 			if iConfig != nil {
-				d.onLoadConfig(iConfig, data, true)
-				iConfig.OnAfterLoad(true)
+				d.reloadConfig(iConfig, data)
 			}
 		})
 
 	}, func(errString string) {
-		clog.Error(errString)
+		d.setLoadError(d.Name(), cerr.Error(errString))
+		logger().Error(errString)
+	})
+}
+
+// loadConfigs 启动时读取并加载所有已注册的配置
+// workers大于1时并行读取和解析数据,OnLoad仍在锁内依次执行,所有配置加载完成后才返回
+func (d *Component) loadConfigs(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		wg         sync.WaitGroup
+		configChan = make(chan IConfig)
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for cfg := range configChan {
+				d.loadConfig(cfg)
+			}
+		}()
+	}
+
+	for _, cfg := range d.configs {
+		configChan <- cfg
+	}
+
+	close(configChan)
+	wg.Wait()
+}
+
+func (d *Component) loadConfig(cfg IConfig) {
+	cutils.Try(func() {
+		data, found := d.GetBytes(cfg.Name())
+		if !found {
+			logger().Warnf("[config = %s] load data fail.", cfg.Name())
+			return
+		}
+
+		if _, err := d.onLoadConfig(cfg, data, false); err != nil {
+			d.setLoadError(cfg.Name(), err)
+			logger().Errorf("[config = %s] init config error. [error = %s]", cfg.Name(), err)
+		}
+	}, func(errString string) {
+		d.setLoadError(cfg.Name(), cerr.Error(errString))
+		logger().Errorf("[config = %s] init config error. [error = %s]", cfg.Name(), errString)
 	})
 }
 
-func (d *Component) onLoadConfig(cfg IConfig, data []byte, reload bool) {
+// onLoadConfig 解析并加载配置,解析数据时不加锁,可以并行解析多个配置
+// 重载时如果配置实现了ISwapConfig,加载到新实例并在校验成功后替换,否则实现了IRollbackConfig时在失败后回滚到之前的数据
+// 重载成功时如果配置实现了IDiffableConfig,返回新旧数据的变更记录
+func (d *Component) onLoadConfig(cfg IConfig, data []byte, reload bool) ([]ChangeRecord, error) {
+	begin := time.Now()
+
+	parseObject := newParseObject(cfg)
+	err := d.parser.Unmarshal(data, parseObject)
+	parseTime := time.Since(begin)
+	if err != nil {
+		logger().Warnf("[config = %s] unmarshal error = %v [len = %d, data = %q]",
+			cfg.Name(),
+			err,
+			len(data),
+			dataPreview(data),
+		)
+		return nil, err
+	}
+
 	d.Lock()
 	defer d.Unlock()
 
+	// 先加载到新实例,校验成功后再替换,失败时当前配置保持不变
+	target := cfg
+	swapConfig, canSwap := cfg.(ISwapConfig)
+	canSwap = canSwap && reload
+
+	if canSwap {
+		if target = swapConfig.NewConfig(); target == nil {
+			return nil, cerr.Errorf("[config = %s] NewConfig() returns nil.", cfg.Name())
+		}
+	}
+
+	rollbackConfig, canRollback := cfg.(IRollbackConfig)
+	canRollback = canRollback && reload && !canSwap
+
+	if canRollback {
+		rollbackConfig.Backup()
+	}
+
+	rollback := func() {
+		if canRollback {
+			rollbackConfig.Rollback()
+			logger().Warnf("[config = %s] rollback to the previous data.", cfg.Name())
+		}
+	}
+
+	// load data
+	var (
+		size    int
+		loadObj = loadObject(parseObject)
+	)
+	cutils.Try(func() {
+		size, err = target.OnLoad(loadObj, reload)
+	}, func(errString string) {
+		err = cerr.Error(errString)
+	})
+
+	if err != nil {
+		logger().Warnf("[config = %s] execute Load() error = %s", cfg.Name(), err)
+		rollback()
+		return nil, err
+	}
+
+	// validate data
+	if validateConfig, ok := target.(IValidateConfig); ok {
+		if err = validateConfig.Validate(); err != nil {
+			logger().Warnf("[config = %s] validate error = %s", cfg.Name(), err)
+			rollback()
+			return nil, err
+		}
+	}
+
+	if canSwap {
+		swapConfig.Swap(target)
+	}
+
+	changes := d.diffConfig(cfg, loadObj, reload)
+
+	loadTime := time.Since(begin)
+	if d.loadStats == nil {
+		d.loadStats = make(map[string]time.Duration)
+	}
+	d.loadStats[cfg.Name()] = loadTime
+
+	logger().Infof("[config = %s] loaded. [size = %d, parse = %v, total = %v, reload = %v]",
+		cfg.Name(),
+		size,
+		parseTime,
+		loadTime,
+		reload,
+	)
+	return changes, nil
+}
+
+// diffConfig 保存实现了IDiffableConfig的配置的解析数据,重载时与上一次的数据对比
+func (d *Component) diffConfig(cfg IConfig, loadObj interface{}, reload bool) []ChangeRecord {
+	diffConfig, ok := cfg.(IDiffableConfig)
+	if !ok {
+		return nil
+	}
+
+	if d.lastObject == nil {
+		d.lastObject = make(map[string]interface{})
+	}
+
+	oldObj, found := d.lastObject[cfg.Name()]
+	d.lastObject[cfg.Name()] = loadObj
+
+	if !reload || !found {
+		return nil
+	}
+
+	var changes []ChangeRecord
+	cutils.Try(func() {
+		changes = diffConfig.Diff(oldObj, loadObj)
+	}, func(errString string) {
+		logger().Warnf("[config = %s] execute Diff() error = %s", cfg.Name(), errString)
+	})
+
+	return changes
+}
+
+// dataPreview 返回数据的前previewSize个字节,用于定位解析失败的数据
+func dataPreview(data []byte) []byte {
+	if len(data) > previewSize {
+		return data[:previewSize]
+	}
+	return data
+}
+
+// newParseObject 配置实现了IParseObjectConfig时使用其提供的目标对象,否则解析到interface{}
+func newParseObject(cfg IConfig) interface{} {
+	if parseConfig, ok := cfg.(IParseObjectConfig); ok {
+		if obj := parseConfig.NewParseObject(); obj != nil {
+			return obj
+		}
+	}
+
 	var parseObject interface{}
-	err := d.parser.Unmarshal(data, &parseObject)
+	return &parseObject
+}
+
+// loadObject 传入OnLoad的对象,interface{}解析结果需解引用
+func loadObject(parseObject interface{}) interface{} {
+	if obj, ok := parseObject.(*interface{}); ok {
+		return *obj
+	}
+	return parseObject
+}
+
+// checkReferences 检查配置之间的引用,打印所有引用错误
+func (d *Component) checkReferences() {
+	errCount := 0
+
+	for _, cfg := range d.configs {
+		referenceConfig, ok := cfg.(IReferenceConfig)
+		if !ok {
+			continue
+		}
+
+		for _, err := range referenceConfig.CheckReferences(d) {
+			errCount++
+			logger().Errorf("[config = %s] reference error = %v", cfg.Name(), err)
+		}
+	}
+
+	if errCount > 0 {
+		logger().Errorf("check references fail. [errCount = %d]", errCount)
+	}
+}
+
+// Reload 从当前数据源重新读取并加载指定配置
+func (d *Component) Reload(configName string) error {
+	iConfig := d.GetIConfig(configName)
+	if iConfig == nil {
+		return cerr.Errorf("[config = %s] config not registered.", configName)
+	}
+
+	data, err := d.dataSource.ReadBytes(configName)
 	if err != nil {
-		clog.Warnf("[config = %s] unmarshal error = %v", err, cfg.Name())
-		return
+		return err
 	}
 
-	// load data
-	size, err := cfg.OnLoad(parseObject, reload)
+	return d.reloadConfig(iConfig, data)
+}
+
+func (d *Component) reloadConfig(cfg IConfig, data []byte) error {
+	changes, err := d.onLoadConfig(cfg, data, true)
 	if err != nil {
-		clog.Warnf("[config = %s] execute Load() error = %s", cfg.Name(), err)
+		d.setLoadError(cfg.Name(), cerr.Wrapf(err, "[config = %s] reload fail.", cfg.Name()))
+		return err
+	}
+
+	// 只清除该配置的错误,其他配置的错误保留到各自重载成功
+	d.setLoadError(cfg.Name(), nil)
+
+	cfg.OnAfterLoad(true)
+	d.onReload(cfg.Name(), changes)
+
+	return nil
+}
+
+// setLoadError 记录配置的加载错误,err为nil时清除该配置的错误
+func (d *Component) setLoadError(name string, err error) {
+	d.Lock()
+	defer d.Unlock()
+
+	if err == nil {
+		delete(d.loadErrs, name)
+		return
+	}
+
+	if d.loadErrs == nil {
+		d.loadErrs = make(map[string]error)
+	}
+	d.loadErrs[name] = err
+}
+
+// HealthCheck 返回配置加载或重载的错误,配置重载成功后清除该配置的错误,所有配置都无错误时恢复健康
+// 数据源实现了IHealthCheck时(如http数据源的熔断状态),同时检查数据源
+func (d *Component) HealthCheck() error {
+	d.RLock()
+	defer d.RUnlock()
+
+	if len(d.loadErrs) > 0 {
+		names := make([]string, 0, len(d.loadErrs))
+		for name := range d.loadErrs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		err := d.loadErrs[names[0]]
+		if len(names) > 1 {
+			return cerr.Wrapf(err, "[configs = %v] load fail.", names)
+		}
+		return err
+	}
+
+	if check, ok := d.dataSource.(cfacade.IHealthCheck); ok {
+		return check.HealthCheck()
+	}
+
+	return nil
+}
+
+// LoadStats 各配置最后一次加载或重载(解析+OnLoad+Validate)的耗时
+func (d *Component) LoadStats() map[string]time.Duration {
+	d.RLock()
+	defer d.RUnlock()
+
+	stats := make(map[string]time.Duration, len(d.loadStats))
+	for name, duration := range d.loadStats {
+		stats[name] = duration
+	}
+	return stats
+}
+
+// OnReload 注册配置重载成功后的回调,每个回调在独立的goroutine中执行
+func (d *Component) OnReload(fn ReloadFn) {
+	if fn == nil {
 		return
 	}
 
-	clog.Infof("[config = %s] loaded. [size = %d]", cfg.Name(), size)
+	d.Lock()
+	defer d.Unlock()
+
+	d.reloadFns = append(d.reloadFns, fn)
+}
+
+// OnReloadDiff 注册配置重载成功后的回调,配置实现了IDiffableConfig时传入变更记录,每个回调在独立的goroutine中执行
+func (d *Component) OnReloadDiff(fn ReloadDiffFn) {
+	if fn == nil {
+		return
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	d.diffFns = append(d.diffFns, fn)
+}
+
+func (d *Component) onReload(configName string, changes []ChangeRecord) {
+	d.RLock()
+	defer d.RUnlock()
+
+	for _, fn := range d.reloadFns {
+		reloadFn := fn
+		go cutils.Try(func() {
+			reloadFn(configName)
+		}, func(errString string) {
+			logger().Errorf("[config = %s] reload callback error. [error = %s]", configName, errString)
+		})
+	}
+
+	for _, fn := range d.diffFns {
+		diffFn := fn
+		go cutils.Try(func() {
+			diffFn(configName, changes)
+		}, func(errString string) {
+			logger().Errorf("[config = %s] reload diff callback error. [error = %s]", configName, errString)
+		})
+	}
 }
 
 func (d *Component) OnStop() {
@@ -122,10 +470,13 @@ func (d *Component) OnStop() {
 
 func (d *Component) Register(configs ...IConfig) {
 	if len(configs) < 1 {
-		clog.Warnf("IConfig size is less than 1.")
+		logger().Warnf("IConfig size is less than 1.")
 		return
 	}
 
+	d.Lock()
+	defer d.Unlock()
+
 	for _, cfg := range configs {
 		if cfg != nil {
 			d.configs = append(d.configs, cfg)
@@ -134,18 +485,40 @@ func (d *Component) Register(configs ...IConfig) {
 }
 
 func (d *Component) GetIConfig(name string) IConfig {
+	cfg, _ := d.GetConfig(name)
+	return cfg
+}
+
+// GetConfig 获取已注册的配置,配置数据为最后一次加载(重载)成功的结构,无需重新读取和解析数据源
+// 注意: 不能在IConfig.OnLoad()中调用,加载时持有写锁
+func (d *Component) GetConfig(name string) (IConfig, bool) {
+	d.RLock()
+	defer d.RUnlock()
+
 	for _, cfg := range d.configs {
 		if cfg.Name() == name {
-			return cfg
+			return cfg, true
 		}
 	}
-	return nil
+	return nil, false
+}
+
+// GetAll 获取所有已注册的配置(key:配置名称,value:配置)
+func (d *Component) GetAll() map[string]IConfig {
+	d.RLock()
+	defer d.RUnlock()
+
+	configMap := make(map[string]IConfig, len(d.configs))
+	for _, cfg := range d.configs {
+		configMap[cfg.Name()] = cfg
+	}
+	return configMap
 }
 
 func (d *Component) GetBytes(configName string) (data []byte, found bool) {
 	data, err := d.dataSource.ReadBytes(configName)
 	if err != nil {
-		clog.Warn(err)
+		logger().Warn(err)
 		return nil, false
 	}
 
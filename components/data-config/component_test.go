@@ -0,0 +1,450 @@
+package cherryDataConfig
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	cerr "github.com/cherry-game/cherry/error"
+	clog "github.com/cherry-game/cherry/logger"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type (
+	testSource struct {
+		sync.Mutex
+		data map[string][]byte
+	}
+
+	itemRow struct {
+		ID   string
+		Name string
+	}
+
+	itemConfig struct {
+		name   string
+		rows   map[string]*itemRow
+		backup map[string]*itemRow
+	}
+
+	// heroConfig 逐行写入rows,通过ISwapConfig保证重载失败时不会只更新一部分
+	heroConfig struct {
+		name string
+		rows map[string]*itemRow
+	}
+)
+
+func (s *testSource) Name() string {
+	return "test"
+}
+
+func (s *testSource) Init(_ IDataConfig) {
+}
+
+func (s *testSource) ReadBytes(configName string) ([]byte, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	data, found := s.data[configName]
+	if !found {
+		return nil, cerr.Errorf("[config = %s] not found.", configName)
+	}
+	return data, nil
+}
+
+func (s *testSource) OnChange(_ ConfigChangeFn) {
+}
+
+func (s *testSource) Stop() {
+}
+
+func (s *testSource) set(configName, data string) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.data[configName] = []byte(data)
+}
+
+func newItemConfig(name string) *itemConfig {
+	return &itemConfig{
+		name: name,
+		rows: make(map[string]*itemRow),
+	}
+}
+
+func (p *itemConfig) Name() string {
+	return p.name
+}
+
+func (p *itemConfig) Init() {
+}
+
+// OnLoad 逐行写入当前数据,重载失败时依赖Rollback恢复
+func (p *itemConfig) OnLoad(maps interface{}, _ bool) (int, error) {
+	var list []map[string]interface{}
+
+	switch rows := maps.(type) {
+	case []map[string]interface{}:
+		list = rows
+	case []interface{}:
+		for _, row := range rows {
+			fields, ok := row.(map[string]interface{})
+			if !ok {
+				return 0, cerr.Errorf("row type error. [type = %T]", row)
+			}
+			list = append(list, fields)
+		}
+	default:
+		return 0, cerr.Errorf("maps type error. [type = %T]", maps)
+	}
+
+	p.rows = make(map[string]*itemRow, len(list))
+	for _, fields := range list {
+		row := &itemRow{
+			ID:   fmt.Sprint(fields["id"]),
+			Name: fmt.Sprint(fields["name"]),
+		}
+
+		if row.Name == "panic" {
+			panic("load row error")
+		}
+
+		p.rows[row.ID] = row
+	}
+
+	return len(p.rows), nil
+}
+
+func (p *itemConfig) OnAfterLoad(_ bool) {
+}
+
+func (p *itemConfig) Validate() error {
+	for id, row := range p.rows {
+		if row.Name == "" {
+			return cerr.Errorf("[id = %s] name is empty.", id)
+		}
+	}
+	return nil
+}
+
+func (p *itemConfig) Backup() {
+	p.backup = p.rows
+}
+
+func (p *itemConfig) Rollback() {
+	p.rows = p.backup
+}
+
+func (p *itemConfig) GetRow(key interface{}) (interface{}, bool) {
+	row, found := p.rows[fmt.Sprint(key)]
+	return row, found
+}
+
+func (p *heroConfig) Name() string {
+	return p.name
+}
+
+func (p *heroConfig) Init() {
+}
+
+func (p *heroConfig) OnLoad(maps interface{}, _ bool) (int, error) {
+	if p.rows == nil {
+		p.rows = make(map[string]*itemRow)
+	}
+
+	for _, row := range maps.([]interface{}) {
+		fields := row.(map[string]interface{})
+		if fields["name"] == "panic" {
+			panic("load row error")
+		}
+
+		id := fmt.Sprint(fields["id"])
+		p.rows[id] = &itemRow{ID: id, Name: fmt.Sprint(fields["name"])}
+	}
+
+	return len(p.rows), nil
+}
+
+func (p *heroConfig) OnAfterLoad(_ bool) {
+}
+
+func (p *heroConfig) Validate() error {
+	for id, row := range p.rows {
+		if row.Name == "" {
+			return cerr.Errorf("[id = %s] name is empty.", id)
+		}
+	}
+	return nil
+}
+
+func (p *heroConfig) NewConfig() IConfig {
+	return &heroConfig{name: p.name}
+}
+
+func (p *heroConfig) Swap(loaded IConfig) {
+	p.rows = loaded.(*heroConfig).rows
+}
+
+// newTestComponent 使用内存数据源创建组件并加载所有配置,不读取profile
+func newTestComponent(parserName string, data map[string]string, configs ...IConfig) (*Component, *testSource) {
+	source := &testSource{data: make(map[string][]byte)}
+	for name, value := range data {
+		source.set(name, value)
+	}
+
+	d := New()
+	d.dataSource = source
+	d.parser = GetParser(parserName)
+	d.Register(configs...)
+	d.loadConfigs(1)
+
+	return d, source
+}
+
+func TestHealthCheckPerConfig(t *testing.T) {
+	item, hero := newItemConfig("item"), newItemConfig("hero")
+	d, source := newTestComponent("json", map[string]string{
+		"item": `[{"id":1,"name":"sword"}]`,
+		"hero": `[{"id":1,"name":"knight"}]`,
+	}, item, hero)
+
+	if err := d.HealthCheck(); err != nil {
+		t.Fatal(err)
+	}
+
+	source.set("item", `[{"id":1`)
+	source.set("hero", `[{"id":1`)
+
+	if d.Reload("item") == nil || d.Reload("hero") == nil {
+		t.Fatal("reload truncated data without error")
+	}
+
+	// the successful reload of item does not clear the error of hero
+	source.set("item", `[{"id":1,"name":"shield"}]`)
+	if err := d.Reload("item"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.HealthCheck(); err == nil {
+		t.Fatal("the error of hero is cleared")
+	}
+
+	source.set("hero", `[{"id":1,"name":"archer"}]`)
+	if err := d.Reload("hero"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.HealthCheck(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateOnLoad(t *testing.T) {
+	item := newItemConfig("item")
+	d, _ := newTestComponent("json", map[string]string{
+		"item": `[{"id":1,"name":""}]`,
+	}, item)
+
+	// the initial load fails the validation
+	if err := d.HealthCheck(); err == nil {
+		t.Fatal("validation error is not reported")
+	}
+}
+
+func TestValidateRollback(t *testing.T) {
+	item := newItemConfig("item")
+	d, source := newTestComponent("json", map[string]string{
+		"item": `[{"id":1,"name":"sword"}]`,
+	}, item)
+
+	// OnLoad replaces the rows, then the validation fails and rolls back
+	source.set("item", `[{"id":1,"name":"axe"},{"id":2,"name":""}]`)
+	if err := d.Reload("item"); err == nil {
+		t.Fatal("reload invalid data without error")
+	}
+
+	if len(item.rows) != 1 || item.rows["1"].Name != "sword" {
+		t.Fatalf("rows = %v", item.rows)
+	}
+
+	if err := d.HealthCheck(); err == nil {
+		t.Fatal("validation error is not reported")
+	}
+}
+
+func TestReloadTruncated(t *testing.T) {
+	item := newItemConfig("item")
+	d, source := newTestComponent("json", map[string]string{
+		"item": `[{"id":1,"name":"sword"},{"id":2,"name":"shield"}]`,
+	}, item)
+
+	// the truncated data fails to parse, OnLoad is not executed
+	source.set("item", `[{"id":1,"name":"axe"},{"id":2,"na`)
+	if err := d.Reload("item"); err == nil {
+		t.Fatal("reload truncated data without error")
+	}
+
+	row, found := GetRow[*itemRow](d, "item", 2)
+	if !found || row.Name != "shield" || item.rows["1"].Name != "sword" {
+		t.Fatalf("rows = %v", item.rows)
+	}
+
+	// OnLoad panics after replacing part of the rows, the rows are rolled back
+	source.set("item", `[{"id":1,"name":"axe"},{"id":2,"name":"panic"}]`)
+	if err := d.Reload("item"); err == nil {
+		t.Fatal("reload panic data without error")
+	}
+
+	if len(item.rows) != 2 || item.rows["1"].Name != "sword" {
+		t.Fatalf("rows = %v", item.rows)
+	}
+}
+
+func TestReloadSwap(t *testing.T) {
+	hero := &heroConfig{name: "hero"}
+	d, source := newTestComponent("json", map[string]string{
+		"hero": `[{"id":1,"name":"knight"},{"id":2,"name":"archer"}]`,
+	}, hero)
+
+	// OnLoad panics after writing the first row, the new instance is dropped
+	source.set("hero", `[{"id":1,"name":"mage"},{"id":2,"name":"panic"}]`)
+	if err := d.Reload("hero"); err == nil {
+		t.Fatal("reload panic data without error")
+	}
+
+	// the validation of the new instance fails
+	source.set("hero", `[{"id":1,"name":"mage"},{"id":2,"name":""}]`)
+	if err := d.Reload("hero"); err == nil {
+		t.Fatal("reload invalid data without error")
+	}
+
+	if len(hero.rows) != 2 || hero.rows["1"].Name != "knight" || hero.rows["2"].Name != "archer" {
+		t.Fatalf("rows = %v", hero.rows)
+	}
+
+	// the rows removed from the data are not kept by the in place load
+	source.set("hero", `[{"id":3,"name":"priest"}]`)
+	if err := d.Reload("hero"); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, _ := d.GetConfig("hero")
+	if cfg != hero || len(hero.rows) != 1 || hero.rows["3"].Name != "priest" {
+		t.Fatalf("rows = %v", hero.rows)
+	}
+}
+
+func TestGetRow(t *testing.T) {
+	d, _ := newTestComponent("json", map[string]string{
+		"item": `[{"id":1001,"name":"sword"}]`,
+	}, newItemConfig("item"))
+
+	row, found := GetRow[*itemRow](d, "item", 1001)
+	if !found || row.ID != "1001" || row.Name != "sword" {
+		t.Fatalf("row = %+v", row)
+	}
+
+	if _, found = GetRow[*itemRow](d, "item", 1002); found {
+		t.Fatal("row 1002 found")
+	}
+
+	// the row type does not match
+	if _, found = GetRow[itemRow](d, "item", 1001); found {
+		t.Fatal("row type error is not reported")
+	}
+
+	if _, found = GetRow[*itemRow](d, "hero", 1001); found {
+		t.Fatal("config hero found")
+	}
+}
+
+func TestGetConfigReloaded(t *testing.T) {
+	d, source := newTestComponent("json", map[string]string{
+		"item": `[{"id":1,"name":"sword"}]`,
+		"hero": `[{"id":1,"name":"knight"}]`,
+	}, newItemConfig("item"), newItemConfig("hero"))
+
+	source.set("item", `[{"id":1,"name":"axe"},{"id":2,"name":"bow"}]`)
+	if err := d.Reload("item"); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, found := d.GetConfig("item")
+	if !found {
+		t.Fatal("config item not found")
+	}
+
+	item := cfg.(*itemConfig)
+	if len(item.rows) != 2 || item.rows["1"].Name != "axe" {
+		t.Fatalf("rows = %v", item.rows)
+	}
+
+	if all := d.GetAll(); len(all) != 2 || all["item"] != cfg {
+		t.Fatalf("all = %v", all)
+	}
+
+	if _, found = d.GetConfig("equip"); found {
+		t.Fatal("config equip found")
+	}
+}
+
+func BenchmarkLoadConfigs(b *testing.B) {
+	const configCount = 32
+
+	var rows []string
+	for i := 0; i < 1000; i++ {
+		rows = append(rows, fmt.Sprintf(`{"id":%d,"name":"item-%d"}`, i, i))
+	}
+
+	source := &testSource{data: make(map[string][]byte)}
+	for i := 0; i < configCount; i++ {
+		source.set(fmt.Sprintf("item%d", i), "["+strings.Join(rows, ",")+"]")
+	}
+
+	for _, workers := range []int{1, 4} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				d := New()
+				d.dataSource = source
+				d.parser = GetParser("json")
+				for i := 0; i < configCount; i++ {
+					d.Register(newItemConfig(fmt.Sprintf("item%d", i)))
+				}
+
+				d.loadConfigs(workers)
+			}
+		})
+	}
+}
+
+func TestUnmarshalErrorLog(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+
+	defaultLogger := clog.DefaultLogger
+	clog.DefaultLogger = &clog.CherryLogger{SugaredLogger: clog.NewSugaredLogger(core)}
+	defer func() {
+		clog.DefaultLogger = defaultLogger
+	}()
+
+	d := New()
+	d.parser = GetParser("json")
+
+	data := `[{"id":1,"name":"` + strings.Repeat("a", previewSize) + `"`
+	if _, err := d.onLoadConfig(newItemConfig("item"), []byte(data), false); err == nil {
+		t.Fatal("unmarshal truncated data without error")
+	}
+
+	entries := logs.FilterMessageSnippet("unmarshal error").All()
+	if len(entries) != 1 {
+		t.Fatalf("entries = %v", logs.All())
+	}
+
+	msg := entries[0].Message
+	preview := fmt.Sprintf("%q", data[:previewSize])
+	if !strings.HasPrefix(msg, "[config = item] unmarshal error = ") ||
+		!strings.Contains(msg, fmt.Sprintf("[len = %d, data = %s]", len(data), preview)) {
+		t.Fatalf("msg = %s", msg)
+	}
+}
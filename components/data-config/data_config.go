@@ -7,8 +7,12 @@ var (
 
 func init() {
 	RegisterParser(new(ParserJson))
+	RegisterParser(new(ParserYaml))
+	RegisterParser(new(ParserCsv))
+	RegisterParser(new(ParserProtobuf))
 	RegisterSource(new(SourceFile))
 	RegisterSource(new(SourceRedis))
+	RegisterSource(new(SourceHttp))
 }
 
 func GetParser(name string) IDataParser {
@@ -0,0 +1,97 @@
+package cherryDataConfig
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// DiffRows 对比默认解析结果的行数据,可在IDiffableConfig.Diff()中使用
+// 行数据为[]interface{}(每行为map[string]interface{},keyField为行的key字段)
+// 或map[string]interface{}(key为行的key,value为行)
+func DiffRows(old, new interface{}, keyField string) []ChangeRecord {
+	oldRows := toRows(old, keyField)
+	newRows := toRows(new, keyField)
+
+	var changes []ChangeRecord
+
+	for key, oldRow := range oldRows {
+		newRow, found := newRows[key]
+		if !found {
+			changes = append(changes, ChangeRecord{Key: key, Old: oldRow})
+			continue
+		}
+
+		changes = append(changes, diffFields(key, oldRow, newRow)...)
+	}
+
+	for key, newRow := range newRows {
+		if _, found := oldRows[key]; !found {
+			changes = append(changes, ChangeRecord{Key: key, New: newRow})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		ki, kj := fmt.Sprint(changes[i].Key), fmt.Sprint(changes[j].Key)
+		if ki != kj {
+			return ki < kj
+		}
+		return changes[i].Field < changes[j].Field
+	})
+
+	return changes
+}
+
+func toRows(v interface{}, keyField string) map[interface{}]interface{} {
+	rows := make(map[interface{}]interface{})
+
+	switch list := v.(type) {
+	case []interface{}:
+		for _, row := range list {
+			fields, ok := row.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			if key, found := fields[keyField]; found && key != nil && reflect.TypeOf(key).Comparable() {
+				rows[key] = row
+			}
+		}
+	case map[string]interface{}:
+		for key, row := range list {
+			rows[key] = row
+		}
+	}
+
+	return rows
+}
+
+// diffFields 对比行的字段,行不是map[string]interface{}时整行对比
+func diffFields(key, oldRow, newRow interface{}) []ChangeRecord {
+	oldFields, oldOK := oldRow.(map[string]interface{})
+	newFields, newOK := newRow.(map[string]interface{})
+
+	if !oldOK || !newOK {
+		if reflect.DeepEqual(oldRow, newRow) {
+			return nil
+		}
+		return []ChangeRecord{{Key: key, Old: oldRow, New: newRow}}
+	}
+
+	var changes []ChangeRecord
+
+	for field, oldValue := range oldFields {
+		newValue := newFields[field]
+		if !reflect.DeepEqual(oldValue, newValue) {
+			changes = append(changes, ChangeRecord{Key: key, Field: field, Old: oldValue, New: newValue})
+		}
+	}
+
+	for field, newValue := range newFields {
+		if _, found := oldFields[field]; !found {
+			changes = append(changes, ChangeRecord{Key: key, Field: field, New: newValue})
+		}
+	}
+
+	return changes
+}
@@ -0,0 +1,79 @@
+package cherryDataConfig
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type diffItemConfig struct {
+	*itemConfig
+}
+
+func (p *diffItemConfig) Diff(old, new interface{}) []ChangeRecord {
+	return DiffRows(old, new, "id")
+}
+
+func TestDiffRows(t *testing.T) {
+	oldRows := []interface{}{
+		map[string]interface{}{"id": 1.0, "name": "sword", "atk": 10.0},
+		map[string]interface{}{"id": 2.0, "name": "shield"},
+	}
+	newRows := []interface{}{
+		map[string]interface{}{"id": 1.0, "name": "sword", "atk": 12.0, "def": 1.0},
+		map[string]interface{}{"id": 3.0, "name": "bow"},
+	}
+
+	want := []ChangeRecord{
+		{Key: 1.0, Field: "atk", Old: 10.0, New: 12.0},
+		{Key: 1.0, Field: "def", New: 1.0},
+		{Key: 2.0, Old: oldRows[1]},
+		{Key: 3.0, New: newRows[1]},
+	}
+
+	if changes := DiffRows(oldRows, newRows, "id"); !reflect.DeepEqual(changes, want) {
+		t.Fatalf("changes = %+v", changes)
+	}
+
+	// map rows, the key of the map is the key of the row
+	oldMap := map[string]interface{}{"a": "sword", "b": "shield"}
+	newMap := map[string]interface{}{"a": "axe", "c": "bow"}
+
+	want = []ChangeRecord{
+		{Key: "a", Old: "sword", New: "axe"},
+		{Key: "b", Old: "shield"},
+		{Key: "c", New: "bow"},
+	}
+
+	if changes := DiffRows(oldMap, newMap, ""); !reflect.DeepEqual(changes, want) {
+		t.Fatalf("changes = %+v", changes)
+	}
+}
+
+func TestOnReloadDiff(t *testing.T) {
+	d, source := newTestComponent("json", map[string]string{
+		"item": `[{"id":1,"name":"sword"},{"id":2,"name":"shield"}]`,
+	}, &diffItemConfig{newItemConfig("item")})
+
+	changesChan := make(chan []ChangeRecord, 1)
+	d.OnReloadDiff(func(configName string, changes []ChangeRecord) {
+		changesChan <- changes
+	})
+
+	source.set("item", `[{"id":1,"name":"axe"},{"id":3,"name":"bow"}]`)
+	if err := d.Reload("item"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case changes := <-changesChan:
+		if len(changes) != 3 ||
+			changes[0].Field != "name" || changes[0].Old != "sword" || changes[0].New != "axe" ||
+			changes[1].Key != 2.0 || changes[1].New != nil ||
+			changes[2].Key != 3.0 || changes[2].Old != nil {
+			t.Fatalf("changes = %+v", changes)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("reload diff callback is not executed")
+	}
+}
@@ -5,6 +5,9 @@ type (
 	IDataConfig interface {
 		Register(configFile ...IConfig)                       // 注册映射文件
 		GetBytes(configName string) (data []byte, found bool) // 获取原始的数据
+		GetIConfig(name string) IConfig                       // 获取已注册的配置
+		GetConfig(name string) (IConfig, bool)                // 获取已注册的配置
+		GetAll() map[string]IConfig                           // 获取所有已注册的配置
 		GetParser() IDataParser                               // 当前参数配置的数据格式解析器
 		GetDataSource() IDataSource                           // 当前参数配置的获取数据源
 	}
@@ -28,10 +31,64 @@ type (
 	ConfigChangeFn func(configName string, data []byte)
 
 	// IConfig 配置接口
+	//
+	// 重载时解析失败不会执行OnLoad,配置保持旧数据;OnLoad直接修改配置自身的数据,
+	// 执行失败(含panic)或校验失败时只有实现了ISwapConfig或IRollbackConfig的配置会保持旧数据,
+	// 都未实现时配置可能只更新了一部分。需要原子重载时,实现ISwapConfig(推荐)或IRollbackConfig,
+	// 或在OnLoad中先构建新数据,全部成功后再替换
 	IConfig interface {
 		Name() string                                      // 配置名称
 		Init()                                             // 结构体初始化
 		OnLoad(maps interface{}, reload bool) (int, error) // 配置序列化后，执行该函数 (size,error)
 		OnAfterLoad(reload bool)                           // 所有配置加载后再执行该函数
 	}
+
+	// IValidateConfig 可选接口,OnLoad成功后校验数据
+	IValidateConfig interface {
+		Validate() error // 校验失败时,重载的配置回滚到之前的数据(需实现IRollbackConfig)
+	}
+
+	// IRowConfig 可选接口,通过GetRow获取指定类型的行数据
+	IRowConfig interface {
+		GetRow(key interface{}) (interface{}, bool) // 根据key获取行数据
+	}
+
+	// IReferenceConfig 可选接口,所有配置加载后检查引用其他配置的数据是否存在
+	IReferenceConfig interface {
+		CheckReferences(dc IDataConfig) []error // 返回所有引用错误
+	}
+
+	// IParseObjectConfig 可选接口,提供解析数据的目标对象,解析后的对象传入OnLoad
+	// 未实现时解析到interface{},如使用protobuf解析器时需返回proto.Message
+	IParseObjectConfig interface {
+		NewParseObject() interface{} // 每次加载(重载)时创建新的目标对象
+	}
+
+	// IDiffableConfig 可选接口,重载成功后对比新旧数据,变更记录传给OnReloadDiff注册的回调
+	IDiffableConfig interface {
+		Diff(old, new interface{}) []ChangeRecord // old,new为传入OnLoad的解析数据,可使用DiffRows
+	}
+
+	// ChangeRecord 配置变更记录
+	// 新增行: Field为空,Old为nil; 删除行: Field为空,New为nil; 修改: 每个变更的字段一条记录
+	ChangeRecord struct {
+		Key   interface{} // 行的key
+		Field string      // 变更的字段
+		Old   interface{} // 旧值
+		New   interface{} // 新值
+	}
+
+	// ISwapConfig 可选接口,重载时OnLoad和Validate在NewConfig创建的新实例上执行,全部成功后才调用Swap替换当前数据
+	// 重载失败时当前配置未被修改,同时实现了IRollbackConfig时优先使用该接口
+	ISwapConfig interface {
+		NewConfig() IConfig  // 创建用于重载的新实例(无需调用Init)
+		Swap(loaded IConfig) // 使用重载成功的新实例替换当前数据
+	}
+
+	// IRollbackConfig 可选接口,重载时解析、OnLoad(含panic)或校验失败则回滚数据
+	// OnLoad可能只执行了一部分,实现该接口可保证重载失败后配置仍为完整的旧数据
+	IRollbackConfig interface {
+		Backup()   // 重载前备份当前数据
+		Rollback() // 重载失败时恢复备份的数据
+	}
 )
@@ -0,0 +1,62 @@
+package cherryDataConfig
+
+import (
+	"bytes"
+	"encoding/csv"
+
+	cerr "github.com/cherry-game/cherry/error"
+)
+
+// ParserCsv csv格式解析器
+// 第一行为表头,解析结果为[]map[string]interface{},每行数据以表头作为key
+// 默认分隔符为',',其他分隔符可通过RegisterParser(NewParserCsv(';'))覆盖默认解析器
+type ParserCsv struct {
+	comma rune
+}
+
+func NewParserCsv(comma rune) *ParserCsv {
+	return &ParserCsv{
+		comma: comma,
+	}
+}
+
+func (c *ParserCsv) TypeName() string {
+	return "csv"
+}
+
+func (c *ParserCsv) Unmarshal(data []byte, v interface{}) error {
+	reader := csv.NewReader(bytes.NewReader(data))
+	if c.comma != 0 {
+		reader.Comma = c.comma
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return err
+	}
+
+	var rows []map[string]interface{}
+	if len(records) > 0 {
+		header := records[0]
+		for _, record := range records[1:] {
+			row := make(map[string]interface{}, len(header))
+			for i, key := range header {
+				if i < len(record) {
+					row[key] = record[i]
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	switch value := v.(type) {
+	case *interface{}:
+		*value = rows
+	case *[]map[string]interface{}:
+		*value = rows
+	default:
+		return cerr.Errorf("csv unmarshal type error. [type = %T]", v)
+	}
+
+	return nil
+}
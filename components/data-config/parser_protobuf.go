@@ -0,0 +1,26 @@
+package cherryDataConfig
+
+import (
+	cerr "github.com/cherry-game/cherry/error"
+	"google.golang.org/protobuf/proto"
+)
+
+// ParserProtobuf 解析protobuf序列化的配置数据
+//
+// Unmarshal的v必须为proto.Message,配置需实现IParseObjectConfig返回对应的消息对象,
+// 解析后的消息对象传入IConfig.OnLoad()
+type ParserProtobuf struct {
+}
+
+func (p *ParserProtobuf) TypeName() string {
+	return "protobuf"
+}
+
+func (p *ParserProtobuf) Unmarshal(data []byte, v interface{}) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return cerr.Errorf("protobuf parser requires proto.Message. [type = %T]", v)
+	}
+
+	return proto.Unmarshal(data, msg)
+}
@@ -0,0 +1,139 @@
+package cherryDataConfig
+
+import (
+	"testing"
+
+	cerr "github.com/cherry-game/cherry/error"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestParserYaml(t *testing.T) {
+	d := New()
+	d.parser = GetParser("yaml")
+
+	item := newItemConfig("item")
+	data := []byte(`
+- id: 1
+  name: sword
+- id: 2
+  name: "magic shield"
+`)
+
+	if _, err := d.onLoadConfig(item, data, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(item.rows) != 2 || item.rows["1"].Name != "sword" || item.rows["2"].Name != "magic shield" {
+		t.Fatalf("rows = %v", item.rows)
+	}
+}
+
+func TestParserCsv(t *testing.T) {
+	data := []byte(`id,name,desc
+1,sword,"sharp, heavy"
+2,"magic ""ice"" shield",
+3,bow,"line1
+line2"
+`)
+
+	var rows []map[string]interface{}
+	if err := GetParser("csv").Unmarshal(data, &rows); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 3 {
+		t.Fatalf("rows = %v", rows)
+	}
+
+	if rows[0]["desc"] != "sharp, heavy" || rows[1]["name"] != `magic "ice" shield` || rows[2]["desc"] != "line1\nline2" {
+		t.Fatalf("rows = %v", rows)
+	}
+
+	// a configurable delimiter
+	rows = nil
+	if err := NewParserCsv(';').Unmarshal([]byte("id;name\n1;\"a;b\"\n"), &rows); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 1 || rows[0]["name"] != "a;b" {
+		t.Fatalf("rows = %v", rows)
+	}
+
+	// loaded through onLoadConfig
+	d := New()
+	d.parser = GetParser("csv")
+
+	item := newItemConfig("item")
+	if _, err := d.onLoadConfig(item, data, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(item.rows) != 3 || item.rows["2"].Name != `magic "ice" shield` {
+		t.Fatalf("rows = %v", item.rows)
+	}
+}
+
+type protoConfig struct {
+	rows map[string]string
+}
+
+func (p *protoConfig) Name() string {
+	return "proto"
+}
+
+func (p *protoConfig) Init() {
+}
+
+func (p *protoConfig) NewParseObject() interface{} {
+	return &structpb.Struct{}
+}
+
+func (p *protoConfig) OnLoad(maps interface{}, _ bool) (int, error) {
+	msg, ok := maps.(*structpb.Struct)
+	if !ok {
+		return 0, cerr.Errorf("maps type error. [type = %T]", maps)
+	}
+
+	p.rows = make(map[string]string, len(msg.Fields))
+	for id, value := range msg.Fields {
+		p.rows[id] = value.GetStringValue()
+	}
+
+	return len(p.rows), nil
+}
+
+func (p *protoConfig) OnAfterLoad(_ bool) {
+}
+
+func TestParserProtobuf(t *testing.T) {
+	msg, err := structpb.NewStruct(map[string]interface{}{
+		"1": "sword",
+		"2": "shield",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := New()
+	d.parser = GetParser("protobuf")
+
+	cfg := &protoConfig{}
+	if _, err = d.onLoadConfig(cfg, data, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cfg.rows) != 2 || cfg.rows["1"] != "sword" || cfg.rows["2"] != "shield" {
+		t.Fatalf("rows = %v", cfg.rows)
+	}
+
+	// the config without a proto.Message parse object
+	if _, err = d.onLoadConfig(newItemConfig("item"), data, false); err == nil {
+		t.Fatal("unmarshal to interface{} without error")
+	}
+}
@@ -0,0 +1,16 @@
+package cherryDataConfig
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+type ParserYaml struct {
+}
+
+func (y *ParserYaml) TypeName() string {
+	return "yaml"
+}
+
+func (y *ParserYaml) Unmarshal(data []byte, v interface{}) error {
+	return yaml.Unmarshal(data, v)
+}
@@ -0,0 +1,35 @@
+package cherryDataConfig
+
+// GetRow 获取配置的行数据并转换为T类型,配置需实现IRowConfig
+//
+//	item, found := cherryDataConfig.GetRow[*ItemRow](dataConfig, "item", 1001)
+func GetRow[T any](d *Component, configName string, key interface{}) (T, bool) {
+	var zero T
+
+	cfg := d.GetIConfig(configName)
+	if cfg == nil {
+		return zero, false
+	}
+
+	rowConfig, ok := cfg.(IRowConfig)
+	if !ok {
+		logger().Warnf("[config = %s] is not implement IRowConfig.", configName)
+		return zero, false
+	}
+
+	d.RLock()
+	row, found := rowConfig.GetRow(key)
+	d.RUnlock()
+
+	if !found {
+		return zero, false
+	}
+
+	value, ok := row.(T)
+	if !ok {
+		logger().Warnf("[config = %s] row type error. [key = %v, type = %T]", configName, key, row)
+		return zero, false
+	}
+
+	return value, true
+}
@@ -7,7 +7,6 @@ import (
 
 	cerr "github.com/cherry-game/cherry/error"
 	cfile "github.com/cherry-game/cherry/extend/file"
-	clog "github.com/cherry-game/cherry/logger"
 	cprofile "github.com/cherry-game/cherry/profile"
 	"github.com/radovskyb/watcher"
 )
@@ -35,29 +34,30 @@ func (f *SourceFile) Name() string {
 func (f *SourceFile) Init(_ IDataConfig) {
 	err := f.unmarshalFileConfig()
 	if err != nil {
-		clog.Panicf("Unmarshal fileConfig fail. err = %v", err)
+		logger().Panicf("Unmarshal fileConfig fail. err = %v", err)
 		return
 	}
 
 	f.watcher = watcher.New()
-	f.watcher.FilterOps(watcher.Write)
+	// 编辑器保存文件时可能先删除再创建(如vim),同时监听Create
+	f.watcher.FilterOps(watcher.Write, watcher.Create)
 	var regexpFilter *regexp.Regexp
 	regexpFilter, err = regexp.Compile(`.*\` + f.ExtName + `$`)
 	if err != nil {
-		clog.Panicf("AddFilterHook extName fail. err = %v", err)
+		logger().Panicf("AddFilterHook extName fail. err = %v", err)
 		return
 	}
 	f.watcher.AddFilterHook(watcher.RegexFilterHook(regexpFilter, false))
 
 	f.monitorPath, err = cfile.JoinPath(cprofile.Path(), f.FilePath)
 	if err != nil {
-		clog.Panicf("[name = %s] join path fail. err = %v.", f.Name(), err)
+		logger().Panicf("[name = %s] join path fail. err = %v.", f.Name(), err)
 		return
 	}
 
 	err = f.watcher.Add(f.monitorPath)
 	if err != nil {
-		clog.Panicf("New watcher error. path=%s, err = %v", f.monitorPath, err)
+		logger().Panicf("New watcher error. path=%s, err = %v", f.monitorPath, err)
 		return
 	}
 
@@ -102,11 +102,11 @@ func (f *SourceFile) newWatcher() {
 					}
 
 					configName := cfile.GetFileName(ev.FileInfo.Name(), true)
-					clog.Infof("Trigger file change. [name = %s]", configName)
+					logger().Infof("Trigger file change. [name = %s]", configName)
 
 					data, err := f.ReadBytes(configName)
 					if err != nil {
-						clog.Warn("Read data fail. [name = %s, err = %s]", configName, err)
+						logger().Warnf("Read data fail. [name = %s, err = %s]", configName, err)
 						continue
 					}
 
@@ -116,7 +116,7 @@ func (f *SourceFile) newWatcher() {
 				}
 			case err := <-f.watcher.Error:
 				{
-					clog.Error(err)
+					logger().Error(err)
 					continue
 				}
 			case <-f.watcher.Closed:
@@ -127,7 +127,7 @@ func (f *SourceFile) newWatcher() {
 
 	err := f.watcher.Start(time.Duration(f.ReloadTime) * time.Millisecond)
 	if err != nil {
-		clog.Panic(err)
+		logger().Panic(err)
 	}
 }
 
@@ -137,7 +137,7 @@ func (f *SourceFile) Stop() {
 	}
 
 	err := f.watcher.Remove(f.monitorPath)
-	clog.Infof("Remote watcher [path = %s, err = %v]", f.monitorPath, err)
+	logger().Infof("Remote watcher [path = %s, err = %v]", f.monitorPath, err)
 
 	f.watcher.Close()
 }
@@ -0,0 +1,311 @@
+package cherryDataConfig
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	cerr "github.com/cherry-game/cherry/error"
+	cprofile "github.com/cherry-game/cherry/profile"
+)
+
+type (
+	// SourceHttp 通过http(s)获取数据配置
+	//
+	// 从profile-x.json中获取data_config的属性配置，
+	// 如果"data_source"的值为"http"，则启用http方式读取数据配置.
+	// 请求地址为{base_url}/{configName}{ext_name}
+	// 定时通过ETag/Last-Modified检查已读取的配置是否有变更，有变更则进行重新加载处理.
+	// 连续请求失败(网络错误或5xx)breaker_failures次后熔断，breaker_cooldown时间内请求直接失败且不触发变更，保持最后一次成功的配置.
+	SourceHttp struct {
+		httpConfig
+		changeFn  ConfigChangeFn
+		client    *http.Client
+		transport *http.Transport
+		tags      sync.Map // key:configName, value:*httpTag
+		close     chan struct{}
+		breaker   httpBreaker
+	}
+
+	httpConfig struct {
+		BaseURL         string `json:"base_url"`         // 配置地址
+		ExtName         string `json:"ext_name"`         // 文件扩展名
+		PollTime        int64  `json:"poll_time"`        // 定时检查变更(毫秒)
+		Timeout         int64  `json:"timeout"`          // 请求超时(毫秒)
+		IdleTimeout     int64  `json:"idle_timeout"`     // 空闲连接超时关闭(毫秒)
+		BreakerFailures int    `json:"breaker_failures"` // 连续失败次数达到该值时熔断
+		BreakerCooldown int64  `json:"breaker_cooldown"` // 熔断持续时间(毫秒)
+	}
+
+	// httpBreaker 熔断器
+	httpBreaker struct {
+		sync.Mutex
+		failures  int       // 连续失败次数
+		openUntil time.Time // 熔断结束时间
+		lastErr   error     // 最后一次失败的错误
+	}
+
+	httpTag struct {
+		etag         string
+		lastModified string
+	}
+
+	// httpStatusError 服务器已响应但没有返回配置数据,只有5xx计入熔断
+	httpStatusError struct {
+		url    string
+		status int
+		msg    string
+	}
+)
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("%s [url = %s, status = %d]", e.msg, e.url, e.status)
+}
+
+func (h *SourceHttp) Name() string {
+	return "http"
+}
+
+func (h *SourceHttp) Init(_ IDataConfig) {
+	//read data_config->http node
+	dataConfig := cprofile.GetConfig("data_config").GetConfig(h.Name())
+	if err := dataConfig.Unmarshal(&h.httpConfig); err != nil {
+		logger().Panicf("Unmarshal httpConfig fail. err = %v", err)
+		return
+	}
+
+	if h.BaseURL == "" {
+		logger().Panicf("[data_config]->[%s]->[base_url] is empty.", h.Name())
+		return
+	}
+
+	h.start()
+}
+
+// start 设置默认参数并启动定时检查
+func (h *SourceHttp) start() {
+	h.BaseURL = strings.TrimSuffix(h.BaseURL, "/")
+
+	if len(h.ExtName) < 1 {
+		h.ExtName = ".json"
+	}
+
+	if h.PollTime < 1 {
+		h.PollTime = 3000
+	}
+
+	if h.Timeout < 1 {
+		h.Timeout = 3000
+	}
+
+	if h.IdleTimeout < 1 {
+		h.IdleTimeout = 90000
+	}
+
+	if h.BreakerFailures < 1 {
+		h.BreakerFailures = 3
+	}
+
+	if h.BreakerCooldown < 1 {
+		h.BreakerCooldown = 10000
+	}
+
+	// 复用连接,空闲超时后自动关闭
+	h.transport = http.DefaultTransport.(*http.Transport).Clone()
+	h.transport.IdleConnTimeout = time.Duration(h.IdleTimeout) * time.Millisecond
+	h.transport.MaxIdleConnsPerHost = 4
+
+	h.client = &http.Client{
+		Transport: h.transport,
+		Timeout:   time.Duration(h.Timeout) * time.Millisecond,
+	}
+	h.close = make(chan struct{})
+
+	go h.newPoller()
+}
+
+func (h *SourceHttp) ReadBytes(configName string) ([]byte, error) {
+	if configName == "" {
+		return nil, cerr.Error("Config name is empty.")
+	}
+
+	data, _, err := h.request(configName, nil)
+	return data, err
+}
+
+// request 请求配置,tag不为nil时进行条件请求,返回changed=false表示配置未变更
+func (h *SourceHttp) request(configName string, tag *httpTag) ([]byte, bool, error) {
+	if h.BreakerOpen() {
+		return nil, false, cerr.Errorf("Config server breaker is open. [configName = %s]", configName)
+	}
+
+	data, changed, err := h.doRequest(configName, tag)
+	h.breaker.record(breakerFault(err), h.BreakerFailures, time.Duration(h.BreakerCooldown)*time.Millisecond)
+
+	return data, changed, err
+}
+
+func (h *SourceHttp) doRequest(configName string, tag *httpTag) ([]byte, bool, error) {
+	url := h.BaseURL + "/" + configName + h.ExtName
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if tag != nil {
+		if tag.etag != "" {
+			req.Header.Set("If-None-Match", tag.etag)
+		}
+
+		if tag.lastModified != "" {
+			req.Header.Set("If-Modified-Since", tag.lastModified)
+		}
+	}
+
+	rsp, err := h.client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+
+	defer func() {
+		if e := rsp.Body.Close(); e != nil {
+			logger().Warn(e)
+		}
+	}()
+
+	if rsp.StatusCode == http.StatusNotModified {
+		return nil, false, nil
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		return nil, false, &httpStatusError{url: url, status: rsp.StatusCode, msg: "Request config fail."}
+	}
+
+	data, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(data) < 1 {
+		return nil, false, &httpStatusError{url: url, status: rsp.StatusCode, msg: "Data is empty."}
+	}
+
+	h.tags.Store(configName, &httpTag{
+		etag:         rsp.Header.Get("ETag"),
+		lastModified: rsp.Header.Get("Last-Modified"),
+	})
+
+	return data, true, nil
+}
+
+func (h *SourceHttp) OnChange(fn ConfigChangeFn) {
+	h.changeFn = fn
+}
+
+func (h *SourceHttp) newPoller() {
+	ticker := time.NewTicker(time.Duration(h.PollTime) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.close:
+			return
+		case <-ticker.C:
+			// 熔断期间不请求,保持最后一次成功的配置
+			if h.BreakerOpen() {
+				continue
+			}
+
+			h.tags.Range(func(key, value any) bool {
+				configName := key.(string)
+
+				data, changed, err := h.request(configName, value.(*httpTag))
+				if err != nil {
+					logger().Warnf("Poll config fail. [name = %s, err = %v]", configName, err)
+					return !h.BreakerOpen()
+				}
+
+				if !changed {
+					return true
+				}
+
+				logger().Infof("Trigger config change. [name = %s]", configName)
+
+				if h.changeFn != nil {
+					h.changeFn(configName, data)
+				}
+				return true
+			})
+		}
+	}
+}
+
+func (h *SourceHttp) Stop() {
+	if h.close != nil {
+		close(h.close)
+	}
+
+	if h.transport != nil {
+		h.transport.CloseIdleConnections()
+	}
+}
+
+// BreakerOpen 是否处于熔断状态
+func (h *SourceHttp) BreakerOpen() bool {
+	return h.breaker.isOpen()
+}
+
+// HealthCheck 熔断期间返回最后一次请求失败的错误
+func (h *SourceHttp) HealthCheck() error {
+	h.breaker.Lock()
+	defer h.breaker.Unlock()
+
+	if time.Now().Before(h.breaker.openUntil) {
+		return cerr.Wrapf(h.breaker.lastErr, "config server breaker is open until %s", h.breaker.openUntil.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// breakerFault 网络错误及5xx计入熔断,404等4xx或空数据说明服务器正常,不计入
+func breakerFault(err error) error {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) && statusErr.status < http.StatusInternalServerError {
+		return nil
+	}
+
+	return err
+}
+
+func (b *httpBreaker) isOpen() bool {
+	b.Lock()
+	defer b.Unlock()
+
+	return time.Now().Before(b.openUntil)
+}
+
+// record 记录请求结果,连续失败maxFailures次后熔断cooldown时间
+func (b *httpBreaker) record(err error, maxFailures int, cooldown time.Duration) {
+	b.Lock()
+	defer b.Unlock()
+
+	if err == nil {
+		b.failures = 0
+		b.lastErr = nil
+		return
+	}
+
+	b.failures++
+	b.lastErr = err
+
+	if b.failures >= maxFailures {
+		b.failures = 0
+		b.openUntil = time.Now().Add(cooldown)
+		logger().Warnf("Config server breaker is open. [cooldown = %v, err = %v]", cooldown, err)
+	}
+}
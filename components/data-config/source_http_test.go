@@ -0,0 +1,162 @@
+package cherryDataConfig
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testConfigServer 返回data,ETag为data本身,status不为0时直接返回该状态码
+type testConfigServer struct {
+	sync.Mutex
+	data     string
+	status   int
+	requests int32
+}
+
+func (s *testConfigServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&s.requests, 1)
+
+	s.Lock()
+	data, status := s.data, s.status
+	s.Unlock()
+
+	if status != 0 {
+		w.WriteHeader(status)
+		return
+	}
+
+	etag := `"` + data + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+	_, _ = w.Write([]byte(data))
+}
+
+func (s *testConfigServer) set(data string, status int) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.data = data
+	s.status = status
+}
+
+func newTestSourceHttp(t *testing.T, server *testConfigServer, pollTime int64) *SourceHttp {
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+
+	h := &SourceHttp{
+		httpConfig: httpConfig{
+			BaseURL:         ts.URL,
+			PollTime:        pollTime,
+			BreakerFailures: 2,
+			BreakerCooldown: 200,
+		},
+	}
+	h.start()
+	t.Cleanup(h.Stop)
+
+	return h
+}
+
+func TestSourceHttpNotModified(t *testing.T) {
+	server := &testConfigServer{data: "v1"}
+	h := newTestSourceHttp(t, server, 60000)
+
+	data, err := h.ReadBytes("item")
+	if err != nil || string(data) != "v1" {
+		t.Fatalf("data = %s, err = %v", data, err)
+	}
+
+	tag, _ := h.tags.Load("item")
+	if data, changed, err := h.request("item", tag.(*httpTag)); err != nil || changed || data != nil {
+		t.Fatalf("data = %s, changed = %v, err = %v", data, changed, err)
+	}
+}
+
+func TestSourceHttpChange(t *testing.T) {
+	server := &testConfigServer{data: "v1"}
+	h := newTestSourceHttp(t, server, 20)
+
+	changeChan := make(chan string, 1)
+	h.OnChange(func(configName string, data []byte) {
+		changeChan <- configName + ":" + string(data)
+	})
+
+	if _, err := h.ReadBytes("item"); err != nil {
+		t.Fatal(err)
+	}
+
+	// not modified, the change function is not triggered
+	select {
+	case change := <-changeChan:
+		t.Fatalf("unexpected change = %s", change)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	server.set("v2", 0)
+
+	select {
+	case change := <-changeChan:
+		if change != "item:v2" {
+			t.Fatalf("change = %s", change)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("change is not triggered")
+	}
+}
+
+func TestSourceHttpBreaker(t *testing.T) {
+	server := &testConfigServer{data: "v1", status: http.StatusInternalServerError}
+	h := newTestSourceHttp(t, server, 60000)
+
+	for i := 0; i < 2; i++ {
+		if _, err := h.ReadBytes("item"); err == nil {
+			t.Fatal("read from the failed server without error")
+		}
+	}
+
+	if !h.BreakerOpen() || h.HealthCheck() == nil {
+		t.Fatal("breaker is not open after 2 failures")
+	}
+
+	// the request fails fast while the breaker is open
+	requests := atomic.LoadInt32(&server.requests)
+	if _, err := h.ReadBytes("item"); err == nil || atomic.LoadInt32(&server.requests) != requests {
+		t.Fatalf("request while breaker is open. [err = %v]", err)
+	}
+
+	server.set("v1", 0)
+	time.Sleep(250 * time.Millisecond)
+
+	// the cooldown is over
+	if data, err := h.ReadBytes("item"); err != nil || string(data) != "v1" {
+		t.Fatalf("data = %s, err = %v", data, err)
+	}
+
+	if h.BreakerOpen() || h.HealthCheck() != nil {
+		t.Fatal("breaker is open after the cooldown")
+	}
+}
+
+func TestSourceHttpNotFound(t *testing.T) {
+	server := &testConfigServer{status: http.StatusNotFound}
+	h := newTestSourceHttp(t, server, 60000)
+
+	for i := 0; i < 5; i++ {
+		if _, err := h.ReadBytes("item"); err == nil {
+			t.Fatal("read the missing config without error")
+		}
+	}
+
+	// the server is alive, 404 does not open the breaker
+	if h.BreakerOpen() || h.HealthCheck() != nil {
+		t.Fatal("breaker is open by 404")
+	}
+}
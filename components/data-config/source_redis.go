@@ -5,7 +5,6 @@ import (
 	"fmt"
 
 	cerr "github.com/cherry-game/cherry/error"
-	clog "github.com/cherry-game/cherry/logger"
 	cprofile "github.com/cherry-game/cherry/profile"
 	"github.com/go-redis/redis/v8"
 )
@@ -20,7 +19,7 @@ type (
 	SourceRedis struct {
 		redisConfig
 		changeFn ConfigChangeFn
-		close    chan bool
+		close    chan struct{}
 		rdb      *redis.Client
 	}
 
@@ -41,12 +40,19 @@ func (r *SourceRedis) Init(_ IDataConfig) {
 	//read data_config->file node
 	dataConfig := cprofile.GetConfig("data_config").GetConfig(r.Name())
 	if dataConfig.Unmarshal(&r.redisConfig) != nil {
-		clog.Warnf("[data_config]->[%s] node in `%s` file not found.", r.Name(), cprofile.Name())
+		logger().Warnf("[data_config]->[%s] node in `%s` file not found.", r.Name(), cprofile.Name())
 		return
 	}
 
+	// 未配置subscribe_key时仍可读取数据,只是不订阅变更
 	r.newRedis()
-	r.close = make(chan bool)
+
+	if r.SubscribeKey == "" {
+		logger().Warnf("[data_config]->[%s]->[subscribe_key] is empty, config changes are not subscribed.", r.Name())
+		return
+	}
+
+	r.close = make(chan struct{})
 
 	go r.newSubscribe()
 }
@@ -57,40 +63,45 @@ func (r *SourceRedis) newRedis() {
 		Password: r.Password,
 		DB:       r.DB,
 		OnConnect: func(ctx context.Context, cn *redis.Conn) error {
-			clog.Infof("data config for redis connected")
+			logger().Infof("data config for redis connected")
 			return nil
 		},
 	})
 }
 
 func (r *SourceRedis) newSubscribe() {
-	if r.SubscribeKey == "" {
-		panic("subscribe key is empty.")
-	}
-
 	sub := r.rdb.Subscribe(context.Background(), r.SubscribeKey)
 
 	defer func(sub *redis.PubSub) {
-		err := sub.Close()
-		if err != nil {
-			clog.Warn(err)
+		if err := sub.Unsubscribe(context.Background(), r.SubscribeKey); err != nil {
+			logger().Warn(err)
+		}
+
+		if err := sub.Close(); err != nil {
+			logger().Warn(err)
 		}
 	}(sub)
 
+	channel := sub.Channel()
+
 	for {
 		select {
 		case <-r.close:
 			return
-		case ch := <-sub.Channel():
+		case ch, ok := <-channel:
+			if !ok {
+				return
+			}
+
 			if ch.Payload == "" {
 				continue
 			}
 
-			clog.Infof("[name = %s] trigger file change.", ch.Payload)
+			logger().Infof("[name = %s] trigger file change.", ch.Payload)
 
 			data, err := r.ReadBytes(ch.Payload)
 			if err != nil {
-				clog.Warnf("[name = %s] read data error = %s", ch.Payload, err)
+				logger().Warnf("[name = %s] read data error = %s", ch.Payload, err)
 				continue
 			}
 
@@ -106,6 +117,10 @@ func (r *SourceRedis) ReadBytes(configName string) ([]byte, error) {
 		return nil, cerr.Error("configName is empty.")
 	}
 
+	if r.rdb == nil {
+		return nil, cerr.Error("redis client is not initialized.")
+	}
+
 	key := fmt.Sprintf("%s:%s", r.PrefixKey, configName)
 
 	return r.rdb.Get(context.Background(), key).Bytes()
@@ -116,13 +131,17 @@ func (r *SourceRedis) OnChange(fn ConfigChangeFn) {
 }
 
 func (r *SourceRedis) Stop() {
-	clog.Infof("close redis client [address = %s]", r.Address)
-	r.close <- true
+	logger().Infof("close redis client [address = %s]", r.Address)
+
+	// unsubscribe and exit the subscribe goroutine
+	if r.close != nil {
+		close(r.close)
+	}
 
 	if r.rdb != nil {
 		err := r.rdb.Close()
 		if err != nil {
-			clog.Error(err)
+			logger().Error(err)
 		}
 	}
 }
@@ -0,0 +1,380 @@
+package cherryGRPC
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	ccode "github.com/cherry-game/cherry/code"
+	cerr "github.com/cherry-game/cherry/error"
+	cfacade "github.com/cherry-game/cherry/facade"
+	clog "github.com/cherry-game/cherry/logger"
+	cproto "github.com/cherry-game/cherry/net/proto"
+	cprofile "github.com/cherry-game/cherry/profile"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	Mode = "grpc"
+)
+
+type (
+	// Cluster 基于grpc实现的集群通信
+	//
+	// 每个节点在rpc_address上启动grpc服务,
+	// publish消息通过client stream发送到目标节点,request消息使用unary调用.
+	// 目标节点已移除或地址变更、发送失败及连接不可用时移除缓存的client,下次发送时重新连接
+	Cluster struct {
+		app            cfacade.IApplication
+		server         *grpc.Server
+		requestTimeout time.Duration
+		dialTimeout    time.Duration
+		clientLock     sync.RWMutex
+		clients        map[string]*client // key:nodeId, value:*client
+	}
+
+	client struct {
+		sync.Mutex
+		nodeId  string
+		address string
+		conn    *grpc.ClientConn
+		streams map[string]grpc.ClientStream // key:method, value:stream
+	}
+
+	// reply 用于接收actor执行结果
+	reply struct {
+		ch chan []byte
+	}
+)
+
+// New 创建grpc集群
+//
+// 配置profile文件,设置"cluster"->"mode"为"grpc"
+//
+//	"cluster": {
+//	  "mode": "grpc",
+//	  "grpc": {
+//	    "request_timeout": 3,
+//	    "dial_timeout": 3
+//	  }
+//	}
+func New(app cfacade.IApplication) cfacade.ICluster {
+	config := cprofile.GetConfig("cluster").GetConfig(Mode)
+
+	return &Cluster{
+		app:            app,
+		requestTimeout: config.GetDuration("request_timeout", 3) * time.Second,
+		dialTimeout:    config.GetDuration("dial_timeout", 3) * time.Second,
+		clients:        make(map[string]*client),
+	}
+}
+
+func (p *Cluster) Init() {
+	address := p.app.RpcAddress()
+	if address == "" {
+		clog.Panicf("[nodeId = %s] rpc_address is empty.", p.app.NodeId())
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		clog.Panicf("grpc listen fail. [address = %s, err = %v]", address, err)
+	}
+
+	p.server = grpc.NewServer()
+	p.server.RegisterService(&clusterServiceDesc, p)
+
+	go func() {
+		if err := p.server.Serve(listener); err != nil {
+			clog.Warnf("grpc serve stop. [address = %s, err = %v]", address, err)
+		}
+	}()
+
+	clog.Infof("grpc cluster execute OnInit(). [address = %s]", address)
+}
+
+func (p *Cluster) Stop() {
+	p.clientLock.Lock()
+	clients := p.clients
+	p.clients = make(map[string]*client)
+	p.clientLock.Unlock()
+
+	for _, c := range clients {
+		c.close()
+	}
+
+	if p.server != nil {
+		p.server.GracefulStop()
+	}
+
+	clog.Info("grpc cluster execute OnStop().")
+}
+
+func (p *Cluster) PublishLocal(nodeId string, packet *cproto.ClusterPacket) error {
+	defer packet.Recycle()
+	return p.publish(nodeId, publishLocalMethod, packet)
+}
+
+func (p *Cluster) PublishRemote(nodeId string, packet *cproto.ClusterPacket) error {
+	defer packet.Recycle()
+	return p.publish(nodeId, publishRemoteMethod, packet)
+}
+
+func (p *Cluster) RequestRemote(nodeId string, packet *cproto.ClusterPacket, timeout ...time.Duration) cproto.Response {
+	defer packet.Recycle()
+
+	rsp := cproto.Response{}
+
+	c, err := p.getClient(nodeId)
+	if err != nil {
+		clog.Debugf("[RequestRemote] Get client fail. [nodeId = %s, %s, err = %v]",
+			nodeId,
+			packet.PrintLog(),
+			err,
+		)
+
+		rsp.Code = ccode.DiscoveryNotFoundNode
+		return rsp
+	}
+
+	requestTimeout := p.requestTimeout
+	if len(timeout) > 0 {
+		requestTimeout = timeout[0]
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	if err = c.conn.Invoke(ctx, requestRemoteMethod, packet, &rsp); err != nil {
+		clog.Warnf("[RequestRemote] grpc request fail. [nodeId = %s, %s, err = %v]",
+			nodeId,
+			packet.PrintLog(),
+			err,
+		)
+
+		// 连接不可用时重新连接,请求超时等错误不影响连接
+		if status.Code(err) == codes.Unavailable {
+			p.removeClient(nodeId, c)
+		}
+
+		rsp.Code = ccode.RPCNetError
+		return rsp
+	}
+
+	return rsp
+}
+
+func (p *Cluster) publish(nodeId, method string, packet *cproto.ClusterPacket) error {
+	if !p.app.Running() {
+		return cerr.ClusterRPCClientIsStop
+	}
+
+	c, err := p.getClient(nodeId)
+	if err != nil {
+		return err
+	}
+
+	if err = c.send(method, packet); err != nil {
+		p.removeClient(nodeId, c)
+		return err
+	}
+
+	return nil
+}
+
+// getClient 获取节点的client,节点已移除或地址变更时移除缓存的client
+func (p *Cluster) getClient(nodeId string) (*client, error) {
+	member, found := p.app.Discovery().GetMember(nodeId)
+	if !found {
+		p.removeClient(nodeId, nil)
+		return nil, cerr.Errorf("[nodeId = %s] member not found.", nodeId)
+	}
+
+	address := member.GetAddress()
+
+	p.clientLock.RLock()
+	c, found := p.clients[nodeId]
+	p.clientLock.RUnlock()
+
+	if found {
+		if c.address == address {
+			return c, nil
+		}
+		p.removeClient(nodeId, c)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.dialTimeout)
+	defer cancel()
+
+	// 阻塞到连接建立或dial_timeout超时,节点不可达时返回错误,不缓存无法连接的client
+	conn, err := grpc.DialContext(ctx, address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	c = &client{
+		nodeId:  nodeId,
+		address: address,
+		conn:    conn,
+		streams: make(map[string]grpc.ClientStream),
+	}
+
+	p.clientLock.Lock()
+	if exist, found := p.clients[nodeId]; found && exist.address == address {
+		p.clientLock.Unlock()
+		c.close()
+		return exist, nil
+	}
+	p.clients[nodeId] = c
+	p.clientLock.Unlock()
+
+	return c, nil
+}
+
+// removeClient 移除并关闭缓存的client,c不为nil时只移除该client(已被重建的client不受影响)
+func (p *Cluster) removeClient(nodeId string, c *client) {
+	p.clientLock.Lock()
+	exist, found := p.clients[nodeId]
+	if !found || (c != nil && exist != c) {
+		p.clientLock.Unlock()
+		return
+	}
+	delete(p.clients, nodeId)
+	p.clientLock.Unlock()
+
+	clog.Debugf("[nodeId = %s] grpc client removed. [address = %s]", nodeId, exist.address)
+	exist.close()
+}
+
+func (p *Cluster) publishLocal(stream grpc.ServerStream) error {
+	return p.receive(stream, func(packet *cproto.ClusterPacket) {
+		message := cfacade.GetMessage()
+		message.BuildTime = packet.BuildTime
+		message.Source = packet.SourcePath
+		message.Target = packet.TargetPath
+		message.FuncName = packet.FuncName
+		message.IsCluster = true
+		message.Session = packet.Session
+		message.Args = packet.ArgBytes
+
+		p.app.ActorSystem().PostLocal(message)
+	})
+}
+
+func (p *Cluster) publishRemote(stream grpc.ServerStream) error {
+	return p.receive(stream, func(packet *cproto.ClusterPacket) {
+		p.app.ActorSystem().PostRemote(buildRemoteMessage(packet))
+	})
+}
+
+func (p *Cluster) requestRemote(ctx context.Context, packet *cproto.ClusterPacket) (*cproto.Response, error) {
+	r := &reply{
+		ch: make(chan []byte, 1),
+	}
+
+	message := buildRemoteMessage(packet)
+	message.ClusterReply = r
+
+	if !p.app.ActorSystem().PostRemote(message) {
+		return &cproto.Response{Code: ccode.ActorCallFail}, nil
+	}
+
+	select {
+	case data := <-r.ch:
+		{
+			rsp := &cproto.Response{}
+			if err := proto.Unmarshal(data, rsp); err != nil {
+				rsp.Code = ccode.RPCUnmarshalError
+			}
+			return rsp, nil
+		}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (p *Cluster) receive(stream grpc.ServerStream, fn func(packet *cproto.ClusterPacket)) error {
+	for {
+		packet := &cproto.ClusterPacket{}
+		if err := stream.RecvMsg(packet); err != nil {
+			if err == io.EOF {
+				return stream.SendMsg(&cproto.Response{})
+			}
+			return err
+		}
+
+		fn(packet)
+	}
+}
+
+func buildRemoteMessage(packet *cproto.ClusterPacket) *cfacade.Message {
+	message := cfacade.GetMessage()
+	message.BuildTime = packet.BuildTime
+	message.Source = packet.SourcePath
+	message.Target = packet.TargetPath
+	message.FuncName = packet.FuncName
+	if packet.ArgBytes != nil {
+		message.Args = packet.ArgBytes
+	}
+	message.IsCluster = true
+
+	return message
+}
+
+// send 通过client stream发送packet,发送失败时重建stream再重试一次
+func (c *client) send(method string, packet *cproto.ClusterPacket) error {
+	c.Lock()
+	defer c.Unlock()
+
+	var err error
+	for i := 0; i < 2; i++ {
+		stream, found := c.streams[method]
+		if !found {
+			stream, err = c.conn.NewStream(context.Background(), publishStreamDesc, method)
+			if err != nil {
+				return err
+			}
+			c.streams[method] = stream
+		}
+
+		if err = stream.SendMsg(packet); err == nil {
+			return nil
+		}
+
+		delete(c.streams, method)
+		clog.Debugf("[nodeId = %s] grpc stream send fail. [method = %s, err = %v]", c.nodeId, method, err)
+	}
+
+	return err
+}
+
+func (c *client) close() {
+	c.Lock()
+	defer c.Unlock()
+
+	for method, stream := range c.streams {
+		_ = stream.CloseSend()
+		delete(c.streams, method)
+	}
+
+	if err := c.conn.Close(); err != nil {
+		clog.Warnf("[nodeId = %s] grpc conn close error. [err = %v]", c.nodeId, err)
+	}
+}
+
+func (r *reply) Respond(data []byte) error {
+	select {
+	case r.ch <- data:
+		return nil
+	default:
+		return cerr.Error("reply has been responded.")
+	}
+}
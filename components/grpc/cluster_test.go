@@ -0,0 +1,210 @@
+package cherryGRPC
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	ccode "github.com/cherry-game/cherry/code"
+	cfacade "github.com/cherry-game/cherry/facade"
+	cproto "github.com/cherry-game/cherry/net/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+type (
+	testApp struct {
+		cfacade.IApplication
+		nodeId      string
+		address     string
+		discovery   *testDiscovery
+		actorSystem *testActorSystem
+	}
+
+	testDiscovery struct {
+		cfacade.IDiscovery
+		sync.Mutex
+		members map[string]cfacade.IMember
+	}
+
+	// testActorSystem 接收到的消息写入messages,request消息直接响应参数
+	testActorSystem struct {
+		cfacade.IActorSystem
+		messages chan *cfacade.Message
+	}
+)
+
+func (p *testApp) NodeId() string {
+	return p.nodeId
+}
+
+func (p *testApp) RpcAddress() string {
+	return p.address
+}
+
+func (p *testApp) Running() bool {
+	return true
+}
+
+func (p *testApp) Discovery() cfacade.IDiscovery {
+	return p.discovery
+}
+
+func (p *testApp) ActorSystem() cfacade.IActorSystem {
+	return p.actorSystem
+}
+
+func (p *testDiscovery) GetMember(nodeId string) (cfacade.IMember, bool) {
+	p.Lock()
+	defer p.Unlock()
+
+	member, found := p.members[nodeId]
+	return member, found
+}
+
+func (p *testDiscovery) AddMember(member cfacade.IMember) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.members[member.GetNodeId()] = member
+}
+
+func (p *testDiscovery) RemoveMember(nodeId string) {
+	p.Lock()
+	defer p.Unlock()
+
+	delete(p.members, nodeId)
+}
+
+func (p *testActorSystem) PostRemote(m *cfacade.Message) bool {
+	if m.ClusterReply != nil {
+		data, _ := proto.Marshal(&cproto.Response{Data: m.Args.([]byte)})
+		_ = m.ClusterReply.Respond(data)
+		return true
+	}
+
+	p.messages <- m
+	return true
+}
+
+func (p *testActorSystem) PostLocal(m *cfacade.Message) bool {
+	p.messages <- m
+	return true
+}
+
+func freeAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	return listener.Addr().String()
+}
+
+func newTestCluster(t *testing.T, nodeId string, discovery *testDiscovery) (*Cluster, *testApp) {
+	app := &testApp{
+		nodeId:      nodeId,
+		address:     freeAddress(t),
+		discovery:   discovery,
+		actorSystem: &testActorSystem{messages: make(chan *cfacade.Message, 8)},
+	}
+
+	cluster := &Cluster{
+		app:            app,
+		requestTimeout: time.Second,
+		dialTimeout:    time.Second,
+		clients:        make(map[string]*client),
+	}
+	cluster.Init()
+
+	discovery.AddMember(&cproto.Member{NodeId: nodeId, Address: app.address})
+	return cluster, app
+}
+
+func receive(t *testing.T, app *testApp) *cfacade.Message {
+	select {
+	case m := <-app.actorSystem.messages:
+		return m
+	case <-time.After(3 * time.Second):
+		t.Fatalf("[nodeId = %s] message not received.", app.nodeId)
+	}
+	return nil
+}
+
+func TestClusterPublishRequest(t *testing.T) {
+	discovery := &testDiscovery{members: make(map[string]cfacade.IMember)}
+
+	node1, _ := newTestCluster(t, "node1", discovery)
+	node2, app2 := newTestCluster(t, "node2", discovery)
+
+	// node1 closes its streams first, then node2 stops gracefully
+	defer func() {
+		node1.Stop()
+		node2.Stop()
+	}()
+
+	packet := cproto.BuildClusterPacket("node1.player", "node2.room", "join")
+	packet.ArgBytes = []byte("1001")
+	if err := node1.PublishRemote("node2", packet); err != nil {
+		t.Fatal(err)
+	}
+
+	if m := receive(t, app2); m.Target != "node2.room" || m.FuncName != "join" || string(m.Args.([]byte)) != "1001" {
+		t.Fatalf("message = %+v", m)
+	}
+
+	packet = cproto.BuildClusterPacket("node1.player", "node2.room", "info")
+	packet.ArgBytes = []byte("1002")
+	if rsp := node1.RequestRemote("node2", packet); rsp.Code != ccode.OK || string(rsp.Data) != "1002" {
+		t.Fatalf("rsp = %+v", &rsp)
+	}
+
+	// the removed member evicts the cached client
+	discovery.RemoveMember("node2")
+	if err := node1.PublishRemote("node2", cproto.BuildClusterPacket("node1.player", "node2.room", "join")); err == nil {
+		t.Fatal("publish to the removed member")
+	}
+
+	if len(node1.clients) != 0 {
+		t.Fatalf("clients = %v", node1.clients)
+	}
+
+	// node2 restarts on a new address, the client reconnects to it
+	node2.Stop()
+
+	node2, app2 = newTestCluster(t, "node2", discovery)
+
+	if err := node1.PublishRemote("node2", cproto.BuildClusterPacket("node1.player", "node2.room", "rejoin")); err != nil {
+		t.Fatal(err)
+	}
+
+	if m := receive(t, app2); m.FuncName != "rejoin" {
+		t.Fatalf("message = %+v", m)
+	}
+}
+
+func TestClusterDialTimeout(t *testing.T) {
+	discovery := &testDiscovery{members: make(map[string]cfacade.IMember)}
+
+	node1, _ := newTestCluster(t, "node1", discovery)
+	node1.dialTimeout = 200 * time.Millisecond
+
+	// the member is registered but nothing listens on the address
+	discovery.AddMember(&cproto.Member{NodeId: "node2", Address: freeAddress(t)})
+
+	begin := time.Now()
+	if _, err := node1.getClient("node2"); err == nil {
+		t.Fatal("dial the unreachable node without error")
+	}
+
+	if elapsed := time.Since(begin); elapsed < 200*time.Millisecond || elapsed > 2*time.Second {
+		t.Fatalf("dial timeout is not applied. [elapsed = %v]", elapsed)
+	}
+
+	node1.clientLock.RLock()
+	defer node1.clientLock.RUnlock()
+	if _, found := node1.clients["node2"]; found {
+		t.Fatal("the client of the unreachable node is cached")
+	}
+}
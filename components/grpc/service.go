@@ -0,0 +1,81 @@
+package cherryGRPC
+
+import (
+	"context"
+
+	cproto "github.com/cherry-game/cherry/net/proto"
+	"google.golang.org/grpc"
+)
+
+// 集群服务定义(使用cherryProto中已有的ClusterPacket/Response消息)
+//
+//	service Cluster {
+//	  rpc PublishLocal(stream ClusterPacket) returns (Response);
+//	  rpc PublishRemote(stream ClusterPacket) returns (Response);
+//	  rpc RequestRemote(ClusterPacket) returns (Response);
+//	}
+const (
+	serviceName         = "cherryProto.Cluster"
+	publishLocalMethod  = "/" + serviceName + "/PublishLocal"
+	publishRemoteMethod = "/" + serviceName + "/PublishRemote"
+	requestRemoteMethod = "/" + serviceName + "/RequestRemote"
+)
+
+type clusterServer interface {
+	publishLocal(stream grpc.ServerStream) error
+	publishRemote(stream grpc.ServerStream) error
+	requestRemote(ctx context.Context, packet *cproto.ClusterPacket) (*cproto.Response, error)
+}
+
+var clusterServiceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*clusterServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "RequestRemote",
+			Handler:    requestRemoteHandler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "PublishLocal",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(clusterServer).publishLocal(stream)
+			},
+			ClientStreams: true,
+		},
+		{
+			StreamName: "PublishRemote",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(clusterServer).publishRemote(stream)
+			},
+			ClientStreams: true,
+		},
+	},
+}
+
+var publishStreamDesc = &grpc.StreamDesc{
+	ClientStreams: true,
+}
+
+func requestRemoteHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	packet := &cproto.ClusterPacket{}
+	if err := dec(packet); err != nil {
+		return nil, err
+	}
+
+	if interceptor == nil {
+		return srv.(clusterServer).requestRemote(ctx, packet)
+	}
+
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: requestRemoteMethod,
+	}
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(clusterServer).requestRemote(ctx, req.(*cproto.ClusterPacket))
+	}
+
+	return interceptor(ctx, packet, info, handler)
+}
@@ -51,6 +51,7 @@ var (
 	MessageWrongType     = Error("wrong message type")
 	MessageInvalid       = Error("invalid message")
 	MessageRouteNotFound = Error("route info not found in dictionary")
+	MessageRouteTooLong  = Error("route length exceeds 255 bytes")
 )
 
 var (
@@ -68,8 +69,26 @@ var (
 	NodeTypeIsNil          = Error("node type is nil.")
 )
 
+// client
 var (
-	ActorPathError = Error("actor path is error.")
+	ClientRequestTimeout   = Error("client request timeout")
+	ClientHeartbeatTimeout = Error("client heartbeat timeout")
+	ClientDisconnected     = Error("client disconnected")
+	ClientHandshakeFail    = Error("client handshake fail")
+	ClientVersionTooOld    = Error("client version is too old")
+	ClientServerFull       = Error("client server is full")
+	ClientClosing          = Error("client is closing")
+	ClientCloseTimeout     = Error("client close timeout")
+)
+
+var (
+	ActorPathError      = Error("actor path is error.")
+	ActorMessageNil     = Error("actor message is nil")
+	ActorNotFound       = Error("actor not found")
+	ActorNotRunning     = Error("actor is not running")
+	ActorFuncNotFound   = Error("actor function not found")
+	ActorFilterRejected = Error("actor message is rejected by the filter")
+	ActorInvokePanic    = Error("actor invoke panic")
 )
 
 var (
@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/ahmetb/go-linq/v3"
+	cherryDataConfig "github.com/cherry-game/cherry/components/data-config"
 	cerr "github.com/cherry-game/cherry/error"
 	"github.com/cherry-game/cherry/extend/mapstructure"
 )
@@ -54,6 +55,15 @@ func (d *DropConfigs) OnLoad(maps interface{}, reload bool) (int, error) {
 func (d *DropConfigs) OnAfterLoad(reload bool) {
 }
 
+// NewConfig 重载时加载到新实例,加载成功后再通过Swap替换list
+func (d *DropConfigs) NewConfig() cherryDataConfig.IConfig {
+	return &DropConfigs{}
+}
+
+func (d *DropConfigs) Swap(loaded cherryDataConfig.IConfig) {
+	d.list = loaded.(*DropConfigs).list
+}
+
 func (d *DropConfigs) Get(dropId int) *DropConfig {
 	for _, config := range d.list {
 		if config.DropId == dropId {
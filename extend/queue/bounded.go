@@ -0,0 +1,162 @@
+package cherryQueue
+
+import (
+	"sync"
+	"sync/atomic"
+
+	cerr "github.com/cherry-game/cherry/error"
+	clog "github.com/cherry-game/cherry/logger"
+)
+
+type OverflowPolicy int
+
+const (
+	Block      OverflowPolicy = iota // 队列满时阻塞等待
+	DropNewest                       // 队列满时丢弃新入队的消息
+	DropOldest                       // 队列满时丢弃最早入队的消息
+)
+
+const (
+	// dropLogSample 每丢弃dropLogSample条消息打印一次warn日志
+	dropLogSample = 1000
+)
+
+type (
+	// BoundedQueue 有容量限制的FIFO队列,容量满时按OverflowPolicy处理
+	//
+	// Push/Pop/Empty可以在多个goroutine中调用
+	BoundedQueue struct {
+		sync.Mutex
+		notFull *sync.Cond
+		list    []interface{}
+		head    int
+		size    int
+		policy  OverflowPolicy
+		drops   int64
+		name    string
+	}
+
+	BoundedOption func(q *BoundedQueue)
+)
+
+// WithOverflowPolicy 设置队列满时的处理策略,默认为Block
+func WithOverflowPolicy(policy OverflowPolicy) BoundedOption {
+	return func(q *BoundedQueue) {
+		q.policy = policy
+	}
+}
+
+// WithName 设置队列名称,用于日志输出
+func WithName(name string) BoundedOption {
+	return func(q *BoundedQueue) {
+		q.name = name
+	}
+}
+
+func NewBoundedQueue(capacity int, opts ...BoundedOption) *BoundedQueue {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	q := &BoundedQueue{
+		list:   make([]interface{}, capacity),
+		policy: Block,
+	}
+	q.notFull = sync.NewCond(&q.Mutex)
+
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	return q
+}
+
+// Push adds x to the back of the queue.
+//
+// 队列满时,Block策略阻塞等待;DropNewest丢弃x并返回false;DropOldest丢弃队首消息
+func (q *BoundedQueue) Push(x interface{}) bool {
+	q.Lock()
+	defer q.Unlock()
+
+	for q.size == len(q.list) {
+		switch q.policy {
+		case DropNewest:
+			q.drop()
+			return false
+		case DropOldest:
+			q.pop()
+			q.drop()
+		default:
+			q.notFull.Wait()
+		}
+	}
+
+	q.list[(q.head+q.size)%len(q.list)] = x
+	q.size++
+	return true
+}
+
+// Pop removes the item from the front of the queue or nil if the queue is empty
+func (q *BoundedQueue) Pop() interface{} {
+	q.Lock()
+	defer q.Unlock()
+
+	if q.size < 1 {
+		return nil
+	}
+
+	v := q.pop()
+	q.notFull.Signal()
+	return v
+}
+
+// Empty returns true if the queue is empty
+func (q *BoundedQueue) Empty() bool {
+	return q.Len() < 1
+}
+
+// Len 当前队列深度
+func (q *BoundedQueue) Len() int {
+	q.Lock()
+	defer q.Unlock()
+
+	return q.size
+}
+
+// Cap 队列容量
+func (q *BoundedQueue) Cap() int {
+	return len(q.list)
+}
+
+// Drops 累计丢弃的消息数量
+func (q *BoundedQueue) Drops() int64 {
+	return atomic.LoadInt64(&q.drops)
+}
+
+// HealthCheck 队列已满时返回错误
+func (q *BoundedQueue) HealthCheck() error {
+	if size, capacity := q.Len(), q.Cap(); size >= capacity {
+		return cerr.Errorf("[queue = %s] queue is full. [len = %d, cap = %d, drops = %d]", q.name, size, capacity, q.Drops())
+	}
+
+	return nil
+}
+
+func (q *BoundedQueue) pop() interface{} {
+	v := q.list[q.head]
+	q.list[q.head] = nil
+	q.head = (q.head + 1) % len(q.list)
+	q.size--
+	return v
+}
+
+func (q *BoundedQueue) drop() {
+	drops := atomic.AddInt64(&q.drops, 1)
+	if drops%dropLogSample == 1 {
+		clog.Warnf("[queue] Queue is full, message dropped. [name = %s, cap = %d, drops = %d]",
+			q.name,
+			len(q.list),
+			drops,
+		)
+	}
+}
@@ -0,0 +1,94 @@
+package cherryQueue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBoundedQueueDropNewest(t *testing.T) {
+	q := NewBoundedQueue(3, WithOverflowPolicy(DropNewest))
+	for i := 0; i < 5; i++ {
+		q.Push(i)
+	}
+
+	if q.Len() != 3 || q.Drops() != 2 {
+		t.Fatalf("len = %d, drops = %d", q.Len(), q.Drops())
+	}
+
+	for i := 0; i < 3; i++ {
+		if v := q.Pop(); v != i {
+			t.Fatalf("pop = %v, want %v", v, i)
+		}
+	}
+}
+
+func TestBoundedQueueDropOldest(t *testing.T) {
+	q := NewBoundedQueue(3, WithOverflowPolicy(DropOldest))
+	for i := 0; i < 5; i++ {
+		q.Push(i)
+	}
+
+	if q.Len() != 3 || q.Drops() != 2 {
+		t.Fatalf("len = %d, drops = %d", q.Len(), q.Drops())
+	}
+
+	for i := 2; i < 5; i++ {
+		if v := q.Pop(); v != i {
+			t.Fatalf("pop = %v, want %v", v, i)
+		}
+	}
+
+	if !q.Empty() {
+		t.Fatal("queue should be empty")
+	}
+}
+
+func TestBoundedQueueBlock(t *testing.T) {
+	q := NewBoundedQueue(1)
+	q.Push(1)
+
+	done := make(chan struct{})
+	go func() {
+		q.Push(2)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("push should block when queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if v := q.Pop(); v != 1 {
+		t.Fatalf("pop = %v, want 1", v)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("push should be unblocked after pop")
+	}
+
+	if v := q.Pop(); v != 2 {
+		t.Fatalf("pop = %v, want 2", v)
+	}
+}
+
+func TestBoundedQueueHealthCheck(t *testing.T) {
+	q := NewBoundedQueue(2, WithOverflowPolicy(DropNewest), WithName("test"))
+	q.Push(1)
+
+	if err := q.HealthCheck(); err != nil {
+		t.Fatal(err)
+	}
+
+	q.Push(2)
+	if err := q.HealthCheck(); err == nil {
+		t.Fatal("expected queue full error")
+	}
+
+	q.Pop()
+	if err := q.HealthCheck(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,140 @@
+package cherryQueue
+
+import (
+	"container/heap"
+	"sync"
+)
+
+const (
+	// DefaultAging 默认老化窗口,低优先级消息最多被后续入队的(优先级差*DefaultAging)条高优先级消息插队
+	DefaultAging int64 = 1024
+)
+
+type (
+	// PriorityQueue 优先级队列,priority越大越先出队,相同优先级按FIFO出队
+	//
+	// Push/Pop/Empty可以在多个goroutine中调用
+	PriorityQueue struct {
+		sync.Mutex
+		items priorityItems
+		seq   int64
+		aging int64
+	}
+
+	PriorityOption func(q *PriorityQueue)
+
+	priorityItem struct {
+		val      interface{}
+		priority int
+		seq      int64
+	}
+
+	priorityItems struct {
+		list  []*priorityItem
+		aging int64
+	}
+)
+
+// WithAging 设置老化窗口,防止低优先级消息饥饿,aging<=0时按严格优先级出队
+func WithAging(aging int64) PriorityOption {
+	return func(q *PriorityQueue) {
+		q.aging = aging
+	}
+}
+
+func NewPriorityQueue(opts ...PriorityOption) *PriorityQueue {
+	q := &PriorityQueue{
+		aging: DefaultAging,
+	}
+
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	q.items.aging = q.aging
+	return q
+}
+
+// Push adds x to the queue with priority 0
+func (q *PriorityQueue) Push(x interface{}) {
+	q.PushPriority(x, 0)
+}
+
+// PushPriority adds x to the queue with the given priority
+func (q *PriorityQueue) PushPriority(x interface{}, priority int) {
+	q.Lock()
+	defer q.Unlock()
+
+	q.seq++
+	heap.Push(&q.items, &priorityItem{
+		val:      x,
+		priority: priority,
+		seq:      q.seq,
+	})
+}
+
+// Pop removes the item with the highest priority or nil if the queue is empty
+func (q *PriorityQueue) Pop() interface{} {
+	q.Lock()
+	defer q.Unlock()
+
+	if len(q.items.list) < 1 {
+		return nil
+	}
+
+	item := heap.Pop(&q.items).(*priorityItem)
+	return item.val
+}
+
+// Empty returns true if the queue is empty
+func (q *PriorityQueue) Empty() bool {
+	q.Lock()
+	defer q.Unlock()
+
+	return len(q.items.list) < 1
+}
+
+// Len returns the number of items in the queue
+func (q *PriorityQueue) Len() int {
+	q.Lock()
+	defer q.Unlock()
+
+	return len(q.items.list)
+}
+
+func (p *priorityItems) Len() int {
+	return len(p.list)
+}
+
+func (p *priorityItems) Less(i, j int) bool {
+	a, b := p.list[i], p.list[j]
+
+	if p.aging > 0 {
+		// 入队序号减去优先级权重,越小越先出队
+		ka := a.seq - int64(a.priority)*p.aging
+		kb := b.seq - int64(b.priority)*p.aging
+		if ka != kb {
+			return ka < kb
+		}
+	} else if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+
+	return a.seq < b.seq
+}
+
+func (p *priorityItems) Swap(i, j int) {
+	p.list[i], p.list[j] = p.list[j], p.list[i]
+}
+
+func (p *priorityItems) Push(x interface{}) {
+	p.list = append(p.list, x.(*priorityItem))
+}
+
+func (p *priorityItems) Pop() interface{} {
+	n := len(p.list)
+	item := p.list[n-1]
+	p.list[n-1] = nil
+	p.list = p.list[:n-1]
+	return item
+}
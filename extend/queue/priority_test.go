@@ -0,0 +1,64 @@
+package cherryQueue
+
+import (
+	"testing"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	q := NewPriorityQueue()
+	q.PushPriority("chat1", 0)
+	q.PushPriority("combat1", 10)
+	q.PushPriority("chat2", 0)
+	q.PushPriority("combat2", 10)
+	q.PushPriority("move", 5)
+
+	want := []string{"combat1", "combat2", "move", "chat1", "chat2"}
+	for _, w := range want {
+		if v := q.Pop(); v != w {
+			t.Fatalf("pop = %v, want %v", v, w)
+		}
+	}
+
+	if !q.Empty() || q.Pop() != nil {
+		t.Fatal("queue should be empty")
+	}
+}
+
+func TestPriorityQueueAging(t *testing.T) {
+	q := NewPriorityQueue(WithAging(3))
+	q.PushPriority("low", 0)
+
+	// 持续入队高优先级消息,低优先级消息最多被插队3次
+	popped := 0
+	for i := 0; i < 10; i++ {
+		q.PushPriority(i, 1)
+		v := q.Pop()
+		popped++
+		if v == "low" {
+			break
+		}
+	}
+
+	if popped > 4 {
+		t.Fatalf("low priority item starved. popped = %d", popped)
+	}
+}
+
+func TestPriorityQueueStrict(t *testing.T) {
+	q := NewPriorityQueue(WithAging(0))
+	q.PushPriority("low", 0)
+
+	for i := 0; i < 100; i++ {
+		q.PushPriority(i, 1)
+	}
+
+	for i := 0; i < 100; i++ {
+		if v := q.Pop(); v != i {
+			t.Fatalf("pop = %v, want %v", v, i)
+		}
+	}
+
+	if v := q.Pop(); v != "low" {
+		t.Fatalf("pop = %v, want low", v)
+	}
+}
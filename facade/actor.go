@@ -12,6 +12,8 @@ type (
 		CreateActor(id string, handler IActorHandler) (IActor, error)
 		PostRemote(m *Message) bool
 		PostLocal(m *Message) bool
+		TryPostRemote(m *Message) error // 返回投递失败的原因
+		TryPostLocal(m *Message) error  // 返回投递失败的原因
 		PostEvent(data IEventData)
 		Call(source, target, funcName string, arg interface{}) int32
 		CallWait(source, target, funcName string, arg interface{}, reply interface{}) int32
@@ -33,6 +33,7 @@ type (
 		Discovery() IDiscovery             // 发现服务
 		Cluster() ICluster                 // 集群服务
 		ActorSystem() IActorSystem         // actor系统
+		Health() map[string]error          // 实现了IHealthCheck的组件健康状态
 	}
 
 	// ProfileJSON profile配置文件读取接口
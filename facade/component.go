@@ -14,6 +14,16 @@ type (
 		OnBeforeStop()
 		OnStop()
 	}
+
+	// IComponentDepends 组件可选实现该接口声明依赖的组件名称,启动时依赖的组件先执行Init()
+	IComponentDepends interface {
+		Depends() []string
+	}
+
+	// IHealthCheck 组件可选实现该接口报告健康状态,返回nil表示健康
+	IHealthCheck interface {
+		HealthCheck() error
+	}
 )
 
 // Component base component
@@ -1,6 +1,7 @@
 package cherryFacade
 
 import (
+	"context"
 	"strings"
 	"sync"
 	"time"
@@ -25,6 +26,7 @@ type (
 		ClusterReply IRespond         // 返回消息的接口
 		IsCluster    bool             // 是否为集群消息
 		ChanResult   chan interface{} //
+		ctx          context.Context  // 执行函数的上下文,带有执行超时的deadline
 	}
 
 	IRespond interface {
@@ -70,6 +72,7 @@ func (p *Message) Recycle() {
 	p.Target = ""
 	p.targetPath = nil
 	p.FuncName = ""
+	p.Session = nil
 	p.Args = nil
 	p.Err = nil
 	p.ClusterReply = nil
@@ -77,9 +80,22 @@ func (p *Message) Recycle() {
 	p.ChanResult = nil
 	p.BuildTime = 0
 	p.PostTime = 0
+	p.ctx = nil
 	messagePool.Put(p)
 }
 
+// Context 返回执行函数的上下文,actor执行函数前设置(见System.SetHandlerTimeout),未设置时返回context.Background()
+func (p *Message) Context() context.Context {
+	if p.ctx == nil {
+		return context.Background()
+	}
+	return p.ctx
+}
+
+func (p *Message) SetContext(ctx context.Context) {
+	p.ctx = ctx
+}
+
 func (p *Message) TargetPath() *ActorPath {
 	if p.targetPath == nil {
 		p.targetPath, _ = ToActorPath(p.Target)
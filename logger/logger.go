@@ -19,8 +19,16 @@ var (
 	loggers       map[string]*CherryLogger // 日志实例存储map(key:日志名称,value:日志实例)
 	nodeId        string                   // current node id
 	printLevel    zapcore.Level            // cherry log print level
+	named         = &namedLoggers{}        // 默认日志对象的子日志对象
 )
 
+// namedLoggers 缓存默认日志对象的子日志对象,默认日志对象被替换(如SetNodeLogger)后重新创建
+type namedLoggers struct {
+	sync.Mutex
+	parent  *CherryLogger
+	loggers map[string]*CherryLogger
+}
+
 func init() {
 	DefaultLogger = NewConfigLogger(defaultConsoleConfig(), zap.AddCallerSkip(1))
 	loggers = make(map[string]*CherryLogger)
@@ -29,12 +37,89 @@ func init() {
 type CherryLogger struct {
 	*zap.SugaredLogger
 	*Config
+	encoder zapcore.Encoder
+	writer  zapcore.WriteSyncer
+	opts    []zap.Option
 }
 
 func (c *CherryLogger) Print(v ...interface{}) {
 	c.Warn(v)
 }
 
+// Named 返回指定名称的子日志对象,如果配置了levels[name]则使用该日志等级,否则使用当前日志等级
+func (c *CherryLogger) Named(name string) *CherryLogger {
+	logger := &CherryLogger{
+		Config:  c.Config,
+		encoder: c.encoder,
+		writer:  c.writer,
+		opts:    c.opts,
+	}
+
+	if level, found := c.level(name); found {
+		logger.SugaredLogger = logger.newSugaredLogger(level).Named(name)
+	} else {
+		logger.SugaredLogger = c.SugaredLogger.Named(name)
+	}
+
+	return logger
+}
+
+// level 返回levels中配置的日志等级,未通过配置创建的日志对象(如测试中替换的DefaultLogger)没有该配置
+func (c *CherryLogger) level(name string) (string, bool) {
+	if c.Config == nil || c.encoder == nil {
+		return "", false
+	}
+
+	level, found := c.Levels[name]
+	return level, found
+}
+
+// Named 返回默认日志对象的子日志对象,用于组件按名称设置日志等级
+// 子日志对象按名称缓存,可以在每次打印日志时调用;DefaultLogger被替换后使用新的DefaultLogger重新创建
+func Named(name string) *CherryLogger {
+	named.Lock()
+	defer named.Unlock()
+
+	if named.parent != DefaultLogger {
+		named.parent = DefaultLogger
+		named.loggers = make(map[string]*CherryLogger)
+	}
+
+	if logger, found := named.loggers[name]; found {
+		return logger
+	}
+
+	logger := DefaultLogger.Named(name)
+	// DefaultLogger被包级函数包装调用,直接调用时需要还原caller skip
+	logger.SugaredLogger = logger.Desugar().WithOptions(zap.AddCallerSkip(-1)).Sugar()
+	named.loggers[name] = logger
+	return logger
+}
+
+// WithFields 返回附加了结构化字段的日志对象,json格式输出时字段与ts,level,msg同级
+func (c *CherryLogger) WithFields(fields map[string]interface{}) *CherryLogger {
+	args := make([]interface{}, 0, len(fields)*2)
+	for k, v := range fields {
+		args = append(args, k, v)
+	}
+
+	return &CherryLogger{
+		SugaredLogger: c.SugaredLogger.With(args...),
+		Config:        c.Config,
+		encoder:       c.encoder,
+		writer:        c.writer,
+		opts:          c.opts,
+	}
+}
+
+// WithFields 返回附加了结构化字段的默认日志对象
+func WithFields(fields map[string]interface{}) *CherryLogger {
+	// DefaultLogger被包级函数包装调用,直接调用时需要还原caller skip
+	logger := DefaultLogger.WithFields(fields)
+	logger.SugaredLogger = logger.Desugar().WithOptions(zap.AddCallerSkip(-1)).Sugar()
+	return logger
+}
+
 func SetNodeLogger(node cfacade.INode) {
 	nodeId = node.NodeId()
 	refLogger := node.Settings().Get("ref_logger").ToString()
@@ -97,16 +182,23 @@ func NewConfigLogger(config *Config, opts ...zap.Option) *CherryLogger {
 		EncodeCaller:   zapcore.ShortCallerEncoder,
 	}
 
-	encoderConfig.EncodeLevel = func(level zapcore.Level, encoder zapcore.PrimitiveArrayEncoder) {
-		if nodeId != "" {
-			encoder.AppendString(fmt.Sprintf("%s  %-5s", nodeId, level.CapitalString()))
-		} else {
-			encoder.AppendString(level.CapitalString())
+	if config.IsJSON() {
+		encoderConfig.EncodeLevel = zapcore.LowercaseLevelEncoder
+		encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	} else {
+		encoderConfig.EncodeLevel = func(level zapcore.Level, encoder zapcore.PrimitiveArrayEncoder) {
+			if nodeId != "" {
+				encoder.AppendString(fmt.Sprintf("%s  %-5s", nodeId, level.CapitalString()))
+			} else {
+				encoder.AppendString(level.CapitalString())
+			}
 		}
 	}
 
 	if config.PrintCaller {
-		encoderConfig.EncodeTime = config.TimeEncoder()
+		if !config.IsJSON() {
+			encoderConfig.EncodeTime = config.TimeEncoder()
+		}
 		encoderConfig.EncodeName = zapcore.FullNameEncoder
 		encoderConfig.FunctionKey = zapcore.OmitKey
 		opts = append(opts, zap.AddCaller())
@@ -122,6 +214,9 @@ func NewConfigLogger(config *Config, opts ...zap.Option) *CherryLogger {
 			rotatelogs.WithLinkName(config.FileLinkPath),
 			rotatelogs.WithMaxAge(time.Hour*24*time.Duration(config.MaxAge)),
 			rotatelogs.WithRotationTime(time.Second*time.Duration(config.RotationTime)),
+			rotatelogs.WithRotationSize(int64(config.MaxSize)*1024*1024),
+			rotatelogs.WithRotationCount(uint(config.MaxBackups)),
+			rotatelogs.WithCompress(config.Compress),
 		)
 
 		if err != nil {
@@ -143,20 +238,39 @@ func NewConfigLogger(config *Config, opts ...zap.Option) *CherryLogger {
 		writers = append(writers, zapcore.Lock(os.Stderr))
 	}
 
-	core := zapcore.NewCore(
-		zapcore.NewConsoleEncoder(encoderConfig),
-		zapcore.AddSync(zapcore.NewMultiWriteSyncer(writers...)),
-		zap.NewAtomicLevelAt(GetLevel(config.LogLevel)),
-	)
+	var encoder zapcore.Encoder
+	if config.IsJSON() {
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	} else {
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	}
 
 	cherryLogger := &CherryLogger{
-		SugaredLogger: NewSugaredLogger(core, opts...),
-		Config:        config,
+		Config:  config,
+		encoder: encoder,
+		writer:  zapcore.AddSync(zapcore.NewMultiWriteSyncer(writers...)),
+		opts:    opts,
 	}
+	cherryLogger.SugaredLogger = cherryLogger.newSugaredLogger(config.LogLevel)
 
 	return cherryLogger
 }
 
+func (c *CherryLogger) newSugaredLogger(level string) *zap.SugaredLogger {
+	core := zapcore.NewCore(
+		c.encoder,
+		c.writer,
+		zap.NewAtomicLevelAt(GetLevel(level)),
+	)
+
+	sugaredLogger := NewSugaredLogger(core, c.opts...)
+	if c.IsJSON() && nodeId != "" {
+		sugaredLogger = sugaredLogger.With("node_id", nodeId)
+	}
+
+	return sugaredLogger
+}
+
 func NewSugaredLogger(core zapcore.Core, opts ...zap.Option) *zap.SugaredLogger {
 	zapLogger := zap.New(core, opts...)
 	return zapLogger.Sugar()
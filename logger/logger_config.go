@@ -9,20 +9,30 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
+const (
+	FormatConsole = "console" // 文本格式输出
+	FormatJSON    = "json"    // json格式输出 {ts, level, msg, fields...}
+)
+
 type (
 	Config struct {
-		LogLevel        string `json:"level"`             // 输出日志等级
-		StackLevel      string `json:"stack_level"`       // 堆栈输出日志等级
-		EnableConsole   bool   `json:"enable_console"`    // 是否控制台输出
-		EnableWriteFile bool   `json:"enable_write_file"` // 是否输出文件(必需配置FilePath)
-		MaxAge          int    `json:"max_age"`           // 最大保留天数(达到限制，则会被清理)
-		TimeFormat      string `json:"time_format"`       // 打印时间输出格式
-		PrintCaller     bool   `json:"print_caller"`      // 是否打印调用函数
-		RotationTime    int    `json:"rotation_time"`     // 日期分割时间(秒)
-		FileLinkPath    string `json:"file_link_path"`    // 日志文件连接路径
-		FilePathFormat  string `json:"file_path_format"`  // 日志文件路径格式
-		IncludeStdout   bool   `json:"include_stdout"`    // 是否包含os.stdout输出
-		IncludeStderr   bool   `json:"include_stderr"`    // 是否包含os.stderr输出
+		LogLevel        string            `json:"level"`             // 输出日志等级
+		StackLevel      string            `json:"stack_level"`       // 堆栈输出日志等级
+		EnableConsole   bool              `json:"enable_console"`    // 是否控制台输出
+		EnableWriteFile bool              `json:"enable_write_file"` // 是否输出文件(必需配置FilePath)
+		MaxAge          int               `json:"max_age"`           // 最大保留天数(达到限制，则会被清理)
+		TimeFormat      string            `json:"time_format"`       // 打印时间输出格式
+		PrintCaller     bool              `json:"print_caller"`      // 是否打印调用函数
+		RotationTime    int               `json:"rotation_time"`     // 日期分割时间(秒)
+		FileLinkPath    string            `json:"file_link_path"`    // 日志文件连接路径
+		FilePathFormat  string            `json:"file_path_format"`  // 日志文件路径格式
+		IncludeStdout   bool              `json:"include_stdout"`    // 是否包含os.stdout输出
+		IncludeStderr   bool              `json:"include_stderr"`    // 是否包含os.stderr输出
+		Format          string            `json:"format"`            // 输出格式(console:文本格式,json:json格式)
+		MaxSize         int               `json:"max_size"`          // 单个日志文件最大大小(MB),超过则分割,0为不限制
+		MaxBackups      int               `json:"max_backups"`       // 最多保留的日志文件数量,0为不限制
+		Compress        bool              `json:"compress"`          // 是否gzip压缩已分割的日志文件
+		Levels          map[string]string `json:"levels"`            // 按名称设置子日志对象的输出等级(key:名称如data_config、pomelo_client,value:日志等级)
 	}
 )
 
@@ -40,6 +50,7 @@ func defaultConsoleConfig() *Config {
 		FilePathFormat:  "logs/log_%Y%m%d%H%M.log",
 		IncludeStdout:   false,
 		IncludeStderr:   false,
+		Format:          FormatConsole,
 	}
 	return config
 }
@@ -62,6 +73,14 @@ func NewConfig(jsonConfig cfacade.ProfileJSON) *Config {
 	config.FilePathFormat = jsonConfig.GetString("file_path_format", defaultFilePath)
 	config.IncludeStdout = jsonConfig.GetBool("include_stdout", false)
 	config.IncludeStderr = jsonConfig.GetBool("include_stderr", false)
+	config.Format = jsonConfig.GetString("format", FormatConsole)
+	config.MaxSize = jsonConfig.GetInt("max_size", 0)
+	config.MaxBackups = jsonConfig.GetInt("max_backups", 0)
+	config.Compress = jsonConfig.GetBool("compress", false)
+
+	if levels := jsonConfig.GetConfig("levels"); levels.LastError() == nil {
+		_ = levels.Unmarshal(&config.Levels)
+	}
 
 	return config
 }
@@ -90,3 +109,7 @@ func GetLevel(level string) zapcore.Level {
 		return zapcore.DebugLevel
 	}
 }
+
+func (c *Config) IsJSON() bool {
+	return strings.ToLower(c.Format) == FormatJSON
+}
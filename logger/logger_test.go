@@ -1,9 +1,16 @@
 package cherryLogger
 
 import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	ctime "github.com/cherry-game/cherry/extend/time"
+	"github.com/cherry-game/cherry/logger/rotatelogs"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func BenchmarkWrite(b *testing.B) {
@@ -19,3 +26,115 @@ func BenchmarkWrite(b *testing.B) {
 		log1.Debug(ctime.Now().ToDateTimeFormat())
 	}
 }
+
+func TestRotationSize(t *testing.T) {
+	dir := t.TempDir()
+
+	hook, err := rotatelogs.New(
+		filepath.Join(dir, "log_%Y%m%d.log"),
+		rotatelogs.WithRotationSize(1024),
+		rotatelogs.WithRotationCount(3),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+
+	line := []byte(strings.Repeat("a", 100) + "\n")
+	for i := 0; i < 30; i++ {
+		if _, err = hook.Write(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !strings.HasSuffix(hook.CurrentFileName(), ".1") && !strings.HasSuffix(hook.CurrentFileName(), ".2") {
+		t.Fatalf("file not rotated. current = %s", hook.CurrentFileName())
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "log_*"))
+	if len(matches) < 2 {
+		t.Fatalf("rollover file not found. files = %v", matches)
+	}
+}
+
+func TestNamedDefaultLogger(t *testing.T) {
+	defaultLogger := DefaultLogger
+	defer func() {
+		DefaultLogger = defaultLogger
+	}()
+
+	config := defaultConsoleConfig()
+	config.LogLevel = "info"
+	config.Levels = map[string]string{
+		"data_config": "debug",
+	}
+	DefaultLogger = NewConfigLogger(config)
+
+	logger := Named("data_config")
+	if logger != Named("data_config") || !logger.Desugar().Core().Enabled(zapcore.DebugLevel) {
+		t.Fatal("named logger should be cached with the debug level")
+	}
+
+	// the named logger is created again after the default logger is replaced, such as SetNodeLogger
+	core, logs := observer.New(zapcore.InfoLevel)
+	DefaultLogger = &CherryLogger{SugaredLogger: NewSugaredLogger(core)}
+
+	Named("data_config").Info("loaded")
+	if entries := logs.All(); len(entries) != 1 || entries[0].LoggerName != "data_config" {
+		t.Fatalf("entries = %v", entries)
+	}
+}
+
+func TestWithFieldsJSON(t *testing.T) {
+	config := defaultConsoleConfig()
+	config.EnableConsole = false
+	config.Format = FormatJSON
+
+	var buf bytes.Buffer
+	logger := NewConfigLogger(config)
+	logger.writer = zapcore.AddSync(&buf)
+	logger.SugaredLogger = logger.newSugaredLogger(config.LogLevel)
+
+	logger.WithFields(map[string]interface{}{
+		"uid":   1001,
+		"route": "game.player.login",
+	}).Info("player login")
+
+	line, err := buf.ReadBytes('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entry map[string]interface{}
+	if err = json.Unmarshal(line, &entry); err != nil {
+		t.Fatalf("line = %s, err = %v", line, err)
+	}
+
+	// the custom fields are at the same level as ts, level and msg
+	if _, found := entry["ts"]; !found || entry["level"] != "info" || entry["msg"] != "player login" ||
+		entry["uid"] != float64(1001) || entry["route"] != "game.player.login" {
+		t.Fatalf("entry = %v", entry)
+	}
+}
+
+func TestNamedLevel(t *testing.T) {
+	config := defaultConsoleConfig()
+	config.LogLevel = "info"
+	config.Levels = map[string]string{
+		"client": "debug",
+	}
+
+	logger := NewConfigLogger(config)
+
+	if logger.Desugar().Core().Enabled(zapcore.DebugLevel) {
+		t.Fatal("debug level should be disabled")
+	}
+
+	if !logger.Named("client").Desugar().Core().Enabled(zapcore.DebugLevel) {
+		t.Fatal("client debug level should be enabled")
+	}
+
+	if logger.Named("data_config").Desugar().Core().Enabled(zapcore.DebugLevel) {
+		t.Fatal("data_config debug level should be disabled")
+	}
+}
@@ -15,6 +15,7 @@ const (
 	optkeyRotationSize  = "rotation-size"
 	optkeyRotationCount = "rotation-count"
 	optkeyForceNewFile  = "force-new-file"
+	optkeyCompress      = "compress"
 )
 
 // WithClock creates a new Option that sets a clock
@@ -87,3 +88,10 @@ func WithHandler(h Handler) Option {
 func ForceNewFile() Option {
 	return option.New(optkeyForceNewFile, true)
 }
+
+// WithCompress creates a new Option that gzips the rotated
+// log files. The original file is removed after it has been
+// compressed to "<filename>.gz"
+func WithCompress(b bool) Option {
+	return option.New(optkeyCompress, b)
+}
@@ -5,11 +5,13 @@
 package rotatelogs
 
 import (
+	"compress/gzip"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -43,6 +45,7 @@ func New(p string, options ...Option) (*RotateLogs, error) {
 	var maxAge time.Duration
 	var handler Handler
 	var forceNewFile bool
+	var compress bool
 
 	for _, o := range options {
 		switch o.Name() {
@@ -71,13 +74,11 @@ func New(p string, options ...Option) (*RotateLogs, error) {
 			handler = o.Value().(Handler)
 		case optkeyForceNewFile:
 			forceNewFile = true
+		case optkeyCompress:
+			compress = o.Value().(bool)
 		}
 	}
 
-	if maxAge > 0 && rotationCount > 0 {
-		return nil, cerror.Error("options MaxAge and RotationCount cannot be both set")
-	}
-
 	if maxAge == 0 && rotationCount == 0 {
 		// if both are 0, give maxAge a sane default
 		maxAge = 7 * 24 * time.Hour
@@ -94,6 +95,7 @@ func New(p string, options ...Option) (*RotateLogs, error) {
 		rotationSize:  rotationSize,
 		rotationCount: rotationCount,
 		forceNewFile:  forceNewFile,
+		compress:      compress,
 	}, nil
 }
 
@@ -170,6 +172,11 @@ func (rl *RotateLogs) getWriterNoLock(bailOnRotateFail, useGenerationalNames boo
 		forceNewFile = true
 		generation++
 	}
+	// the archive of a previous file with the same name exists (such as
+	// after a restart), don't reuse the name or the archive is overwritten
+	if !forceNewFile && rl.archiveExists(filename) {
+		forceNewFile = true
+	}
 	if forceNewFile {
 		// A new file has been requested. Instead of just using the
 		// regular strftime pattern, we create a new file name using
@@ -181,7 +188,7 @@ func (rl *RotateLogs) getWriterNoLock(bailOnRotateFail, useGenerationalNames boo
 			} else {
 				name = fmt.Sprintf("%s.%d", filename, generation)
 			}
-			if _, err := os.Stat(name); err != nil {
+			if _, err := os.Stat(name); err != nil && !rl.archiveExists(name) {
 				filename = name
 				break
 			}
@@ -224,6 +231,10 @@ func (rl *RotateLogs) getWriterNoLock(bailOnRotateFail, useGenerationalNames boo
 	rl.curFn = filename
 	rl.generation = generation
 
+	if rl.compress && previousFn != "" && previousFn != filename {
+		go compressFile(previousFn)
+	}
+
 	if h := rl.eventHandler; h != nil {
 		go h.Handle(&FileRotatedEvent{
 			prev:    previousFn,
@@ -233,6 +244,16 @@ func (rl *RotateLogs) getWriterNoLock(bailOnRotateFail, useGenerationalNames boo
 	return fh, nil
 }
 
+// archiveExists reports whether the compressed archive of the file exists
+func (rl *RotateLogs) archiveExists(filename string) bool {
+	if !rl.compress {
+		return false
+	}
+
+	_, err := os.Stat(filename + ".gz")
+	return err == nil
+}
+
 // CurrentFileName returns the current file name that
 // the RotateLogs object is writing to
 func (rl *RotateLogs) CurrentFileName() string {
@@ -333,46 +354,49 @@ func (rl *RotateLogs) rotateNoLock(filename string) error {
 		return cerror.Error("panic: maxAge and rotationCount are both set")
 	}
 
-	matches, err := filepath.Glob(rl.globPattern)
+	// 包含分代文件(foo.1)和压缩文件(foo.gz)
+	matches, err := filepath.Glob(rl.globPattern + "*")
 	if err != nil {
 		return err
 	}
 
 	cutoff := rl.clock.Now().Add(-1 * rl.maxAge)
 	var toUnlink []string
+	var backups []os.FileInfo
+	backupPaths := make(map[os.FileInfo]string)
 	for _, path := range matches {
 		// Ignore lock files
 		if strings.HasSuffix(path, "_lock") || strings.HasSuffix(path, "_symlink") {
 			continue
 		}
 
-		fi, err := os.Stat(path)
-		if err != nil {
+		if path == filename {
 			continue
 		}
 
 		fl, err := os.Lstat(path)
-		if err != nil {
+		if err != nil || fl.Mode()&os.ModeSymlink == os.ModeSymlink {
 			continue
 		}
 
-		if rl.maxAge > 0 && fi.ModTime().After(cutoff) {
+		if rl.maxAge > 0 && fl.ModTime().Before(cutoff) {
+			toUnlink = append(toUnlink, path)
 			continue
 		}
 
-		if rl.rotationCount > 0 && fl.Mode()&os.ModeSymlink == os.ModeSymlink {
-			continue
-		}
-		toUnlink = append(toUnlink, path)
+		backups = append(backups, fl)
+		backupPaths[fl] = path
 	}
 
-	if rl.rotationCount > 0 {
-		// Only delete if we have more than rotationCount
-		if rl.rotationCount >= uint(len(toUnlink)) {
-			return nil
-		}
+	if rl.rotationCount > 0 && uint(len(backups)) > rl.rotationCount {
+		// Only keep the newest rotationCount backups
+		sort.Slice(backups, func(i, j int) bool {
+			return backups[i].ModTime().Before(backups[j].ModTime())
+		})
 
-		toUnlink = toUnlink[:len(toUnlink)-int(rl.rotationCount)]
+		for _, fi := range backups[:len(backups)-int(rl.rotationCount)] {
+			toUnlink = append(toUnlink, backupPaths[fi])
+		}
 	}
 
 	if len(toUnlink) <= 0 {
@@ -390,6 +414,53 @@ func (rl *RotateLogs) rotateNoLock(filename string) error {
 	return nil
 }
 
+// compressFile gzips the rotated file and removes the original file
+func compressFile(filename string) {
+	if strings.HasSuffix(filename, ".gz") {
+		return
+	}
+
+	if err := gzipFile(filename); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to compress %s: %s\n", filename, err.Error())
+		return
+	}
+
+	os.Remove(filename)
+}
+
+func gzipFile(filename string) error {
+	src, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// never overwrite an existing archive, the original file is kept on error
+	dst, err := os.OpenFile(filename+".gz", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	gw := gzip.NewWriter(dst)
+	_, err = io.Copy(gw, src)
+	if err == nil {
+		err = gw.Close()
+	}
+
+	// the archive is complete only if it is flushed to the disk by Close,
+	// otherwise remove the partial archive so that the original file is not removed
+	if e := dst.Close(); err == nil {
+		err = e
+	}
+
+	if err != nil {
+		os.Remove(filename + ".gz")
+		return err
+	}
+
+	return nil
+}
+
 // Close satisfies the io.Closer interface. You must
 // call this method if you performed any writes to
 // the object.
@@ -0,0 +1,111 @@
+package rotatelogs
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompressNotOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	pattern := filepath.Join(dir, "app.log")
+
+	// rotate once in each run, the second run starts with the same file name as the first one
+	for i, content := range []string{"first", "second"} {
+		rl, err := New(pattern, WithCompress(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = rl.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = rl.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+
+		_ = rl.Close()
+
+		waitArchives(t, dir, i+1)
+	}
+
+	archives, _ := filepath.Glob(filepath.Join(dir, "*.gz"))
+	sort.Strings(archives)
+
+	var contents []string
+	for _, archive := range archives {
+		contents = append(contents, readArchive(t, archive))
+	}
+	sort.Strings(contents)
+
+	if len(contents) != 2 || contents[0] != "first" || contents[1] != "second" {
+		t.Fatalf("archives = %v, contents = %v", archives, contents)
+	}
+}
+
+func TestCompressFail(t *testing.T) {
+	// read the directory fails after the archive is created
+	filename := filepath.Join(t.TempDir(), "app.log")
+	if err := os.Mkdir(filename, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	compressFile(filename)
+
+	if _, err := os.Stat(filename + ".gz"); !os.IsNotExist(err) {
+		t.Fatalf("the partial archive is kept. [err = %v]", err)
+	}
+
+	if _, err := os.Stat(filename); err != nil {
+		t.Fatalf("the original file is removed. [err = %v]", err)
+	}
+}
+
+// waitArchives wait for the compress goroutines, the original file is removed after compressed
+func waitArchives(t *testing.T, dir string, count int) {
+	deadline := time.Now().Add(time.Second)
+	for {
+		archives, _ := filepath.Glob(filepath.Join(dir, "*.gz"))
+
+		compressed := 0
+		for _, archive := range archives {
+			if _, err := os.Stat(strings.TrimSuffix(archive, ".gz")); os.IsNotExist(err) {
+				compressed++
+			}
+		}
+
+		if compressed >= count {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("archives = %v, want %d", archives, count)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func readArchive(t *testing.T, filename string) string {
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := io.ReadAll(gr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -1,9 +1,14 @@
 package cherryActor
 
 import (
+	"context"
+	"errors"
+	"runtime/debug"
 	"strings"
 	"time"
 
+	ccode "github.com/cherry-game/cherry/code"
+	cerr "github.com/cherry-game/cherry/error"
 	cutils "github.com/cherry-game/cherry/extend/utils"
 	cfacade "github.com/cherry-game/cherry/facade"
 	clog "github.com/cherry-game/cherry/logger"
@@ -50,6 +55,12 @@ type (
 		lastAt           int64                 // last process time
 		arrivalElapsed   int64                 // arrival elapsed for message
 		executionElapsed int64                 // execution elapsed for message
+		invoking         *invokeContext        // context of the executing message
+	}
+
+	// invokeContext 正在执行的消息上下文,Base加载的Actor副本共享该指针
+	invokeContext struct {
+		ctx context.Context
 	}
 )
 
@@ -120,6 +131,7 @@ func (p *Actor) processLocal() {
 				childActor.PostLocal(m)
 			} else {
 				clog.Warnf("Child actor not found. path = %s", m.Target)
+				p.system.unhandled(m)
 			}
 		}
 	} else {
@@ -152,6 +164,7 @@ func (p *Actor) processRemote() {
 				childActor.PostRemote(m)
 			} else {
 				clog.Warnf("Child actor not found. path = %s", m.Target)
+				p.system.unhandled(m)
 			}
 		}
 	} else {
@@ -169,17 +182,21 @@ func (p *Actor) processEvent() {
 	p.event.funcInvoke(eventData)
 }
 
-func (p *Actor) invokeFunc(mb *mailbox, app cfacade.IApplication, fn cfacade.InvokeFunc, m *cfacade.Message) {
+// invokeFunc 执行消息对应的函数,返回执行结果
+// cerr.ActorFuncNotFound: 函数未注册, cerr.ActorFilterRejected: 被before过滤器拦截, cerr.ActorInvokePanic: 执行时panic
+func (p *Actor) invokeFunc(mb *mailbox, app cfacade.IApplication, fn cfacade.InvokeFunc, m *cfacade.Message) (err error) {
+	defer func() {
+		if err != nil {
+			// 执行失败时记录日志并响应调用方,需在message回收前处理
+			p.system.invokeError(mb.name, app, m, err)
+		}
+		m.Recycle()
+	}()
+
 	funcInfo, found := mb.funcMap[m.FuncName]
 	if !found {
-		clog.Warnf("[%s] Function not found. [source = %s, target = %s -> %s]",
-			mb.name,
-			m.Source,
-			m.Target,
-			m.FuncName,
-		)
-		m.Recycle()
-		return
+		p.system.unhandled(m)
+		return cerr.Errorf("%w [funcName = %s]", cerr.ActorFuncNotFound, m.FuncName)
 	}
 
 	p.arrivalElapsed = m.PostTime - m.BuildTime
@@ -195,33 +212,106 @@ func (p *Actor) invokeFunc(mb *mailbox, app cfacade.IApplication, fn cfacade.Inv
 		)
 	}
 
-	now := time.Now().UnixMilli()
+	// 串行路由先获取lane,执行耗时及handler的deadline从获取lane后开始计算
+	if lane := p.system.routeLane(m); lane != nil {
+		p.lockLane(mb, lane, m)
+		defer lane.Unlock()
+	}
+
+	ctx, cancel := p.system.messageContext(m)
+	m.SetContext(ctx)
+	p.invoking.ctx = ctx
+
+	defer func() {
+		p.invoking.ctx = nil
+		cancel()
+	}()
+
+	begin := time.Now()
+	now := begin.UnixMilli()
+	invoked := false
 
 	defer func() {
+		if invoked && ctx.Err() == context.DeadlineExceeded {
+			clog.Warnf("[%s] Invoke exceeded the deadline.[source = %s, target = %s->%s, sid = %s, uid = %d, timeout = %v]",
+				mb.name,
+				m.Source,
+				m.Target,
+				m.FuncName,
+				m.Session.GetSid(),
+				m.Session.GetUid(),
+				p.system.handlerTimeout,
+			)
+		}
+
 		p.executionElapsed = time.Now().UnixMilli() - now
 		if p.executionElapsed > p.system.executionTimeout {
-			clog.Warnf("[%s] Invoke timeout.[source = %s, target = %s->%s, execution = %dms]",
+			clog.Warnf("[%s] Invoke timeout.[source = %s, target = %s->%s, sid = %s, uid = %d, execution = %dms]",
 				mb.name,
 				m.Source,
 				m.Target,
 				m.FuncName,
+				m.Session.GetSid(),
+				m.Session.GetUid(),
 				p.executionElapsed,
 			)
 		}
 
-		if rev := recover(); rev != nil {
-			clog.Errorf("[%s] Invoke error. [source = %s, target = %s->%s, type = %v]",
+		rev := recover()
+		if rev != nil {
+			clog.Errorf("[%s] Invoke error. [source = %s, target = %s->%s, type = %v, sid = %s, uid = %d, err = %v]\n%s",
 				mb.name,
 				m.Source,
 				m.Target,
 				m.FuncName,
 				funcInfo.InArgs,
+				m.Session.GetSid(),
+				m.Session.GetUid(),
+				rev,
+				debug.Stack(),
 			)
+			err = cerr.Errorf("%w [funcName = %s, err = %v]", cerr.ActorInvokePanic, m.FuncName, rev)
+		}
+
+		if invoked {
+			if p.system.metrics != nil {
+				p.system.metrics.ObserveInvoke(invokeRoute(m), rev == nil, time.Since(begin))
+			}
+
+			p.system.afterFilter(m)
 		}
-		m.Recycle()
 	}()
 
+	if ok, filterErr := p.system.beforeFilter(m); !ok {
+		var rejectErr *FilterError
+		if !errors.As(filterErr, &rejectErr) {
+			rejectErr = NewFilterError(ccode.ActorFilterRejected, filterErr)
+		}
+		return cerr.Errorf("%w [funcName = %s]", rejectErr, m.FuncName)
+	}
+
+	invoked = true
+
 	fn(app, funcInfo, m)
+	return nil
+}
+
+// lockLane 等待串行路由的lane,等待期间当前actor的其他消息也在等待,等待过久时打印警告日志
+func (p *Actor) lockLane(mb *mailbox, lane *routeLane, m *cfacade.Message) {
+	begin := time.Now()
+	lane.Lock()
+
+	if wait := time.Since(begin).Milliseconds(); wait > p.system.executionTimeout {
+		clog.Warnf("[%s] Route lane wait timeout.[pattern = %s, target = %s->%s, sid = %s, uid = %d, wait = %dms]",
+			mb.name,
+			lane.pattern,
+			m.Target,
+			m.FuncName,
+			m.Session.GetSid(),
+			m.Session.GetUid(),
+			wait,
+		)
+	}
 }
 
 func (p *Actor) findChildActor(m *cfacade.Message) (*Actor, bool) {
@@ -279,6 +369,15 @@ func (p *Actor) onStop() {
 	p.system.wg.Done()
 }
 
+// Context 返回正在执行的消息的上下文,超过System.SetHandlerTimeout的时间后Done()被关闭
+// 函数执行耗时操作(如数据库调用)时可监听ctx.Done()提前结束,不在函数执行中时返回context.Background()
+func (p *Actor) Context() context.Context {
+	if p.invoking == nil || p.invoking.ctx == nil {
+		return context.Background()
+	}
+	return p.invoking.ctx
+}
+
 func (p *Actor) State() State {
 	return p.state
 }
@@ -372,11 +471,12 @@ func newActor(actorID, childID string, handler cfacade.IActorHandler, c *System)
 			ActorID: actorID,
 			ChildID: childID,
 		},
-		state:   InitState,
-		system:  c,
-		close:   make(chan struct{}, 1),
-		handler: handler,
-		lastAt:  time.Now().Unix(),
+		state:    InitState,
+		system:   c,
+		close:    make(chan struct{}, 1),
+		handler:  handler,
+		lastAt:   time.Now().Unix(),
+		invoking: &invokeContext{},
 	}
 
 	localMailbox := newMailbox(LocalName)
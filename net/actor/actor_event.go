@@ -1,6 +1,7 @@
 package cherryActor
 
 import (
+	cutils "github.com/cherry-game/cherry/extend/utils"
 	cfacade "github.com/cherry-game/cherry/facade"
 	clog "github.com/cherry-game/cherry/logger"
 )
@@ -20,7 +21,7 @@ func newEvent(thisActor *Actor) actorEvent {
 }
 
 // Register 注册事件
-// name 事件名
+// name 事件名,EventAll则接收所有事件
 // fn 接收事件处理的函数
 func (p *actorEvent) Register(name string, fn IEventFunc) {
 	funcList := p.funcMap[name]
@@ -41,7 +42,7 @@ func (p *actorEvent) Unregister(name string) {
 }
 
 func (p *actorEvent) Push(data cfacade.IEventData) {
-	if _, found := p.funcMap[data.Name()]; found {
+	if p.isRegistered(data.Name()) {
 		p.queue.Push(data)
 	}
 
@@ -71,9 +72,22 @@ func (p *actorEvent) Pop() cfacade.IEventData {
 	return eventData
 }
 
+func (p *actorEvent) isRegistered(name string) bool {
+	if _, found := p.funcMap[name]; found {
+		return true
+	}
+
+	_, found := p.funcMap[EventAll]
+	return found
+}
+
 func (p *actorEvent) funcInvoke(data cfacade.IEventData) {
-	funcList, found := p.funcMap[data.Name()]
-	if !found {
+	funcList := p.funcMap[data.Name()]
+	if data.Name() != EventAll {
+		funcList = append(funcList[:len(funcList):len(funcList)], p.funcMap[EventAll]...)
+	}
+
+	if len(funcList) < 1 {
 		clog.Warnf("[%s] Event not found. [data = %+v]",
 			p.thisActor.Path(),
 			data,
@@ -81,17 +95,18 @@ func (p *actorEvent) funcInvoke(data cfacade.IEventData) {
 		return
 	}
 
-	defer func() {
-		if rev := recover(); rev != nil {
-			clog.Errorf("[%s] Event invoke error. [data = %+v]",
+	// 每个处理函数独立recover,避免一个函数异常导致后续函数无法执行
+	for _, eventFunc := range funcList {
+		fn := eventFunc
+		cutils.Try(func() {
+			fn(data)
+		}, func(errString string) {
+			clog.Errorf("[%s] Event invoke error. [data = %+v, err = %s]",
 				p.thisActor.Path(),
 				data,
+				errString,
 			)
-		}
-	}()
-
-	for _, eventFunc := range funcList {
-		eventFunc(data)
+		})
 	}
 }
 
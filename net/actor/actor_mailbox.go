@@ -1,6 +1,9 @@
 package cherryActor
 
 import (
+	"reflect"
+	"runtime"
+	"sort"
 	"time"
 
 	creflect "github.com/cherry-game/cherry/extend/reflect"
@@ -34,19 +37,43 @@ func (p *mailbox) Register(funcName string, fn interface{}) {
 		return
 	}
 
-	if _, found := p.funcMap[funcName]; found {
-		clog.Errorf("funcName = %s, already exists.", funcName)
+	// 重复注册在启动阶段直接panic,避免运行时路由到错误的函数
+	if registered, found := p.funcMap[funcName]; found {
+		clog.Panicf("[mailbox = %s] funcName = %s, already registered. [registered = %s, conflict = %s]",
+			p.name,
+			funcName,
+			funcFullName(registered.Value),
+			funcFullName(funcInfo.Value),
+		)
 		return
 	}
 
 	p.funcMap[funcName] = &funcInfo
 }
 
+func funcFullName(v reflect.Value) string {
+	if fn := runtime.FuncForPC(v.Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return v.String()
+}
+
 func (p *mailbox) GetFuncInfo(funcName string) (*creflect.FuncInfo, bool) {
 	funcInfo, found := p.funcMap[funcName]
 	return funcInfo, found
 }
 
+// FuncNames 已注册的函数名列表,函数信息在Register时已解析,调用时只需查找map
+func (p *mailbox) FuncNames() []string {
+	names := make([]string, 0, len(p.funcMap))
+	for name := range p.funcMap {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
 func (p *mailbox) Pop() *cfacade.Message {
 	v := p.queue.Pop()
 	if v == nil {
@@ -0,0 +1,833 @@
+package cherryActor
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ccode "github.com/cherry-game/cherry/code"
+	cerror "github.com/cherry-game/cherry/error"
+	creflect "github.com/cherry-game/cherry/extend/reflect"
+	cfacade "github.com/cherry-game/cherry/facade"
+	cproto "github.com/cherry-game/cherry/net/proto"
+	cserializer "github.com/cherry-game/cherry/net/serializer"
+)
+
+func TestActorInvokePanic(t *testing.T) {
+	actorSystem := NewSystem()
+	app := &testApp{serializer: cserializer.NewJSON(), actorSystem: actorSystem}
+
+	thisActor, err := newActor("panic", "", &testActor{}, actorSystem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invokeCount := 0
+	thisActor.Local().Register("login", func(_ *cproto.Session, _ *cproto.Response) {
+		invokeCount++
+		panic("nil map")
+	})
+
+	// the panic of the registered handler reaches invokeFunc through the default local invoker
+	for i := 0; i < 2; i++ {
+		m := cfacade.GetMessage()
+		m.FuncName = "login"
+		m.Session = &cproto.Session{Sid: "1", Uid: 1}
+		m.Args = &cproto.Response{}
+		if err = thisActor.invokeFunc(thisActor.localMail, app, actorSystem.localInvokeFunc, m); !errors.Is(err, cerror.ActorInvokePanic) {
+			t.Fatalf("err = %v, want %v", err, cerror.ActorInvokePanic)
+		}
+	}
+
+	if invokeCount != 2 {
+		t.Fatalf("invoke count = %d", invokeCount)
+	}
+}
+
+type testEvent struct {
+	name string
+}
+
+func (p *testEvent) Name() string {
+	return p.name
+}
+
+func (p *testEvent) UniqueId() int64 {
+	return 0
+}
+
+func TestActorEvent(t *testing.T) {
+	actorSystem := NewSystem()
+
+	thisActor, err := newActor("event", "", &testActor{}, actorSystem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var loginCount, allCount int
+	thisActor.Event().Register("player.login", func(_ cfacade.IEventData) {
+		loginCount++
+		panic("nil map")
+	})
+	thisActor.Event().Register(EventAll, func(_ cfacade.IEventData) {
+		allCount++
+	})
+
+	thisActor.event.Push(&testEvent{name: "player.login"})
+	thisActor.event.Push(&testEvent{name: "player.logout"})
+
+	for data := thisActor.event.Pop(); data != nil; data = thisActor.event.Pop() {
+		thisActor.event.funcInvoke(data)
+	}
+
+	if loginCount != 1 || allCount != 2 {
+		t.Fatalf("loginCount = %d, allCount = %d", loginCount, allCount)
+	}
+
+	thisActor.Event().Unregister(EventAll)
+	thisActor.event.Push(&testEvent{name: "player.logout"})
+	if thisActor.event.Count() != 0 {
+		t.Fatal("unregistered event is pushed")
+	}
+}
+
+func TestActorUnhandled(t *testing.T) {
+	actorSystem := NewSystem()
+
+	var unhandledList []string
+	actorSystem.SetOnUnhandled(func(m *cfacade.Message) {
+		unhandledList = append(unhandledList, m.FuncName)
+	})
+
+	thisActor, err := newActor("unhandled", "", &testActor{}, actorSystem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := cfacade.GetMessage()
+	m.FuncName = "notRegistered"
+	if err = thisActor.invokeFunc(thisActor.localMail, nil, InvokeLocalFunc, m); !errors.Is(err, cerror.ActorFuncNotFound) {
+		t.Fatalf("err = %v, want %v", err, cerror.ActorFuncNotFound)
+	}
+
+	if len(unhandledList) != 1 || unhandledList[0] != "notRegistered" {
+		t.Fatalf("unhandled = %v", unhandledList)
+	}
+}
+
+func TestSystemTryPost(t *testing.T) {
+	actorSystem := NewSystem()
+
+	if err := actorSystem.TryPostLocal(nil); !errors.Is(err, cerror.ActorMessageNil) {
+		t.Fatalf("err = %v, want %v", err, cerror.ActorMessageNil)
+	}
+
+	m := cfacade.BuildMessage(".player", ".notFound", "login", nil)
+	if err := actorSystem.TryPostLocal(m); !errors.Is(err, cerror.ActorNotFound) {
+		t.Fatalf("err = %v, want %v", err, cerror.ActorNotFound)
+	}
+
+	if actorSystem.PostRemote(m) {
+		t.Fatal("post to the not found actor should fail")
+	}
+
+	// the actor is not started(or stopped)
+	thisActor, err := newActor("stopped", "", &testActor{}, actorSystem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actorSystem.actorMap.Store(thisActor.ActorID(), &thisActor)
+
+	m = cfacade.BuildMessage(".player", ".stopped", "login", nil)
+	if err = actorSystem.TryPostRemote(m); !errors.Is(err, cerror.ActorNotRunning) {
+		t.Fatalf("err = %v, want %v", err, cerror.ActorNotRunning)
+	}
+
+	thisActor.state = WorkerState
+	if err = actorSystem.TryPostLocal(m); err != nil {
+		t.Fatal(err)
+	}
+
+	if thisActor.localMail.Pop() != m {
+		t.Fatal("message should be posted to the local mailbox")
+	}
+}
+
+func TestSystemMailCount(t *testing.T) {
+	actorSystem := NewSystem()
+
+	parent, err := newActor("room", "", &testActor{}, actorSystem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actorSystem.actorMap.Store(parent.ActorID(), &parent)
+
+	child, err := newActor("room", "1", &testActor{}, actorSystem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent.child.childActors.Store("1", &child)
+
+	// the messages of the child actor are included
+	child.PostLocal(cfacade.GetMessage())
+	child.PostLocal(cfacade.GetMessage())
+	parent.PostRemote(cfacade.GetMessage())
+
+	if local, remote := actorSystem.MailCount(); local != 2 || remote != 1 {
+		t.Fatalf("local = %d, remote = %d", local, remote)
+	}
+}
+
+func TestSystemStopTimeout(t *testing.T) {
+	actorSystem := NewSystem()
+	actorSystem.SetStopTimeout(100 * time.Millisecond)
+
+	parentActor, err := actorSystem.CreateActor("room", &testActor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	childActor, err := parentActor.(*Actor).Child().Create("1", &testActor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	child := childActor.(*Actor)
+
+	// the child actor is blocked by the first message, the others stay in the mailbox
+	var (
+		started = make(chan struct{}, 1)
+		block   = make(chan struct{})
+	)
+	defer close(block)
+
+	child.Local().Register("save", func(_ *cproto.Session, _ *cproto.Response) {
+		started <- struct{}{}
+		<-block
+	})
+
+	actorSystem.SetApp(&testApp{serializer: cserializer.NewJSON(), actorSystem: actorSystem})
+
+	for i := 0; i < 3; i++ {
+		m := cfacade.GetMessage()
+		m.Target = child.PathString()
+		m.FuncName = "save"
+		m.Session = &cproto.Session{}
+		m.Args = &cproto.Response{}
+		child.PostLocal(m)
+	}
+	<-started
+
+	begin := time.Now()
+	actorSystem.Stop()
+
+	if elapsed := time.Since(begin); elapsed < 100*time.Millisecond || elapsed > 2*time.Second {
+		t.Fatalf("stop elapsed = %v", elapsed)
+	}
+
+	if local, _ := mailCount([]*Actor{child}); local != 2 {
+		t.Fatalf("local = %d", local)
+	}
+}
+
+func TestMailboxRegisterConflict(t *testing.T) {
+	m := newMailbox(LocalName)
+	m.Register("login", func(_ *cproto.Session, _ *cproto.Response) {})
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("register the same funcName should panic")
+		}
+	}()
+
+	m.Register("login", func(_ *cproto.Session, _ *cproto.Response) {})
+}
+
+func TestMailboxFuncNames(t *testing.T) {
+	m := newMailbox(LocalName)
+	m.Register("logout", func(_ *cproto.Session, _ *cproto.Response) {})
+	m.Register("login", func(_ *cproto.Session, _ *cproto.Response) {})
+
+	names := m.FuncNames()
+	if len(names) != 2 || names[0] != "login" || names[1] != "logout" {
+		t.Fatalf("names = %v", names)
+	}
+}
+
+func BenchmarkMailboxDispatch(b *testing.B) {
+	m := newMailbox(LocalName)
+
+	count := 0
+	m.Register("login", func(_ *cproto.Session, _ *cproto.Response) {
+		count++
+	})
+
+	values := []reflect.Value{
+		reflect.ValueOf(&cproto.Session{}),
+		reflect.ValueOf(&cproto.Response{}),
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if fi, found := m.GetFuncInfo("login"); found {
+			fi.Value.Call(values)
+		}
+	}
+}
+
+func TestActorFilter(t *testing.T) {
+	actorSystem := NewSystem()
+
+	thisActor, err := newActor("filter", "", &testActor{}, actorSystem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	thisActor.Local().Register("login", func(_ *cproto.Session, _ *cproto.Response) {})
+
+	var trace []string
+	actorSystem.AddBeforeFilter(
+		func(m *cfacade.Message) bool {
+			trace = append(trace, "auth")
+			return m.Session.GetUid() > 0
+		},
+		func(_ *cfacade.Message) bool {
+			trace = append(trace, "limit")
+			return true
+		},
+		func(_ *cfacade.Message) bool {
+			trace = append(trace, "log")
+			return true
+		},
+	)
+	actorSystem.AddAfterFilter(
+		func(_ *cfacade.Message) bool {
+			trace = append(trace, "after1")
+			return false
+		},
+		func(_ *cfacade.Message) bool {
+			trace = append(trace, "after2")
+			return true
+		},
+	)
+
+	invoke := func(uid int64, panicFunc bool) {
+		trace = nil
+		m := cfacade.GetMessage()
+		m.FuncName = "login"
+		m.Session = &cproto.Session{Uid: uid}
+		thisActor.invokeFunc(thisActor.localMail, nil, func(_ cfacade.IApplication, _ *creflect.FuncInfo, _ *cfacade.Message) {
+			trace = append(trace, "handler")
+			if panicFunc {
+				panic("handler error")
+			}
+		}, m)
+	}
+
+	// auth返回false,中断后续的过滤器和执行函数
+	invoke(0, false)
+	if strings.Join(trace, ",") != "auth" {
+		t.Fatalf("trace = %v", trace)
+	}
+
+	invoke(1, false)
+	if strings.Join(trace, ",") != "auth,limit,log,handler,after1" {
+		t.Fatalf("trace = %v", trace)
+	}
+
+	// 执行函数panic时after过滤器仍然执行
+	invoke(1, true)
+	if strings.Join(trace, ",") != "auth,limit,log,handler,after1" {
+		t.Fatalf("trace = %v", trace)
+	}
+}
+
+func TestActorContext(t *testing.T) {
+	actorSystem := NewSystem()
+	actorSystem.SetHandlerTimeout(20 * time.Millisecond)
+
+	thisActor, err := newActor("context", "", &testActor{}, actorSystem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var handlerErr error
+	thisActor.Local().Register("query", func(_ *cproto.Session, _ *cproto.Response) {
+		// the handler aborts the slow query when the deadline is exceeded
+		select {
+		case <-thisActor.Context().Done():
+			handlerErr = thisActor.Context().Err()
+		case <-time.After(3 * time.Second):
+		}
+	})
+
+	var filterDeadline, afterDeadline bool
+	actorSystem.AddBeforeFilter(func(m *cfacade.Message) bool {
+		_, filterDeadline = m.Context().Deadline()
+		return true
+	})
+	actorSystem.AddAfterFilter(func(m *cfacade.Message) bool {
+		afterDeadline = m.Context().Err() == context.DeadlineExceeded
+		return true
+	})
+
+	m := cfacade.GetMessage()
+	m.FuncName = "query"
+	m.Args = &cproto.Response{}
+	app := &testApp{serializer: cserializer.NewJSON(), actorSystem: actorSystem}
+	if err = thisActor.invokeFunc(thisActor.localMail, app, actorSystem.localInvokeFunc, m); err != nil {
+		t.Fatal(err)
+	}
+
+	if handlerErr != context.DeadlineExceeded || !filterDeadline || !afterDeadline {
+		t.Fatalf("handler err = %v, filter deadline = %v, after deadline = %v", handlerErr, filterDeadline, afterDeadline)
+	}
+
+	// the context is cleared after the message is executed
+	if thisActor.Context().Err() != nil {
+		t.Fatal("context is not cleared")
+	}
+}
+
+func TestActorFilterError(t *testing.T) {
+	actorSystem := NewSystem()
+	app := &testApp{serializer: cserializer.NewJSON(), actorSystem: actorSystem}
+
+	thisActor, err := newActor("player", "", &testActor{}, actorSystem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invoked := false
+	thisActor.Local().Register("login", func(_ *cproto.Session, _ *cproto.Response) {
+		invoked = true
+	})
+
+	// the agent actor receives the response of the rejected request
+	agentActor, err := newActor("agent", "", &testActor{}, actorSystem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agentActor.state = WorkerState
+	actorSystem.actorMap.Store(agentActor.ActorID(), &agentActor)
+
+	authErr := cerror.Error("token expired")
+
+	var trace []string
+	actorSystem.AddBeforeFilter(func(_ *cfacade.Message) bool {
+		trace = append(trace, "log")
+		return true
+	})
+	actorSystem.AddBeforeErrFilter(func(m *cfacade.Message) (bool, error) {
+		trace = append(trace, "auth")
+		if m.Session.GetUid() < 1 {
+			return false, NewFilterError(401, authErr)
+		}
+		return true, nil
+	})
+
+	login := func(uid int64, mid uint32) error {
+		m := cfacade.GetMessage()
+		m.Target = ".player"
+		m.FuncName = "login"
+		m.Session = &cproto.Session{Sid: "1", Uid: uid, Mid: mid, AgentPath: ".agent"}
+		return thisActor.invokeFunc(thisActor.localMail, app, func(_ cfacade.IApplication, _ *creflect.FuncInfo, _ *cfacade.Message) {
+			invoked = true
+		}, m)
+	}
+
+	err = login(0, 1)
+	if !errors.Is(err, cerror.ActorFilterRejected) || !strings.Contains(err.Error(), authErr.Error()) {
+		t.Fatalf("err = %v", err)
+	}
+
+	if invoked || strings.Join(trace, ",") != "log,auth" {
+		t.Fatalf("invoked = %t, trace = %v", invoked, trace)
+	}
+
+	m := agentActor.remoteMail.Pop()
+	if m == nil || m.FuncName != ResponseFuncName {
+		t.Fatalf("response message = %+v", m)
+	}
+
+	rsp, ok := m.Args.(*cproto.PomeloResponse)
+	if !ok || rsp.Code != 401 || rsp.Mid != 1 || !strings.Contains(string(rsp.Data), authErr.Error()) {
+		t.Fatalf("rsp = %+v", m.Args)
+	}
+
+	// notify(mid = 0) is not responded
+	if err = login(0, 0); !errors.Is(err, cerror.ActorFilterRejected) {
+		t.Fatalf("err = %v", err)
+	}
+
+	if m = agentActor.remoteMail.Pop(); m != nil {
+		t.Fatalf("response message = %+v", m)
+	}
+
+	if err = login(1, 2); err != nil || !invoked {
+		t.Fatalf("invoked = %t, err = %v", invoked, err)
+	}
+}
+
+func TestSystemSerializeRoute(t *testing.T) {
+	actorSystem := NewSystem()
+	actorSystem.SerializeRoute("*.match")
+
+	var actors []*Actor
+	for _, actorID := range []string{"room1", "room2"} {
+		thisActor, err := newActor(actorID, "", &testActor{}, actorSystem)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		thisActor.Local().Register("match", func(_ *cproto.Session, _ *cproto.Response) {})
+		thisActor.Local().Register("chat", func(_ *cproto.Session, _ *cproto.Response) {})
+		actors = append(actors, &thisActor)
+	}
+
+	var (
+		running    int32
+		maxRunning int32
+	)
+
+	invokeFn := func(_ cfacade.IApplication, _ *creflect.FuncInfo, m *cfacade.Message) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+
+		// wait for the other message, it arrives if the route runs in parallel
+		deadline := time.Now().Add(200 * time.Millisecond)
+		for atomic.LoadInt32(&maxRunning) < 2 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	invoke := func(funcName string) int32 {
+		atomic.StoreInt32(&maxRunning, 0)
+
+		var wg sync.WaitGroup
+		for _, thisActor := range actors {
+			wg.Add(1)
+			go func(thisActor *Actor) {
+				defer wg.Done()
+
+				m := cfacade.GetMessage()
+				m.Target = thisActor.PathString()
+				m.FuncName = funcName
+				_ = thisActor.invokeFunc(thisActor.localMail, nil, invokeFn, m)
+			}(thisActor)
+		}
+		wg.Wait()
+
+		return atomic.LoadInt32(&maxRunning)
+	}
+
+	if n := invoke("match"); n != 1 {
+		t.Fatalf("match max running = %d, want 1", n)
+	}
+
+	if n := invoke("chat"); n != 2 {
+		t.Fatalf("chat max running = %d, want 2", n)
+	}
+}
+
+// testMetrics 记录每次执行的耗时
+type testMetrics struct {
+	sync.Mutex
+	durations []time.Duration
+}
+
+func (p *testMetrics) ObserveInvoke(_ string, _ bool, d time.Duration) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.durations = append(p.durations, d)
+}
+
+func TestSystemSerializeRouteElapsed(t *testing.T) {
+	actorSystem := NewSystem()
+	actorSystem.SerializeRoute("*.match")
+
+	metrics := &testMetrics{}
+	actorSystem.SetMetrics(metrics)
+
+	var actors []*Actor
+	for _, actorID := range []string{"room1", "room2"} {
+		thisActor, err := newActor(actorID, "", &testActor{}, actorSystem)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		thisActor.Local().Register("match", func(_ *cproto.Session, _ *cproto.Response) {})
+		actors = append(actors, &thisActor)
+	}
+
+	invokeFn := func(_ cfacade.IApplication, _ *creflect.FuncInfo, _ *cfacade.Message) {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	var wg sync.WaitGroup
+	for _, thisActor := range actors {
+		wg.Add(1)
+		go func(thisActor *Actor) {
+			defer wg.Done()
+
+			m := cfacade.GetMessage()
+			m.Target = thisActor.PathString()
+			m.FuncName = "match"
+			_ = thisActor.invokeFunc(thisActor.localMail, nil, invokeFn, m)
+		}(thisActor)
+	}
+	wg.Wait()
+
+	// the second message waits for the lane, the wait is not counted into the execution elapsed
+	if len(metrics.durations) != 2 {
+		t.Fatalf("durations = %v", metrics.durations)
+	}
+
+	for _, d := range metrics.durations {
+		if d >= 180*time.Millisecond {
+			t.Fatalf("the lane wait is counted. [durations = %v]", metrics.durations)
+		}
+	}
+}
+
+func TestActorMetrics(t *testing.T) {
+	actorSystem := NewSystem()
+	app := &testApp{serializer: cserializer.NewJSON(), actorSystem: actorSystem}
+	stats := NewInvokeStats()
+	actorSystem.SetMetrics(stats)
+
+	thisActor, err := newActor("metrics", "", &testActor{}, actorSystem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the registered handlers are invoked by the default invokers
+	thisActor.Local().Register("login", func(_ *cproto.Session, req *cproto.Response) {
+		if req.Code < 0 {
+			panic("handler error")
+		}
+	})
+	thisActor.Remote().Register("save", func(req *cproto.Response) {
+		if req.Code < 0 {
+			panic("handler error")
+		}
+	})
+
+	for i := 0; i < 3; i++ {
+		m := cfacade.GetMessage()
+		m.FuncName = "login"
+		m.Target = "game-1.metrics"
+		m.Session = &cproto.Session{}
+		m.Args = &cproto.Response{Code: int32(i - 1)}
+		_ = thisActor.invokeFunc(thisActor.localMail, app, actorSystem.localInvokeFunc, m)
+
+		m = cfacade.GetMessage()
+		m.FuncName = "save"
+		m.Target = "game-1.metrics"
+		m.Args = &cproto.Response{Code: int32(i - 1)}
+		_ = thisActor.invokeFunc(thisActor.remoteMail, app, actorSystem.remoteInvokeFunc, m)
+	}
+
+	for _, route := range []string{"metrics.login", "metrics.save"} {
+		stat, found := stats.Snapshot()[route]
+		if !found || stat.Count != 3 || stat.Errors != 1 {
+			t.Fatalf("route = %s, stats = %+v", route, stats.Snapshot())
+		}
+	}
+}
+
+func TestLocalResponseFunc(t *testing.T) {
+	serializer := cserializer.NewJSON()
+
+	fn := func(_ *cproto.Session, req *cproto.Response) (*cproto.Response, error) {
+		if req.Code < 0 {
+			return nil, cerror.Error("invalid code")
+		}
+		return &cproto.Response{Code: req.Code + 1}, nil
+	}
+
+	fi, err := creflect.GetFuncInfo(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !isResponseFunc(&fi) {
+		t.Fatal("expected response func")
+	}
+
+	call := func(req *cproto.Response) (int32, []byte) {
+		rets := fi.Value.Call([]reflect.Value{
+			reflect.ValueOf(&cproto.Session{}),
+			reflect.ValueOf(req),
+		})
+		return localRetValue(serializer, rets)
+	}
+
+	code, data := call(&cproto.Response{Code: 1})
+	if code != ccode.OK || string(data) != `{"code":2}` {
+		t.Fatalf("code = %d, data = %s", code, data)
+	}
+
+	code, data = call(&cproto.Response{Code: -1})
+	if code != ccode.LocalExecuteError || data != nil {
+		t.Fatalf("code = %d, data = %s", code, data)
+	}
+}
+
+func TestEncodeLocalArgsMalformed(t *testing.T) {
+	fi, err := creflect.GetFuncInfo(func(_ *cproto.Session, _ *cproto.Response) (*cproto.Response, error) {
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &testApp{serializer: cserializer.NewJSON()}
+
+	m := &cfacade.Message{Args: []byte(`{"code":3}`)}
+	if err = EncodeLocalArgs(app, &fi, m); err != nil {
+		t.Fatal(err)
+	}
+
+	if req, ok := m.Args.(*cproto.Response); !ok || req.Code != 3 {
+		t.Fatalf("args = %+v", m.Args)
+	}
+
+	m = &cfacade.Message{Args: []byte(`{"code":`)}
+	if err = EncodeLocalArgs(app, &fi, m); err == nil {
+		t.Fatal("expected unmarshal error")
+	}
+}
+
+func TestActorInvokeError(t *testing.T) {
+	actorSystem := NewSystem()
+	app := &testApp{serializer: cserializer.NewJSON(), actorSystem: actorSystem}
+
+	thisActor, err := newActor("player", "", &testActor{}, actorSystem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	thisActor.Local().Register("login", func(_ *cproto.Session, _ *cproto.Response) (*cproto.Response, error) {
+		panic("nil map")
+	})
+	thisActor.Local().Register("logout", func(_ *cproto.Session, _ *cproto.Response) {})
+	thisActor.Remote().Register("save", func(_ *cproto.Response) {
+		panic("nil map")
+	})
+
+	agentActor, err := newActor("agent", "", &testActor{}, actorSystem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agentActor.state = WorkerState
+	actorSystem.actorMap.Store(agentActor.ActorID(), &agentActor)
+	actorSystem.SetApp(app)
+
+	var invokeErrors []error
+	actorSystem.SetOnInvokeError(func(_ *cfacade.Message, err error) {
+		invokeErrors = append(invokeErrors, err)
+	})
+	actorSystem.AddBeforeFilter(func(m *cfacade.Message) bool {
+		return m.FuncName != "logout"
+	})
+
+	localRequest := func(funcName string) int32 {
+		m := cfacade.GetMessage()
+		m.Target = ".player"
+		m.FuncName = funcName
+		m.Args = &cproto.Response{}
+		m.Session = &cproto.Session{Sid: "1", Uid: 1, Mid: 1, AgentPath: ".agent"}
+		thisActor.localMail.Push(m)
+		thisActor.processLocal()
+
+		rspMsg := agentActor.remoteMail.Pop()
+		if rspMsg == nil {
+			t.Fatalf("funcName = %s, no response", funcName)
+		}
+		return rspMsg.Args.(*cproto.PomeloResponse).Code
+	}
+
+	remoteCall := func(funcName string) int32 {
+		m := cfacade.BuildMessage(".caller", ".player", funcName, &cproto.Response{})
+		thisActor.remoteMail.Push(m)
+
+		done := make(chan struct{})
+		go func() {
+			thisActor.processRemote()
+			close(done)
+		}()
+
+		var code int32
+		select {
+		case result := <-m.ChanResult:
+			code = result.(*cproto.Response).Code
+		case <-time.After(time.Second):
+			t.Fatalf("funcName = %s, no result", funcName)
+		}
+
+		<-done
+		return code
+	}
+
+	if code := localRequest("notFound"); code != ccode.RouteNotFound {
+		t.Fatalf("code = %d", code)
+	}
+	if code := localRequest("logout"); code != ccode.ActorFilterRejected {
+		t.Fatalf("code = %d", code)
+	}
+	if code := localRequest("login"); code != ccode.LocalExecuteError {
+		t.Fatalf("code = %d", code)
+	}
+	if code := remoteCall("notFound"); code != ccode.RouteNotFound {
+		t.Fatalf("code = %d", code)
+	}
+	if code := remoteCall("save"); code != ccode.RPCRemoteExecuteError {
+		t.Fatalf("code = %d", code)
+	}
+
+	want := []error{
+		cerror.ActorFuncNotFound,
+		cerror.ActorFilterRejected,
+		cerror.ActorInvokePanic,
+		cerror.ActorFuncNotFound,
+		cerror.ActorInvokePanic,
+	}
+
+	if len(invokeErrors) != len(want) {
+		t.Fatalf("invoke errors = %v", invokeErrors)
+	}
+
+	for i, target := range want {
+		if !errors.Is(invokeErrors[i], target) {
+			t.Fatalf("invoke errors[%d] = %v, want %v", i, invokeErrors[i], target)
+		}
+	}
+}
+
+type testApp struct {
+	cfacade.IApplication
+	serializer  cfacade.ISerializer
+	actorSystem *System
+}
+
+func (p *testApp) ActorSystem() cfacade.IActorSystem {
+	return p.actorSystem
+}
+
+func (p *testApp) Serializer() cfacade.ISerializer {
+	return p.serializer
+}
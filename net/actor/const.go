@@ -13,4 +13,7 @@ var (
 const (
 	LocalName  = "local"
 	RemoteName = "remote"
+	EventAll   = "*" // 订阅所有事件
+
+	ResponseFuncName = "response" // agent actor中响应客户端请求的函数名
 )
@@ -27,6 +27,8 @@ type (
 	IMailBox interface {
 		Register(funcName string, fn interface{}) // 注册执行函数
 		GetFuncInfo(funcName string) (*creflect.FuncInfo, bool)
+		FuncNames() []string // 已注册的函数名列表(已排序)
+		Count() int32        // 待处理的消息数量
 	}
 )
 
@@ -8,24 +8,52 @@ import (
 	ccode "github.com/cherry-game/cherry/code"
 	cerror "github.com/cherry-game/cherry/error"
 	creflect "github.com/cherry-game/cherry/extend/reflect"
-	cutils "github.com/cherry-game/cherry/extend/utils"
 	cfacade "github.com/cherry-game/cherry/facade"
 	clog "github.com/cherry-game/cherry/logger"
 	cproto "github.com/cherry-game/cherry/net/proto"
 )
 
+var (
+	bytesType = reflect.TypeOf([]byte(nil))
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+)
+
 func InvokeLocalFunc(app cfacade.IApplication, fi *creflect.FuncInfo, m *cfacade.Message) {
 	if app == nil {
 		clog.Errorf("[InvokeLocalFunc] app is nil. [message = %+v]", m)
 		return
 	}
 
-	EncodeLocalArgs(app, fi, m)
+	if err := EncodeLocalArgs(app, fi, m); err != nil {
+		clog.Warn(err)
+		if isResponseFunc(fi) {
+			localResponse(app, m, ccode.ActorUnmarshalError, nil)
+		}
+		return
+	}
 
 	values := make([]reflect.Value, 2)
 	values[0] = reflect.ValueOf(m.Session) // session
 	values[1] = reflect.ValueOf(m.Args)    // args
-	fi.Value.Call(values)
+
+	// 函数panic时不在此处recover,由Actor.invokeFunc统一记录日志、指标及返回错误
+	// 声明了响应的函数在panic时仍响应客户端,避免请求一直等待
+	called := false
+	if isResponseFunc(fi) {
+		defer func() {
+			if !called {
+				localResponse(app, m, ccode.LocalExecuteError, nil)
+			}
+		}()
+	}
+
+	rets := fi.Value.Call(values)
+	called = true
+
+	if isResponseFunc(fi) {
+		rspCode, rspData := localRetValue(app.Serializer(), rets)
+		localResponse(app, m, rspCode, rspData)
+	}
 }
 
 func InvokeRemoteFunc(app cfacade.IApplication, fi *creflect.FuncInfo, m *cfacade.Message) {
@@ -41,47 +69,50 @@ func InvokeRemoteFunc(app cfacade.IApplication, fi *creflect.FuncInfo, m *cfacad
 		values[0] = reflect.ValueOf(m.Args) // args
 	}
 
+	// 函数panic时不在此处recover,由Actor.invokeFunc统一记录日志、指标及返回错误
+	// 等待结果的调用方在panic时仍收到响应,避免一直等待
+	called := false
+	defer func() {
+		if called {
+			return
+		}
+
+		rsp := &cproto.Response{
+			Code: ccode.RPCRemoteExecuteError,
+		}
+
+		if m.IsCluster {
+			retResponse(m.ClusterReply, rsp)
+		} else if m.ChanResult != nil {
+			m.ChanResult <- rsp
+		}
+	}()
+
 	if m.IsCluster {
-		cutils.Try(func() {
-			rets := fi.Value.Call(values)
-			rspCode, rspData := retValue(app.Serializer(), rets)
-
-			retResponse(m.ClusterReply, &cproto.Response{
-				Code: rspCode,
-				Data: rspData,
-			})
-
-		}, func(errString string) {
-			retResponse(m.ClusterReply, &cproto.Response{
-				Code: ccode.RPCRemoteExecuteError,
-			})
-			clog.Errorf("[InvokeRemoteFunc] invoke error. [message = %+v, err = %s]", m, errString)
-		})
-	} else {
-		cutils.Try(func() {
-			if m.ChanResult == nil {
-				fi.Value.Call(values)
-			} else {
-				rets := fi.Value.Call(values)
-				rspCode, rspData := retValue(app.Serializer(), rets)
-				m.ChanResult <- &cproto.Response{
-					Code: rspCode,
-					Data: rspData,
-				}
-			}
-		}, func(errString string) {
-			if m.ChanResult != nil {
-				m.ChanResult <- nil
-			}
+		rets := fi.Value.Call(values)
+		called = true
 
-			clog.Errorf("[remote] invoke error.[source = %s, target = %s -> %s, funcType = %v, err = %+v]",
-				m.Source,
-				m.Target,
-				m.FuncName,
-				fi.InArgs,
-				errString,
-			)
+		rspCode, rspData := retValue(app.Serializer(), rets)
+		retResponse(m.ClusterReply, &cproto.Response{
+			Code: rspCode,
+			Data: rspData,
 		})
+		return
+	}
+
+	if m.ChanResult == nil {
+		fi.Value.Call(values)
+		called = true
+		return
+	}
+
+	rets := fi.Value.Call(values)
+	called = true
+
+	rspCode, rspData := retValue(app.Serializer(), rets)
+	m.ChanResult <- &cproto.Response{
+		Code: rspCode,
+		Data: rspData,
 	}
 }
 
@@ -98,6 +129,11 @@ func EncodeRemoteArgs(app cfacade.IApplication, fi *creflect.FuncInfo, m *cfacad
 }
 
 func EncodeLocalArgs(app cfacade.IApplication, fi *creflect.FuncInfo, m *cfacade.Message) error {
+	if _, ok := m.Args.([]byte); !ok || fi.InArgs[1] == bytesType {
+		// 本地调用直接传递参数对象或函数参数为[]byte,无需反序列化
+		return nil
+	}
+
 	return EncodeArgs(app, fi, 1, m)
 }
 
@@ -162,6 +198,47 @@ func retValue(serializer cfacade.ISerializer, rets []reflect.Value) (int32, []by
 	return rspCode, rspData
 }
 
+// isResponseFunc 本地函数声明为 func(session, req) (rsp, error) 时,由框架自动响应客户端请求
+func isResponseFunc(fi *creflect.FuncInfo) bool {
+	return fi.OutArgsLen == 2 && fi.OutArgs[1] == errorType
+}
+
+func localRetValue(serializer cfacade.ISerializer, rets []reflect.Value) (int32, []byte) {
+	if err, ok := rets[1].Interface().(error); ok && err != nil {
+		clog.Warn(err)
+		return ccode.LocalExecuteError, nil
+	}
+
+	if rets[0].Kind() == reflect.Ptr && rets[0].IsNil() {
+		return ccode.OK, nil
+	}
+
+	data, err := serializer.Marshal(rets[0].Interface())
+	if err != nil {
+		clog.Warn(err)
+		return ccode.ActorMarshalError, nil
+	}
+
+	return ccode.OK, data
+}
+
+// localResponse 将返回值响应给session所属的agent actor,非request消息(mid=0)不响应
+func localResponse(app cfacade.IApplication, m *cfacade.Message, code int32, data []byte) {
+	session := m.Session
+	if session == nil || session.Mid < 1 || session.AgentPath == "" {
+		return
+	}
+
+	rsp := &cproto.PomeloResponse{
+		Sid:  session.Sid,
+		Mid:  session.Mid,
+		Code: code,
+		Data: data,
+	}
+
+	app.ActorSystem().Call(m.Target, session.AgentPath, ResponseFuncName, rsp)
+}
+
 func retResponse(reply cfacade.IRespond, rsp *cproto.Response) {
 	if reply != nil {
 		rspData, _ := proto.Marshal(rsp)
@@ -0,0 +1,85 @@
+package cherryActor
+
+import (
+	"sync"
+	"time"
+
+	cconst "github.com/cherry-game/cherry/const"
+	cfacade "github.com/cherry-game/cherry/facade"
+)
+
+type (
+	// IInvokeMetrics 函数执行的指标收集
+	IInvokeMetrics interface {
+		// ObserveInvoke route为actorID.funcName,succeed为false表示执行函数panic
+		ObserveInvoke(route string, succeed bool, d time.Duration)
+	}
+
+	// InvokeStats 内存中按route统计函数执行次数、错误数及耗时
+	InvokeStats struct {
+		sync.Mutex
+		stats map[string]*InvokeStat
+	}
+
+	InvokeStat struct {
+		Count  int64         // 执行次数
+		Errors int64         // 执行错误(panic)次数
+		Total  time.Duration // 总耗时
+		Max    time.Duration // 最大耗时
+	}
+)
+
+func NewInvokeStats() *InvokeStats {
+	return &InvokeStats{
+		stats: make(map[string]*InvokeStat),
+	}
+}
+
+func (p *InvokeStats) ObserveInvoke(route string, succeed bool, d time.Duration) {
+	p.Lock()
+	defer p.Unlock()
+
+	stat, found := p.stats[route]
+	if !found {
+		stat = &InvokeStat{}
+		p.stats[route] = stat
+	}
+
+	stat.Count++
+	if !succeed {
+		stat.Errors++
+	}
+
+	stat.Total += d
+	if d > stat.Max {
+		stat.Max = d
+	}
+}
+
+// Snapshot 返回各route的统计快照
+func (p *InvokeStats) Snapshot() map[string]InvokeStat {
+	p.Lock()
+	defer p.Unlock()
+
+	snapshot := make(map[string]InvokeStat, len(p.stats))
+	for route, stat := range p.stats {
+		snapshot[route] = *stat
+	}
+
+	return snapshot
+}
+
+// Avg 平均耗时
+func (p InvokeStat) Avg() time.Duration {
+	if p.Count < 1 {
+		return 0
+	}
+	return p.Total / time.Duration(p.Count)
+}
+
+func invokeRoute(m *cfacade.Message) string {
+	if targetPath := m.TargetPath(); targetPath != nil {
+		return targetPath.ActorID + cconst.DOT + m.FuncName
+	}
+	return m.FuncName
+}
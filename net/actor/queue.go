@@ -83,5 +83,5 @@ func (p *queue) Destroy() {
 	close(p.C)
 	p.head = nil
 	p.tail = nil
-	p.count = 0
+	atomic.StoreInt32(&p.count, 0)
 }
@@ -1,11 +1,16 @@
 package cherryActor
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
 	"strings"
 	"sync"
 	"time"
 
 	ccode "github.com/cherry-game/cherry/code"
+	cerr "github.com/cherry-game/cherry/error"
 	cutils "github.com/cherry-game/cherry/extend/utils"
 	cfacade "github.com/cherry-game/cherry/facade"
 	clog "github.com/cherry-game/cherry/logger"
@@ -23,9 +28,57 @@ type (
 		callTimeout      time.Duration      // call调用超时
 		arrivalTimeOut   int64              // message到达超时(毫秒)
 		executionTimeout int64              // 消息执行超时(毫秒)
+		handlerTimeout   time.Duration      // 函数执行上下文的超时时间,0为不设置deadline
+		onUnhandledFunc  UnhandledFunc      // 消息找不到处理函数时回调
+		onInvokeError    InvokeErrorFunc    // 函数执行失败时回调
+		stopTimeout      time.Duration      // 停止时等待actor处理完剩余消息的超时时间,0为一直等待
+		beforeFilters    []FilterErrFunc    // 函数执行前的过滤器
+		afterFilters     []FilterFunc       // 函数执行后的过滤器
+		metrics          IInvokeMetrics     // 函数执行的指标收集,nil则不收集
+		routeLanes       []*routeLane       // 串行执行的路由
+	}
+
+	// routeLane 匹配pattern的路由在所有actor中串行执行
+	routeLane struct {
+		sync.Mutex
+		pattern string
+	}
+
+	UnhandledFunc   func(m *cfacade.Message)
+	InvokeErrorFunc func(m *cfacade.Message, err error)    // err可用errors.Is判断cerr.ActorFuncNotFound等原因
+	FilterFunc      func(m *cfacade.Message) bool          // 返回false则中断后续的过滤器
+	FilterErrFunc   func(m *cfacade.Message) (bool, error) // 返回false则中断后续的过滤器,error为拒绝的原因
+
+	// FilterError 过滤器拒绝的原因,Code响应给客户端(默认为ccode.ActorFilterRejected)
+	FilterError struct {
+		Code int32
+		Err  error
 	}
 )
 
+func NewFilterError(code int32, err error) *FilterError {
+	return &FilterError{
+		Code: code,
+		Err:  err,
+	}
+}
+
+func (e *FilterError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("filter rejected. [code = %d]", e.Code)
+	}
+	return fmt.Sprintf("%v [code = %d]", e.Err, e.Code)
+}
+
+func (e *FilterError) Unwrap() error {
+	return e.Err
+}
+
+// Is 使errors.Is(err, cerr.ActorFilterRejected)成立
+func (e *FilterError) Is(target error) bool {
+	return target == cerr.ActorFilterRejected
+}
+
 func NewSystem() *System {
 	system := &System{
 		actorMap:         &sync.Map{},
@@ -35,6 +88,7 @@ func NewSystem() *System {
 		callTimeout:      3 * time.Second,
 		arrivalTimeOut:   100,
 		executionTimeout: 100,
+		handlerTimeout:   3 * time.Second,
 	}
 
 	return system
@@ -53,6 +107,9 @@ func (p *System) NodeId() string {
 }
 
 func (p *System) Stop() {
+	// actor停止后会从actorMap中移除,超时时通过停止前的快照统计剩余的消息
+	actors := p.allActors()
+
 	p.actorMap.Range(func(key, value any) bool {
 		actor, ok := value.(*Actor)
 		if ok {
@@ -66,10 +123,66 @@ func (p *System) Stop() {
 	})
 
 	clog.Info("actor system stopping!")
-	p.wg.Wait()
+
+	// actor停止后不再接收新消息,处理完队列中剩余的消息后退出
+	if p.stopTimeout > 0 {
+		done := make(chan struct{})
+		go func() {
+			p.wg.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(p.stopTimeout):
+			local, remote := mailCount(actors)
+			clog.Warnf("actor system stop timeout! [timeout = %v, local = %d, remote = %d]", p.stopTimeout, local, remote)
+			return
+		}
+	} else {
+		p.wg.Wait()
+	}
+
 	clog.Info("actor system stopped!")
 }
 
+// MailCount 所有actor(含子actor)待处理的local、remote消息数量,用于观察消息积压情况
+func (p *System) MailCount() (local, remote int64) {
+	return mailCount(p.allActors())
+}
+
+// allActors 返回所有actor及其子actor
+func (p *System) allActors() []*Actor {
+	var actors []*Actor
+
+	p.actorMap.Range(func(key, value any) bool {
+		thisActor, ok := value.(*Actor)
+		if !ok {
+			return true
+		}
+
+		actors = append(actors, thisActor)
+		thisActor.child.childActors.Range(func(key, value any) bool {
+			if childActor, found := value.(*Actor); found {
+				actors = append(actors, childActor)
+			}
+			return true
+		})
+		return true
+	})
+
+	return actors
+}
+
+func mailCount(actors []*Actor) (local, remote int64) {
+	for _, thisActor := range actors {
+		local += int64(thisActor.localMail.Count())
+		remote += int64(thisActor.remoteMail.Count())
+	}
+
+	return local, remote
+}
+
 // GetIActor 根据ActorID获取IActor
 func (p *System) GetIActor(id string) (cfacade.IActor, bool) {
 	return p.GetActor(id)
@@ -316,49 +429,77 @@ func (p *System) CallWait(source, target, funcName string, arg interface{}, repl
 	return ccode.OK
 }
 
-// PostRemote 提交远程消息
+// PostRemote 提交远程消息,不关心投递失败的原因(fire-and-forget)
 func (p *System) PostRemote(m *cfacade.Message) bool {
-	if m == nil {
-		clog.Error("Message is nil.")
+	if err := p.TryPostRemote(m); err != nil {
+		p.postFail("PostRemote", m, err)
 		return false
 	}
+	return true
+}
 
-	if targetActor, found := p.GetActor(m.TargetPath().ActorID); found {
-		if targetActor.state == WorkerState {
-			targetActor.PostRemote(m)
-		}
-		return true
+// PostLocal 提交本地消息,不关心投递失败的原因(fire-and-forget)
+func (p *System) PostLocal(m *cfacade.Message) bool {
+	if err := p.TryPostLocal(m); err != nil {
+		p.postFail("PostLocal", m, err)
+		return false
 	}
+	return true
+}
 
-	clog.Warnf("[PostRemote] actor not found. [source = %s, target = %s -> %s]",
-		m.Source,
-		m.Target,
-		m.FuncName,
-	)
-	return false
+// TryPostRemote 提交远程消息,返回投递失败的原因
+// cerr.ActorMessageNil: 消息为nil, cerr.ActorNotFound: actor不存在, cerr.ActorNotRunning: actor未启动或已停止
+func (p *System) TryPostRemote(m *cfacade.Message) error {
+	targetActor, err := p.postTarget(m)
+	if err != nil {
+		return err
+	}
+
+	targetActor.PostRemote(m)
+	return nil
 }
 
-// PostLocal 提交本地消息
-func (p *System) PostLocal(m *cfacade.Message) bool {
+// TryPostLocal 提交本地消息,返回投递失败的原因,同TryPostRemote
+func (p *System) TryPostLocal(m *cfacade.Message) error {
+	targetActor, err := p.postTarget(m)
+	if err != nil {
+		return err
+	}
+
+	targetActor.PostLocal(m)
+	return nil
+}
+
+func (p *System) postTarget(m *cfacade.Message) (*Actor, error) {
 	if m == nil {
-		clog.Error("Message is nil.")
-		return false
+		return nil, cerr.ActorMessageNil
 	}
 
-	if targetActor, found := p.GetActor(m.TargetPath().ActorID); found {
-		if targetActor.state == WorkerState {
-			targetActor.PostLocal(m)
-		}
-		return true
+	targetActor, found := p.GetActor(m.TargetPath().ActorID)
+	if !found {
+		return nil, cerr.ActorNotFound
+	}
+
+	if targetActor.state != WorkerState {
+		return nil, cerr.ActorNotRunning
 	}
 
-	clog.Warnf("[PostLocal] actor not found. [source = %s, target = %s -> %s]",
+	return targetActor, nil
+}
+
+func (p *System) postFail(name string, m *cfacade.Message, err error) {
+	if m == nil {
+		clog.Errorf("[%s] %v", name, err)
+		return
+	}
+
+	clog.Warnf("[%s] %v [source = %s, target = %s -> %s]",
+		name,
+		err,
 		m.Source,
 		m.Target,
 		m.FuncName,
 	)
-
-	return false
 }
 
 // PostEvent 提交事件
@@ -390,6 +531,207 @@ func (p *System) SetRemoteInvoke(fn cfacade.InvokeFunc) {
 	}
 }
 
+// SetOnUnhandled 设置消息找不到处理函数(或子actor)时的回调,可用于统计或转发到死信队列
+// 回调返回后message会被回收,需要保存时请复制
+func (p *System) SetOnUnhandled(fn UnhandledFunc) {
+	p.onUnhandledFunc = fn
+}
+
+// SetOnInvokeError 设置函数执行失败(找不到函数、过滤器拒绝、panic)时的回调
+// 回调返回后message会被回收,需要保存时请复制
+func (p *System) SetOnInvokeError(fn InvokeErrorFunc) {
+	p.onInvokeError = fn
+}
+
+func (p *System) unhandled(m *cfacade.Message) {
+	if p.onUnhandledFunc != nil {
+		cutils.Try(func() {
+			p.onUnhandledFunc(m)
+		}, func(errString string) {
+			clog.Warnf("[unhandled] callback error. [source = %s, target = %s -> %s, err = %s]",
+				m.Source,
+				m.Target,
+				m.FuncName,
+				errString,
+			)
+		})
+	}
+}
+
+// AddBeforeFilter 添加函数执行前的过滤器,按添加顺序执行
+// 过滤器返回false时,后续的before过滤器、执行函数及after过滤器都不再执行
+func (p *System) AddBeforeFilter(fn ...FilterFunc) {
+	for _, filter := range fn {
+		boolFilter := filter
+		p.beforeFilters = append(p.beforeFilters, func(m *cfacade.Message) (bool, error) {
+			return boolFilter(m), nil
+		})
+	}
+}
+
+// AddBeforeErrFilter 添加函数执行前的过滤器,与AddBeforeFilter按添加顺序一起执行
+// 过滤器返回false时中断执行,本地request消息以error作为原因响应客户端,
+// error为*FilterError时响应其Code,否则响应ccode.ActorFilterRejected
+func (p *System) AddBeforeErrFilter(fn ...FilterErrFunc) {
+	p.beforeFilters = append(p.beforeFilters, fn...)
+}
+
+// AddAfterFilter 添加函数执行后的过滤器,按添加顺序执行
+// 执行函数panic时after过滤器仍然执行,过滤器返回false时后续的after过滤器不再执行
+func (p *System) AddAfterFilter(fn ...FilterFunc) {
+	p.afterFilters = append(p.afterFilters, fn...)
+}
+
+func (p *System) beforeFilter(m *cfacade.Message) (bool, error) {
+	for _, filter := range p.beforeFilters {
+		if ok, err := filter(m); !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// invokeError 记录函数执行失败的原因并响应调用方
+// 函数panic时由invoker响应,此处只响应找不到函数及过滤器拒绝的消息
+func (p *System) invokeError(mbName string, app cfacade.IApplication, m *cfacade.Message, err error) {
+	var (
+		code      int32
+		data      []byte
+		filterErr *FilterError
+	)
+
+	switch {
+	case errors.As(err, &filterErr):
+		clog.Debugf("[%s] Filter rejected. [source = %s, target = %s -> %s, sid = %s, uid = %d, err = %v]",
+			mbName,
+			m.Source,
+			m.Target,
+			m.FuncName,
+			m.Session.GetSid(),
+			m.Session.GetUid(),
+			err,
+		)
+
+		code = ccode.ActorFilterRejected
+		if ccode.IsFail(filterErr.Code) {
+			code = filterErr.Code
+		}
+		if filterErr.Err != nil {
+			data = []byte(filterErr.Error())
+		}
+	case errors.Is(err, cerr.ActorFuncNotFound):
+		clog.Warnf("[%s] Function not found. [source = %s, target = %s -> %s, sid = %s, uid = %d]",
+			mbName,
+			m.Source,
+			m.Target,
+			m.FuncName,
+			m.Session.GetSid(),
+			m.Session.GetUid(),
+		)
+		code = ccode.RouteNotFound
+	}
+
+	if code != ccode.OK {
+		if mbName == LocalName {
+			if app != nil {
+				localResponse(app, m, code, data)
+			}
+		} else if m.IsCluster {
+			retResponse(m.ClusterReply, &cproto.Response{Code: code})
+		} else if m.ChanResult != nil {
+			m.ChanResult <- &cproto.Response{Code: code}
+		}
+	}
+
+	if p.onInvokeError != nil {
+		cutils.Try(func() {
+			p.onInvokeError(m, err)
+		}, func(errString string) {
+			clog.Warnf("[invokeError] callback error. [source = %s, target = %s -> %s, err = %s]",
+				m.Source,
+				m.Target,
+				m.FuncName,
+				errString,
+			)
+		})
+	}
+}
+
+func (p *System) afterFilter(m *cfacade.Message) {
+	cutils.Try(func() {
+		for _, filter := range p.afterFilters {
+			if !filter(m) {
+				return
+			}
+		}
+	}, func(errString string) {
+		clog.Warnf("[afterFilter] filter error. [source = %s, target = %s -> %s, err = %s]",
+			m.Source,
+			m.Target,
+			m.FuncName,
+			errString,
+		)
+	})
+}
+
+// SerializeRoute 匹配pattern的路由在所有actor(含子actor)中串行执行,其他路由不受影响
+// 路由为"actorID.funcName",pattern支持通配符,如"match.*"匹配match actor的所有函数,"*.join"匹配所有actor的join函数
+// 每个pattern独立串行,需在actor启动前设置;串行执行的函数中不要CallWait同一pattern的函数,否则会死锁
+// 函数仍在actor自身的goroutine中执行(保证actor数据只被一个goroutine访问),等待lane时该actor的其他消息也会等待
+// 等待lane的时间不计入执行耗时(executionTimeout、metrics)及handler的deadline
+func (p *System) SerializeRoute(patterns ...string) {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			clog.Warnf("[SerializeRoute] pattern error. [pattern = %s, err = %v]", pattern, err)
+			continue
+		}
+
+		p.routeLanes = append(p.routeLanes, &routeLane{pattern: pattern})
+	}
+}
+
+// routeLane 返回路由所属的串行lane,未匹配时返回nil
+func (p *System) routeLane(m *cfacade.Message) *routeLane {
+	if len(p.routeLanes) < 1 {
+		return nil
+	}
+
+	route := invokeRoute(m)
+	for _, lane := range p.routeLanes {
+		if matched, _ := path.Match(lane.pattern, route); matched {
+			return lane
+		}
+	}
+
+	return nil
+}
+
+// SetMetrics 设置函数执行的指标收集,如NewInvokeStats()
+func (p *System) SetMetrics(metrics IInvokeMetrics) {
+	p.metrics = metrics
+}
+
+// SetStopTimeout 设置停止时等待actor处理完剩余消息的超时时间
+func (p *System) SetStopTimeout(d time.Duration) {
+	p.stopTimeout = d
+}
+
+// SetHandlerTimeout 设置函数执行上下文的超时时间(默认3秒),0为不设置deadline
+// 函数可通过Actor.Context()监听Done()提前结束,过滤器通过m.Context()获取
+func (p *System) SetHandlerTimeout(d time.Duration) {
+	if d >= 0 {
+		p.handlerTimeout = d
+	}
+}
+
+// messageContext 创建执行函数的上下文
+func (p *System) messageContext(m *cfacade.Message) (context.Context, context.CancelFunc) {
+	if p.handlerTimeout > 0 {
+		return context.WithTimeout(m.Context(), p.handlerTimeout)
+	}
+	return context.WithCancel(m.Context())
+}
+
 func (p *System) SetCallTimeout(d time.Duration) {
 	p.callTimeout = d
 }
@@ -2,16 +2,44 @@ package cherryCluster
 
 import (
 	cfacade "github.com/cherry-game/cherry/facade"
+	clog "github.com/cherry-game/cherry/logger"
 	cherryNatsCluster "github.com/cherry-game/cherry/net/cluster/nats_cluster"
+	cprofile "github.com/cherry-game/cherry/profile"
 )
 
 const (
-	Name = "cluster_component"
+	Name        = "cluster_component"
+	DefaultMode = "nats"
 )
 
-type Component struct {
-	cfacade.Component
-	cfacade.ICluster
+type (
+	Component struct {
+		cfacade.Component
+		cfacade.ICluster
+	}
+
+	// NewClusterFunc 创建集群通信实例的函数
+	NewClusterFunc func(app cfacade.IApplication) cfacade.ICluster
+)
+
+var (
+	clusterMap = make(map[string]NewClusterFunc)
+)
+
+func init() {
+	Register(DefaultMode, func(app cfacade.IApplication) cfacade.ICluster {
+		return cherryNatsCluster.New(app)
+	})
+}
+
+// Register 注册集群通信实现,通过profile的"cluster"->"mode"进行选择
+func Register(mode string, fn NewClusterFunc) {
+	if mode == "" || fn == nil {
+		clog.Fatalf("Register cluster fail. [mode = %s]", mode)
+		return
+	}
+
+	clusterMap[mode] = fn
 }
 
 func New() *Component {
@@ -32,5 +60,13 @@ func (c *Component) OnStop() {
 }
 
 func (c *Component) loadCluster() cfacade.ICluster {
-	return cherryNatsCluster.New(c.App())
+	mode := cprofile.GetConfig("cluster").GetString("mode", DefaultMode)
+
+	newFunc, found := clusterMap[mode]
+	if !found {
+		clog.Panicf("mode = %s property not found in cluster config.", mode)
+	}
+
+	clog.Infof("Select cluster [mode = %s].", mode)
+	return newFunc(c.App())
 }
@@ -0,0 +1,41 @@
+package cherryConnector
+
+import (
+	"net"
+	"time"
+)
+
+type (
+	// writeTimeoutConn 每次写入前设置写超时,防止对端接收窗口为0时写入一直阻塞
+	writeTimeoutConn struct {
+		net.Conn
+		writeTimeout time.Duration
+	}
+)
+
+// NewWriteTimeoutConn return a net.Conn which set the write deadline before each write.
+// return conn itself when writeTimeout < 1
+func NewWriteTimeoutConn(conn net.Conn, writeTimeout time.Duration) net.Conn {
+	if writeTimeout <= 0 {
+		return conn
+	}
+
+	return &writeTimeoutConn{
+		Conn:         conn,
+		writeTimeout: writeTimeout,
+	}
+}
+
+func (c *writeTimeoutConn) Write(b []byte) (int, error) {
+	if err := c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout)); err != nil {
+		return 0, err
+	}
+
+	return c.Conn.Write(b)
+}
+
+// IsTimeout returns true if the error is a net timeout error
+func IsTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
@@ -1,15 +1,19 @@
 package cherryConnector
 
 import (
+	"net"
+	"time"
+
 	clog "github.com/cherry-game/cherry/logger"
 )
 
 type (
 	Options struct {
-		address  string
-		certFile string
-		keyFile  string
-		chanSize int
+		address      string
+		certFile     string
+		keyFile      string
+		chanSize     int
+		writeTimeout time.Duration
 	}
 
 	Option func(*Options)
@@ -33,3 +37,16 @@ func WithChanSize(size int) Option {
 		}
 	}
 }
+
+// WithWriteTimeout set the write deadline of each write on the accepted connection
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		if timeout > 0 {
+			o.writeTimeout = timeout
+		}
+	}
+}
+
+func (o *Options) wrapConn(conn net.Conn) net.Conn {
+	return NewWriteTimeoutConn(conn, o.writeTimeout)
+}
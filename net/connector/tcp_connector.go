@@ -68,7 +68,7 @@ func (t *TCPConnector) Start() {
 			continue
 		}
 
-		t.InChan(conn)
+		t.InChan(t.wrapConn(conn))
 	}
 }
 
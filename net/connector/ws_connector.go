@@ -103,7 +103,7 @@ func (w *WSConnector) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	}
 
 	conn := NewWSConn(wsConn)
-	w.InChan(&conn)
+	w.InChan(w.wrapConn(&conn))
 }
 
 // NewWSConn return an initialized *WSConn
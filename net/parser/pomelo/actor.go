@@ -99,6 +99,10 @@ func (*actor) SetDataCompression(compression bool) {
 	pomeloMessage.SetDataCompression(compression)
 }
 
+func (*actor) SetDataCompressionThreshold(threshold int) {
+	pomeloMessage.SetDataCompressionThreshold(threshold)
+}
+
 func (*actor) SetWriteBacklog(size int) {
 	cmd.writeBacklog = size
 }
@@ -124,6 +128,52 @@ func (*actor) SetOnDataRoute(fn DataRouteFunc) {
 	}
 }
 
+// AddDataRoute 注册匹配pattern的路由处理函数,如"game.*.test*"、"chat.**",按注册顺序匹配
+func (*actor) AddDataRoute(pattern string, fn DataRouteFunc) {
+	if pattern == "" || fn == nil {
+		return
+	}
+
+	cmd.routeMatchers = append(cmd.routeMatchers, routeMatcher{
+		pattern: pattern,
+		fn:      fn,
+	})
+}
+
+// SetChildID 设置本地消息路由的子actor id,例如按uid取模将多个session分配到固定的子actor
+func (*actor) SetChildID(fn ChildIDFunc) {
+	if fn != nil {
+		cmd.childIDFunc = fn
+	}
+}
+
+// SetRateLimit 设置路由每秒允许的消息数量,超过限制的消息被丢弃,request消息响应ccode.RouteRateLimited
+// 可以在运行时调用,重新设置时令牌桶重置(perSession时已创建的session令牌桶不变)
+// perSession为true时每个session独立计数,否则所有session共享计数
+func (*actor) SetRateLimit(route string, perSecond int, perSession bool) {
+	if perSecond < 1 {
+		clog.Warnf("Rate limit perSecond must be greater than 0. [route = %s, perSecond = %d]", route, perSecond)
+		return
+	}
+
+	cmd.rateLimits.set(route, &rateLimit{
+		perSecond:  perSecond,
+		perSession: perSession,
+		bucket:     newTokenBucket(perSecond),
+	})
+}
+
+// SetFragmentLimit 启用分片包重组,默认不接收分片包(收到时断开连接)
+// maxSize为重组后的包最大长度,maxBytes为每个agent未收齐的分片缓存的最大字节数,maxGroups为每个agent未收齐的分片组最大数量
+// maxBytes、maxGroups为0时使用pomeloPacket.MaxFragmentBytes、pomeloPacket.MaxFragmentGroups
+func (*actor) SetFragmentLimit(maxSize, maxBytes, maxGroups int) {
+	cmd.fragmentLimit = fragmentLimit{
+		maxSize:   maxSize,
+		maxBytes:  maxBytes,
+		maxGroups: maxGroups,
+	}
+}
+
 func (*actor) SetOnPacket(typ ppacket.Type, fn PacketFunc) {
 	cmd.onPacketFuncMap[typ] = fn
 }
@@ -142,6 +192,7 @@ func (p *actor) response(rsp *cproto.PomeloResponse) {
 	} else {
 		errRsp := &cproto.Response{
 			Code: rsp.Code,
+			Data: rsp.Data,
 		}
 		agent.ResponseMID(rsp.Mid, errRsp, true)
 	}
@@ -171,17 +222,23 @@ func (p *actor) kick(rsp *cproto.PomeloKick) {
 }
 
 func (p *actor) broadcast(rsp *cproto.PomeloBroadcastPush) {
+	var agentList []*Agent
+
 	if rsp.AllUID {
 		ForeachAgent(func(agent *Agent) {
 			if agent.IsBind() {
-				agent.Push(rsp.Route, rsp.Data)
+				agentList = append(agentList, agent)
 			}
 		})
 	} else {
 		for _, uid := range rsp.UidList {
 			if agent, found := GetAgentWithUID(uid); found {
-				agent.Push(rsp.Route, rsp.Data)
+				agentList = append(agentList, agent)
 			}
 		}
 	}
+
+	for _, err := range BroadcastPush(agentList, rsp.Route, rsp.Data) {
+		clog.Warnf("[broadcast] push fail. [route = %s, err = %v]", rsp.Route, err)
+	}
 }
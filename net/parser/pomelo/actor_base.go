@@ -1,6 +1,10 @@
 package pomelo
 
 import (
+	"sync/atomic"
+
+	ccode "github.com/cherry-game/cherry/code"
+	cerr "github.com/cherry-game/cherry/error"
 	cfacade "github.com/cherry-game/cherry/facade"
 	clog "github.com/cherry-game/cherry/logger"
 	cactor "github.com/cherry-game/cherry/net/actor"
@@ -34,10 +38,77 @@ func (p *ActorBase) Kick(session *cproto.Session, reason interface{}, closed boo
 	Kick(p, session.AgentPath, session.Sid, reason, closed)
 }
 
+// Responder 保存当前请求的响应信息,可在其他goroutine中(如异步IO完成后)响应请求
+func (p *ActorBase) Responder(session *cproto.Session) *Responder {
+	return NewResponder(p, session)
+}
+
 func (p *ActorBase) Broadcast(agentPath string, uidList []int64, allUID bool, route string, data []byte) {
 	Broadcast(p, agentPath, uidList, allUID, route, data)
 }
 
+type Responder struct {
+	iActor    cfacade.IActor
+	agentPath string
+	sid       string
+	mid       uint32
+	responded int32
+}
+
+// NewResponder 复制session中的agentPath、sid、mid,session被修改或回收后仍然可以响应
+func NewResponder(iActor cfacade.IActor, session *cproto.Session) *Responder {
+	return &Responder{
+		iActor:    iActor,
+		agentPath: session.AgentPath,
+		sid:       session.Sid,
+		mid:       session.Mid,
+	}
+}
+
+// Respond 序列化v并响应请求,每个请求只能响应一次
+func (p *Responder) Respond(v interface{}) error {
+	data, err := p.iActor.App().Serializer().Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return p.RespondRaw(data)
+}
+
+// RespondRaw 响应已序列化的数据
+func (p *Responder) RespondRaw(data []byte) error {
+	return p.call(&cproto.PomeloResponse{
+		Sid:  p.sid,
+		Mid:  p.mid,
+		Data: data,
+	})
+}
+
+// RespondCode 响应错误码
+func (p *Responder) RespondCode(statusCode int32) error {
+	return p.call(&cproto.PomeloResponse{
+		Sid:  p.sid,
+		Mid:  p.mid,
+		Code: statusCode,
+	})
+}
+
+func (p *Responder) call(rsp *cproto.PomeloResponse) error {
+	if p.mid < 1 {
+		return cerr.Errorf("Message is not a request. [sid = %s]", p.sid)
+	}
+
+	if !atomic.CompareAndSwapInt32(&p.responded, 0, 1) {
+		return cerr.Errorf("Request already responded. [sid = %s, mid = %d]", p.sid, p.mid)
+	}
+
+	if code := p.iActor.Call(p.agentPath, ResponseFuncName, rsp); ccode.IsFail(code) {
+		return cerr.Errorf("Response fail. [sid = %s, mid = %d, code = %d]", p.sid, p.mid, code)
+	}
+
+	return nil
+}
+
 func Response(iActor cfacade.IActor, agentPath, sid string, mid uint32, v interface{}) {
 	data, err := iActor.App().Serializer().Marshal(v)
 	if err != nil {
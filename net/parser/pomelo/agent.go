@@ -3,13 +3,16 @@ package pomelo
 import (
 	"fmt"
 	"net"
+	"sync"
 	"sync/atomic"
 	"time"
 
+	cerr "github.com/cherry-game/cherry/error"
 	cnet "github.com/cherry-game/cherry/extend/net"
 	cutils "github.com/cherry-game/cherry/extend/utils"
 	cfacade "github.com/cherry-game/cherry/facade"
 	clog "github.com/cherry-game/cherry/logger"
+	cconnector "github.com/cherry-game/cherry/net/connector"
 	pomeloMessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
 	pomeloPacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
 	cproto "github.com/cherry-game/cherry/net/proto"
@@ -25,15 +28,19 @@ const (
 
 type (
 	Agent struct {
-		cfacade.IApplication                      // app
-		conn                 net.Conn             // low-level conn fd
-		state                int32                // current agent state
-		session              *cproto.Session      // session
-		chDie                chan struct{}        // wait for close
-		chPending            chan *pendingMessage // push message queue
-		chWrite              chan []byte          // push bytes queue
-		lastAt               int64                // last heartbeat unix time stamp
-		onCloseFunc          []OnCloseFunc        // on close agent
+		cfacade.IApplication                         // app
+		conn                 net.Conn                // low-level conn fd
+		state                int32                   // current agent state
+		session              *cproto.Session         // session
+		chDie                chan struct{}           // wait for close
+		chPending            chan *pendingMessage    // push message queue
+		chWrite              chan []byte             // push bytes queue
+		chLock               *sync.RWMutex           // guard chClosed and the send of chPending and chWrite
+		chClosed             bool                    // chPending and chWrite are closed
+		lastAt               int64                   // last heartbeat unix time stamp
+		onCloseFunc          []OnCloseFunc           // on close agent
+		fragments            *pomeloPacket.Fragments // reassemble fragment packets
+		buckets              map[string]*tokenBucket // rate limit token bucket of session, key:route
 	}
 
 	pendingMessage struct {
@@ -42,6 +49,7 @@ type (
 		mid     uint               // response message id(response)
 		payload interface{}        // payload
 		err     bool               // if it's an error
+		raw     []byte             // encoded packet, broadcast时只编码一次,不再序列化payload
 	}
 
 	OnCloseFunc func(*Agent)
@@ -56,8 +64,10 @@ func NewAgent(app cfacade.IApplication, conn net.Conn, session *cproto.Session)
 		chDie:        make(chan struct{}),
 		chPending:    make(chan *pendingMessage, cmd.writeBacklog),
 		chWrite:      make(chan []byte, cmd.writeBacklog),
+		chLock:       &sync.RWMutex{},
 		lastAt:       0,
 		onCloseFunc:  nil,
+		fragments:    newFragments(),
 	}
 
 	agent.session.Ip = agent.RemoteAddr()
@@ -75,8 +85,20 @@ func NewAgent(app cfacade.IApplication, conn net.Conn, session *cproto.Session)
 	return agent
 }
 
+// newFragments 未通过SetFragmentLimit启用分片时返回nil,避免客户端发送分片占用内存
+func newFragments() *pomeloPacket.Fragments {
+	limit := cmd.fragmentLimit
+	if limit.maxSize < 1 {
+		return nil
+	}
+
+	fragments := pomeloPacket.NewFragments(pomeloPacket.FragmentTimeout)
+	fragments.SetLimit(limit.maxSize, limit.maxBytes, limit.maxGroups)
+	return fragments
+}
+
 func (a *Agent) State() int32 {
-	return a.state
+	return atomic.LoadInt32(&a.state)
 }
 
 func (a *Agent) SetState(state int32) bool {
@@ -133,12 +155,52 @@ func (a *Agent) SetLastAt() {
 	atomic.StoreInt64(&a.lastAt, time.Now().Unix())
 }
 
+// SendRaw 发送队列已满时阻塞,直到发送成功或agent关闭
+// 持有读锁发送,避免与closeProcess关闭chWrite并发导致panic
 func (a *Agent) SendRaw(bytes []byte) {
-	a.chWrite <- bytes
+	a.chLock.RLock()
+	defer a.chLock.RUnlock()
+
+	if a.chClosed || a.State() == AgentClosed {
+		clog.Warnf("[sid = %s,uid = %d] Agent is closed, drop raw bytes. [len = %d]", a.SID(), a.UID(), len(bytes))
+		return
+	}
+
+	select {
+	case a.chWrite <- bytes:
+	case <-a.chDie:
+	}
+}
+
+// trySendRaw 通过chPending发送已编码的数据包,与Push、Response的消息保持顺序
+// 发送队列已满或agent已关闭时返回error,不阻塞(与Push一致,丢弃该消息)
+func (a *Agent) trySendRaw(bytes []byte) error {
+	return a.tryPending(&pendingMessage{
+		typ: pomeloMessage.Push,
+		raw: bytes,
+	})
+}
+
+// tryPending 发送队列已满或agent已关闭时返回error,不阻塞
+// 持有读锁发送,避免与closeProcess关闭chPending并发导致panic
+func (a *Agent) tryPending(pending *pendingMessage) error {
+	a.chLock.RLock()
+	defer a.chLock.RUnlock()
+
+	if a.chClosed || a.State() == AgentClosed {
+		return cerr.Errorf("[sid = %s,uid = %d] agent is closed.", a.SID(), a.UID())
+	}
+
+	select {
+	case a.chPending <- pending:
+		return nil
+	default:
+		return cerr.Errorf("[sid = %s,uid = %d] send buffer exceed.", a.SID(), a.UID())
+	}
 }
 
 func (a *Agent) SendPacket(typ pomeloPacket.Type, data []byte) {
-	pkg, err := pomeloPacket.Encode(typ, data)
+	pkg, err := pomeloPacket.EncodeFragments(typ, data)
 	if err != nil {
 		clog.Warn(err)
 		return
@@ -197,8 +259,9 @@ func (a *Agent) writeChan() {
 		}
 
 		ticker.Stop()
-		a.closeProcess()
+		// 先关闭chDie,唤醒阻塞在SendRaw的goroutine,closeProcess才能获取写锁
 		a.Close()
+		a.closeProcess()
 	}()
 
 	for {
@@ -257,14 +320,22 @@ func (a *Agent) closeProcess() {
 		)
 	}
 
+	a.chLock.Lock()
+	a.chClosed = true
 	close(a.chPending)
 	close(a.chWrite)
+	a.chLock.Unlock()
 }
 
 func (a *Agent) write(bytes []byte) {
 	_, err := a.conn.Write(bytes)
 	if err != nil {
 		clog.Warn(err)
+
+		// write timeout, the peer is stuck
+		if cconnector.IsTimeout(err) {
+			a.Close()
+		}
 	}
 }
 
@@ -299,7 +370,13 @@ func (p *pendingMessage) String() string {
 	return fmt.Sprintf("typ = %d, route = %s, mid = %d, payload = %v", p.typ, p.route, p.mid, p.payload)
 }
 
+// processPending 在writeChan中执行,直接写入conn,避免写入chWrite时阻塞自身
 func (a *Agent) processPending(data *pendingMessage) {
+	if data.raw != nil {
+		a.write(data.raw)
+		return
+	}
+
 	payload, err := a.Serializer().Marshal(data.payload)
 	if err != nil {
 		clog.Warnf("[sid = %s,uid = %d] Payload marshal error. [data = %s]",
@@ -327,7 +404,13 @@ func (a *Agent) processPending(data *pendingMessage) {
 	}
 
 	// encode packet
-	a.SendPacket(pomeloPacket.Data, em)
+	pkg, err := pomeloPacket.EncodeFragments(pomeloPacket.Data, em)
+	if err != nil {
+		clog.Warn(err)
+		return
+	}
+
+	a.write(pkg)
 }
 
 func (a *Agent) sendPending(typ pomeloMessage.Type, route string, mid uint32, v interface{}, isError bool) {
@@ -344,19 +427,6 @@ func (a *Agent) sendPending(typ pomeloMessage.Type, route string, mid uint32, v
 		return
 	}
 
-	if len(a.chPending) >= cmd.writeBacklog {
-		clog.Warnf("[sid = %s,uid = %d] send buffer exceed. [typ = %v, route = %s, mid = %d, val = %+v, err = %v]",
-			a.SID(),
-			a.UID(),
-			typ,
-			route,
-			mid,
-			v,
-			isError,
-		)
-		return
-	}
-
 	pending := &pendingMessage{
 		typ:     typ,
 		mid:     uint(mid),
@@ -365,7 +435,16 @@ func (a *Agent) sendPending(typ pomeloMessage.Type, route string, mid uint32, v
 		err:     isError,
 	}
 
-	a.chPending <- pending
+	if err := a.tryPending(pending); err != nil {
+		clog.Warnf("%v [typ = %v, route = %s, mid = %d, val = %+v, err = %v]",
+			err,
+			typ,
+			route,
+			mid,
+			v,
+			isError,
+		)
+	}
 }
 
 func (a *Agent) Response(session *cproto.Session, v interface{}, isError ...bool) {
@@ -6,6 +6,8 @@ import (
 	cerr "github.com/cherry-game/cherry/error"
 	cfacade "github.com/cherry-game/cherry/facade"
 	clog "github.com/cherry-game/cherry/logger"
+	pmessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	ppacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
 )
 
 var (
@@ -104,3 +106,46 @@ func Count() int {
 
 	return len(sidAgentMap)
 }
+
+// BroadcastPush 推送消息给多个agent,消息只序列化和编码一次
+// 消息与Push一样进入chPending,保持与该agent其他消息的顺序
+// 发送队列已满时不阻塞,丢弃该agent的消息(与Push一致),已关闭或队列已满的agent返回对应的error,不影响其他agent的发送
+func BroadcastPush(agentList []*Agent, route string, val interface{}) []error {
+	if len(agentList) < 1 {
+		return nil
+	}
+
+	pkg, err := encodePush(agentList[0].Serializer(), route, val)
+	if err != nil {
+		return []error{err}
+	}
+
+	var errs []error
+	for _, agent := range agentList {
+		if err = agent.trySendRaw(pkg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+func encodePush(serializer cfacade.ISerializer, route string, val interface{}) ([]byte, error) {
+	payload, err := serializer.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &pmessage.Message{
+		Type:  pmessage.Push,
+		Route: route,
+		Data:  payload,
+	}
+
+	em, err := pmessage.Encode(m)
+	if err != nil {
+		return nil, err
+	}
+
+	return ppacket.EncodeFragments(ppacket.Data, em)
+}
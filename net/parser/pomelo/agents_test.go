@@ -0,0 +1,89 @@
+package pomelo
+
+import (
+	"net"
+	"sync"
+	"testing"
+
+	cfacade "github.com/cherry-game/cherry/facade"
+	pomeloPacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+	cproto "github.com/cherry-game/cherry/net/proto"
+	cserializer "github.com/cherry-game/cherry/net/serializer"
+)
+
+type testApp struct {
+	cfacade.IApplication
+	serializer cfacade.ISerializer
+}
+
+func (p *testApp) Serializer() cfacade.ISerializer {
+	return p.serializer
+}
+
+func newTestAgent(sid string) *Agent {
+	conn, _ := net.Pipe()
+	agent := NewAgent(&testApp{serializer: cserializer.NewJSON()}, conn, &cproto.Session{Sid: sid})
+	return &agent
+}
+
+func TestBroadcastPush(t *testing.T) {
+	healthy := newTestAgent("1")
+	closed := newTestAgent("2")
+	closed.Close()
+	closed.closeProcess()
+
+	errs := BroadcastPush([]*Agent{healthy, closed}, "room.chat", map[string]string{"msg": "hello"})
+	if len(errs) != 1 {
+		t.Fatalf("errs = %v", errs)
+	}
+
+	if len(healthy.chPending) != 1 {
+		t.Fatalf("healthy agent pending queue = %d", len(healthy.chPending))
+	}
+}
+
+func TestBroadcastPushOrder(t *testing.T) {
+	agent := newTestAgent("1")
+	agent.SetState(AgentWorking)
+
+	agent.Push("room.first", 1)
+	BroadcastPush([]*Agent{agent}, "room.chat", 2)
+	agent.Push("room.last", 3)
+
+	first, broadcast, last := <-agent.chPending, <-agent.chPending, <-agent.chPending
+	if first.route != "room.first" || broadcast.raw == nil || last.route != "room.last" {
+		t.Fatalf("broadcast overtake the push. [%s, %s, %s]", first, broadcast, last)
+	}
+}
+
+func TestSendRawClosed(t *testing.T) {
+	agent := newTestAgent("1")
+	agent.Close()
+	agent.closeProcess()
+
+	// must not panic on the closed channel
+	agent.SendRaw([]byte{1})
+	agent.SendPacket(pomeloPacket.Heartbeat, nil)
+}
+
+func TestBroadcastPushClosing(t *testing.T) {
+	agentList := []*Agent{newTestAgent("1"), newTestAgent("2")}
+
+	// the agent is closing while broadcasting, the send must not panic on the closed channel
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			BroadcastPush(agentList, "room.chat", i)
+		}
+	}()
+
+	agentList[1].Close()
+	agentList[1].closeProcess()
+	wg.Wait()
+
+	if err := agentList[1].trySendRaw([]byte{}); err == nil {
+		t.Fatal("send to the closed agent")
+	}
+}
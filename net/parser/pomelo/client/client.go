@@ -1,7 +1,10 @@
 package pomeloClient
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
+	"math/rand"
 	"net"
 	"net/url"
 	"runtime/debug"
@@ -11,6 +14,7 @@ import (
 
 	cerr "github.com/cherry-game/cherry/error"
 	ccompress "github.com/cherry-game/cherry/extend/compress"
+	cfacade "github.com/cherry-game/cherry/facade"
 	clog "github.com/cherry-game/cherry/logger"
 	cconnector "github.com/cherry-game/cherry/net/connector"
 	pomeloMessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
@@ -25,31 +29,89 @@ type (
 	// Client struct
 	Client struct {
 		options
-		TagName       string         // 客户标识
-		conn          net.Conn       // 连接对象
-		connected     bool           // 是否连接
-		responseMaps  sync.Map       // 响应消息队列 key:ID, value: chan *Message
-		pushBindMaps  sync.Map       // push消息绑定列表 key:route, value:OnMessageFn
-		nextID        uint32         // 消息自增id
-		closeChan     chan struct{}  // 关闭chan
-		actionChan    chan ActionFn  // 动作执行队列
-		handshakeData *HandshakeData // handshake data
+		TagName        string                      // 客户标识
+		OnConnected    func()                      // 连接(重连)成功后回调,nil则忽略
+		OnDisconnected func(err error)             // 断开连接后回调,err为导致断开的错误(主动断开时为nil)
+		OnKicked       func(reason []byte)         // 被服务端踢下线时回调,reason为kick包的数据(服务端序列化的原因)
+		conn           net.Conn                    // 连接对象
+		connMutex      sync.RWMutex                // 保护conn及握手后确定的serializer、handshakeData、heartBeat,重连时被替换
+		connected      int32                       // 是否连接
+		closeOnce      sync.Once                   // 关闭closeChan
+		responseMaps   sync.Map                    // 响应消息队列 key:ID, value: chan *Message
+		pushBindMaps   sync.Map                    // push消息绑定列表 key:route, value:OnMessageFn
+		nextID         uint32                      // 消息自增id
+		closeChan      chan struct{}               // 关闭chan
+		actionChan     chan ActionFn               // 动作执行队列
+		handshakeData  *HandshakeData              // handshake data
+		fragments      *pomeloPacket.Fragments     // 分片重组
+		dialFn         DialFn                      // 建立连接函数,断线重连时使用
+		reconnecting   int32                       // 是否正在重连
+		reconnectChan  chan ReconnectEvent         // 重连结果通知
+		writeMutex     sync.Mutex                  // 写锁,重连时缓存待发送的数据
+		writeQueue     [][]byte                    // 重连期间待发送的数据
+		inflightChan   chan struct{}               // 并发请求数限制
+		lastAt         int64                       // 最后收到数据包的时间(unix milli)
+		pushChan       chan pushItem               // push消息队列,见WithPushBacklog
+		pushDropped    int64                       // push消息队列已满丢弃的数量
+		closing        int32                       // 正在优雅关闭,不再接受新的发送
+		pushOutChan    chan *pomeloMessage.Message // 未注册On(route)的push消息,见WithPushChan
+		pushSeq        uint64                      // 已收到的push消息序号,只在读取goroutine中递增
+		pushDelivered  uint64                      // 最后一次分发的push消息序号
+	}
+
+	// pushItem push消息及其接收序号,分发时检查序号递增,保证按服务端发送的顺序分发
+	pushItem struct {
+		seq uint64
+		msg *pomeloMessage.Message
 	}
 
 	ActionFn    func() error
 	OnMessageFn func(msg *pomeloMessage.Message)
+	DialFn      func() (net.Conn, error)
+
+	// OutgoingMessage the message of SendBatch
+	OutgoingMessage struct {
+		Type       pomeloMessage.Type                          // Request or Notify
+		Route      string                                      // message route
+		Val        interface{}                                 // serialized by the client serializer
+		OnResponse func(rsp *pomeloMessage.Message, err error) // response callback of the request, nil is ignored
+	}
+
+	// ReconnectEvent 断线重连结果
+	ReconnectEvent struct {
+		Succeed bool  // 是否重连成功
+		Retries int   // 重试次数
+		Err     error // 最后一次失败的错误
+	}
+)
+
+const (
+	DefaultMaxPacketSize = 64 * 1024       // 64kb, the header exceed it is rejected before the data buffer is allocated
+	LoggerName           = "pomelo_client" // the name of the sub logger, its level can be set by the levels of the logger config
+
+	maxReconnectDelay = time.Minute
+	kickDrainTimeout  = 500 * time.Millisecond // 被踢下线后继续读取已到达数据包的最长时间
 )
 
+// logger returns the sub logger of the client
+func logger() *clog.CherryLogger {
+	return clog.Named(LoggerName)
+}
+
 // New returns a new client
 func New(opts ...Option) *Client {
 	client := &Client{
 		TagName:   "client",
-		connected: false,
+		connected: 0,
 		options: options{
 			serializer:     cserializer.NewProtobuf(),
 			heartBeat:      30,
+			heartBeatTimes: 2,
 			requestTimeout: 10 * time.Second,
 			isErrorBreak:   true,
+			metrics:        noopMetrics{},
+			codec:          NewPomeloCodec(),
+			maxPacketSize:  DefaultMaxPacketSize,
 		},
 		responseMaps:  sync.Map{},
 		pushBindMaps:  sync.Map{},
@@ -57,77 +119,256 @@ func New(opts ...Option) *Client {
 		closeChan:     make(chan struct{}),
 		actionChan:    make(chan ActionFn, 128),
 		handshakeData: &HandshakeData{},
+		fragments:     pomeloPacket.NewFragments(pomeloPacket.FragmentTimeout),
+		reconnectChan: make(chan ReconnectEvent, 8),
 	}
 
 	for _, opt := range opts {
 		opt(&client.options)
 	}
 
+	if client.maxPacketSize > 0 {
+		// the reassembled fragments are also limited by maxPacketSize
+		client.fragments.SetLimit(client.maxPacketSize, 0, 0)
+	}
+
+	if client.maxInflight > 0 {
+		client.inflightChan = make(chan struct{}, client.maxInflight)
+	}
+
+	if client.pushBacklog > 0 {
+		client.pushChan = make(chan pushItem, client.pushBacklog)
+	}
+
+	if client.pushChanSize > 0 {
+		client.pushOutChan = make(chan *pomeloMessage.Message, client.pushChanSize)
+	}
+
 	return client
 }
 
 func (p *Client) ConnectToWS(addr string, path string, tlsConfig ...*tls.Config) error {
+	return p.ConnectToWSContext(context.Background(), addr, path, tlsConfig...)
+}
+
+// ConnectToWSContext connect to the websocket server, the dial and handshake are aborted when ctx is done
+func (p *Client) ConnectToWSContext(ctx context.Context, addr string, path string, tlsConfig ...*tls.Config) error {
 	u := url.URL{
 		Scheme: "ws",
 		Host:   addr,
 		Path:   path,
 	}
 
-	dialer := websocket.DefaultDialer
-	if len(tlsConfig) > 0 {
-		dialer.TLSClientConfig = tlsConfig[0]
+	dialer := *websocket.DefaultDialer
+	dialer.NetDialContext = p.netDialer().DialContext
+	if p.dialTimeout > 0 {
+		dialer.HandshakeTimeout = p.dialTimeout
+	}
+
+	if config := p.getTLSConfig(tlsConfig); config != nil {
+		dialer.TLSClientConfig = config
 		u.Scheme = "wss"
 	}
 
-	conn, _, err := dialer.Dial(u.String(), nil)
+	return p.connectContext(ctx, func(ctx context.Context) (net.Conn, error) {
+		conn, _, err := dialer.DialContext(ctx, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		wsConn := cconnector.NewWSConn(conn)
+		return &wsConn, nil
+	})
+}
+
+func (p *Client) ConnectToTCP(addr string, tlsConfig ...*tls.Config) error {
+	return p.ConnectToTCPContext(context.Background(), addr, tlsConfig...)
+}
+
+// ConnectToTCPContext connect to the tcp server, the dial and handshake are aborted when ctx is done
+func (p *Client) ConnectToTCPContext(ctx context.Context, addr string, tlsConfig ...*tls.Config) error {
+	config := p.getTLSConfig(tlsConfig)
+
+	return p.connectContext(ctx, func(ctx context.Context) (net.Conn, error) {
+		if config != nil {
+			dialer := &tls.Dialer{
+				NetDialer: p.netDialer(),
+				Config:    config,
+			}
+			return dialer.DialContext(ctx, "tcp", addr)
+		}
+
+		return p.netDialer().DialContext(ctx, "tcp", addr)
+	})
+}
+
+func (p *Client) netDialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout:   p.dialTimeout,
+		KeepAlive: p.keepAlive,
+	}
+}
+
+// getTLSConfig returns the tls config passed in, otherwise the WithTLSConfig option
+func (p *Client) getTLSConfig(tlsConfig []*tls.Config) *tls.Config {
+	if len(tlsConfig) > 0 && tlsConfig[0] != nil {
+		return tlsConfig[0]
+	}
+
+	return p.tlsConfig
+}
+
+// ConnectWith connect by the custom dial function, such as a KCP/UDP session which implements net.Conn.
+// the packets are framed by the pomelo codec as same as the tcp connection, dialFn is called again when reconnecting.
+func (p *Client) ConnectWith(dialFn DialFn) error {
+	if dialFn == nil {
+		return cerr.Error("dial function is nil.")
+	}
+
+	return p.connect(dialFn)
+}
+
+func (p *Client) connect(dialFn DialFn) error {
+	return p.connectContext(context.Background(), func(_ context.Context) (net.Conn, error) {
+		return dialFn()
+	})
+}
+
+func (p *Client) connectContext(ctx context.Context, dialFn func(ctx context.Context) (net.Conn, error)) error {
+	conn, err := dialFn(ctx)
 	if err != nil {
 		return err
 	}
 
-	wsConn := cconnector.NewWSConn(conn)
-	p.conn = &wsConn
+	// reconnect is not bound to the ctx of the first connection
+	p.dialFn = func() (net.Conn, error) {
+		return dialFn(context.Background())
+	}
+	p.setConn(conn)
 
-	if err = p.handleHandshake(); err != nil {
+	if err = p.handleHandshakeContext(ctx); err != nil {
+		if e := conn.Close(); e != nil {
+			logger().Debug(e)
+		}
 		return err
 	}
 
 	return nil
 }
 
-func (p *Client) ConnectToTCP(addr string, tlsConfig ...*tls.Config) error {
-	var conn net.Conn
-	var err error
+// shakeHandsContext close the connection to abort the blocking handshake when ctx is done
+func (p *Client) shakeHandsContext(ctx context.Context, conn net.Conn) error {
+	if ctx.Done() == nil {
+		return p.shakeHands()
+	}
 
-	if len(tlsConfig) > 0 {
-		conn, err = tls.Dial("tcp", addr, tlsConfig[0])
-	} else {
-		conn, err = net.Dial("tcp", addr)
+	done := make(chan struct{})
+	canceled := make(chan bool, 1)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			if err := conn.Close(); err != nil {
+				logger().Debug(err)
+			}
+			canceled <- true
+		case <-done:
+			canceled <- false
+		}
+	}()
+
+	err := p.shakeHands()
+	close(done)
+
+	if <-canceled {
+		return ctx.Err()
 	}
 
-	if err != nil {
-		return err
+	return err
+}
+
+// Disconnect close the connection, it's safe to call concurrently
+func (p *Client) Disconnect() {
+	p.disconnect(nil)
+}
+
+// Close graceful close the connection, the new sends are rejected with cerr.ClientClosing,
+// then wait for the pending requests to be responded and the queued data(reconnecting) to be written.
+// the connection is closed after timeout even if they are not finished, and cerr.ClientCloseTimeout is returned.
+func (p *Client) Close(timeout time.Duration) error {
+	if !atomic.CompareAndSwapInt32(&p.closing, 0, 1) {
+		return nil
 	}
 
-	p.conn = conn
+	defer p.Disconnect()
 
-	if err = p.handleHandshake(); err != nil {
-		return err
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	deadline := time.After(timeout)
+
+	for !p.flushed() {
+		select {
+		case <-p.closeChan:
+			return nil
+		case <-deadline:
+			return cerr.Errorf("%w [pending = %d, queued = %d]", cerr.ClientCloseTimeout, p.InflightCount(), p.queuedCount())
+		case <-ticker.C:
+		}
 	}
 
 	return nil
 }
 
-func (p *Client) Disconnect() {
-	for p.connected {
-		p.connected = false
+// flushed returns true if no pending request and queued data
+func (p *Client) flushed() bool {
+	return p.InflightCount() == 0 && p.queuedCount() == 0
+}
+
+func (p *Client) queuedCount() int {
+	p.writeMutex.Lock()
+	defer p.writeMutex.Unlock()
+
+	return len(p.writeQueue)
+}
+
+func (p *Client) isClosing() bool {
+	return atomic.LoadInt32(&p.closing) == 1
+}
+
+func (p *Client) disconnect(cause error) {
+	if !atomic.CompareAndSwapInt32(&p.connected, 1, 0) {
+		return
+	}
+
+	p.closeOnce.Do(func() {
 		close(p.closeChan)
-		err := p.conn.Close()
+		err := p.getConn().Close()
 		if err != nil {
-			clog.Error(err)
+			logger().Error(err)
 		}
 
-		clog.Debugf("[%s] is disconnect.", p.TagName)
-	}
+		logger().Debugf("[%s] is disconnect.", p.TagName)
+
+		if p.OnDisconnected != nil {
+			p.callback(func() {
+				p.OnDisconnected(cause)
+			})
+		}
+	})
+}
+
+// callback run fn in a new goroutine, so that a slow callback doesn't stall the read loop
+func (p *Client) callback(fn func()) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				logger().Errorf("[%s] recover in callback. %s", p.TagName, string(debug.Stack()))
+			}
+		}()
+
+		fn()
+	}()
 }
 
 func (p *Client) AddAction(actionFn ActionFn) {
@@ -135,38 +376,245 @@ func (p *Client) AddAction(actionFn ActionFn) {
 }
 
 func (p *Client) Request(route string, val interface{}) (*pomeloMessage.Message, error) {
-	id, err := p.Send(pomeloMessage.Request, route, val)
+	data, err := p.getSerializer().Marshal(val)
+	if err != nil {
+		return nil, cerr.Errorf("serializer error.[route = %s, val =%v]", route, val)
+	}
+
+	return p.request(route, data, val, false)
+}
+
+// RequestCompressed sends the request with the deflated data, used by the large payload such as uploading a replay.
+// the compressed flag of the message is set, and the server inflates the data before dispatch.
+func (p *Client) RequestCompressed(route string, val interface{}) (*pomeloMessage.Message, error) {
+	data, err := p.getSerializer().Marshal(val)
+	if err != nil {
+		return nil, cerr.Errorf("serializer error.[route = %s, val =%v]", route, val)
+	}
+
+	return p.request(route, data, val, true)
+}
+
+// RequestRaw sends the serialized data as a request and blocks until the response arrives or timeout.
+// returns cerr.ClientRequestTimeout if no response in requestTimeout.
+func (p *Client) RequestRaw(route string, data []byte) (*pomeloMessage.Message, error) {
+	return p.request(route, data, data, false)
+}
+
+func (p *Client) request(route string, data []byte, val interface{}, compress bool) (*pomeloMessage.Message, error) {
+	if p.inflightChan != nil {
+		select {
+		case p.inflightChan <- struct{}{}:
+			defer func() {
+				<-p.inflightChan
+			}()
+		case <-time.After(p.requestTimeout):
+			return nil, cerr.Errorf("[route = %s, req = %+v] wait inflight slot time out. [maxInflight = %d]",
+				route, val, p.maxInflight)
+		case <-p.closeChan:
+			return nil, cerr.Errorf("%w [route = %s, req = %+v]", cerr.ClientDisconnected, route, val)
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		rsp, err := p.requestOnce(route, data, val, compress)
+		if attempt >= p.retryAttempts || !errors.Is(err, cerr.ClientRequestTimeout) {
+			return rsp, err
+		}
+
+		logger().Debugf("[%s] retry the timed out request. [route = %s, attempt = %d]", p.TagName, route, attempt)
+
+		if !p.waitRetry() {
+			return nil, cerr.Errorf("%w [route = %s, req = %+v]", cerr.ClientDisconnected, route, val)
+		}
+	}
+}
+
+// waitRetry waits a random delay in [0, retryJitter), returns false if disconnected
+func (p *Client) waitRetry() bool {
+	if p.retryJitter <= 0 {
+		return p.IsConnected()
+	}
+
+	select {
+	case <-time.After(time.Duration(rand.Int63n(int64(p.retryJitter)))):
+		return true
+	case <-p.closeChan:
+		return false
+	}
+}
+
+// requestOnce sends the request with a new message id and waits for the response
+func (p *Client) requestOnce(route string, data []byte, val interface{}, compress bool) (*pomeloMessage.Message, error) {
+	// an encode error is returned before the pending request is registered
+	id, bytes, err := p.encodeData(pomeloMessage.Request, route, data, 0, compress)
 	if err != nil {
 		return nil, err
 	}
 
+	// register before write, the response may arrive before write returns
 	reqCtx := NewRequestContext(p.requestTimeout)
+	reqCtx.Route = route
 	p.responseMaps.Store(id, &reqCtx)
 
 	defer func() {
 		reqCtx.Close()
 	}()
 
-	select {
-	case rsp := <-reqCtx.Chan:
-		{
-			if rsp.Error {
-				errRsp := &cproto.Response{}
-				if e := p.serializer.Unmarshal(rsp.Data, errRsp); e != nil {
-					return nil, e
+	if err = p.write(bytes); err != nil {
+		p.responseMaps.Delete(id)
+		return nil, err
+	}
+
+	return p.waitResponse(id, &reqCtx, val)
+}
+
+// waitResponse blocks until the response arrives, timeout or disconnected
+func (p *Client) waitResponse(id uint, reqCtx *RequestContext, val interface{}) (*pomeloMessage.Message, error) {
+	for {
+		select {
+		case rsp := <-reqCtx.Chan:
+			return p.parseResponse(rsp, reqCtx.Route, val)
+		case <-reqCtx.C:
+			{
+				// hold the request until reconnected
+				if p.IsReconnecting() {
+					continue
+				}
+
+				p.responseMaps.Delete(id)
+				p.metrics.IncTimeout(reqCtx.Route)
+				return nil, cerr.Errorf("%w [route = %s, req = %+v]", cerr.ClientRequestTimeout, reqCtx.Route, val)
+			}
+		case <-p.closeChan:
+			{
+				// the response may be received before disconnected
+				select {
+				case rsp := <-reqCtx.Chan:
+					return p.parseResponse(rsp, reqCtx.Route, val)
+				default:
 				}
 
-				return nil, cerr.Errorf("[route = %s, statusCode = %d, req = %+v]", route, errRsp.Code, val)
-			} else {
-				return rsp, nil
+				// the pending requests are released once the client is disconnected
+				p.responseMaps.Delete(id)
+				return nil, cerr.Errorf("%w [route = %s, req = %+v]", cerr.ClientDisconnected, reqCtx.Route, val)
 			}
 		}
-	case <-reqCtx.C:
-		{
-			p.responseMaps.Delete(id)
-			return nil, cerr.Errorf("[route = %s, req = %+v] time out", route, val)
+	}
+}
+
+func (p *Client) parseResponse(rsp *pomeloMessage.Message, route string, val interface{}) (*pomeloMessage.Message, error) {
+	if !rsp.Error {
+		return rsp, nil
+	}
+
+	errRsp := &cproto.Response{}
+	if e := p.getSerializer().Unmarshal(rsp.Data, errRsp); e != nil {
+		return nil, e
+	}
+
+	return nil, cerr.Errorf("[route = %s, statusCode = %d, req = %+v]", route, errRsp.Code, val)
+}
+
+// SendBatch encodes the messages and writes them to the connection with a single write.
+// the OnResponse of the request is called in a new goroutine when the response arrives, timeout or disconnected.
+// nothing is sent if any message fails to encode.
+func (p *Client) SendBatch(msgs []OutgoingMessage) error {
+	type pending struct {
+		id     uint
+		msg    *OutgoingMessage
+		reqCtx *RequestContext
+	}
+
+	var (
+		buf      []byte
+		requests []pending
+	)
+
+	for i := range msgs {
+		msg := &msgs[i]
+
+		data, err := p.getSerializer().Marshal(msg.Val)
+		if err != nil {
+			return cerr.Errorf("serializer error.[route = %s, val =%v]", msg.Route, msg.Val)
+		}
+
+		id, bytes, err := p.encodeData(msg.Type, msg.Route, data, 0, false)
+		if err != nil {
+			return err
+		}
+
+		buf = append(buf, bytes...)
+
+		if msg.Type == pomeloMessage.Request {
+			requests = append(requests, pending{id: id, msg: msg})
+		}
+	}
+
+	// register before write, the response may arrive before write returns
+	for i := range requests {
+		reqCtx := NewRequestContext(p.requestTimeout)
+		reqCtx.Route = requests[i].msg.Route
+		requests[i].reqCtx = &reqCtx
+		p.responseMaps.Store(requests[i].id, &reqCtx)
+	}
+
+	if err := p.write(buf); err != nil {
+		for _, r := range requests {
+			p.responseMaps.Delete(r.id)
+			r.reqCtx.Close()
 		}
+		return err
 	}
+
+	for _, r := range requests {
+		go func(r pending) {
+			defer r.reqCtx.Close()
+
+			rsp, err := p.waitResponse(r.id, r.reqCtx, r.msg.Val)
+			if r.msg.OnResponse != nil {
+				r.msg.OnResponse(rsp, err)
+			}
+		}(r)
+	}
+
+	return nil
+}
+
+// PendingRequests returns a snapshot of the requests which are waiting for response
+func (p *Client) PendingRequests() []PendingInfo {
+	var list []PendingInfo
+
+	now := time.Now()
+	p.responseMaps.Range(func(key, value any) bool {
+		id, ok := key.(uint)
+		if !ok {
+			return true
+		}
+
+		if reqCtx, ok := value.(*RequestContext); ok {
+			list = append(list, PendingInfo{
+				ID:    id,
+				Route: reqCtx.Route,
+				Age:   now.Sub(reqCtx.SentAt),
+			})
+		}
+
+		return true
+	})
+
+	return list
+}
+
+// InflightCount returns the number of requests waiting for response
+func (p *Client) InflightCount() int {
+	count := 0
+	p.responseMaps.Range(func(_, _ any) bool {
+		count++
+		return true
+	})
+
+	return count
 }
 
 // Notify sends a notify to the server
@@ -179,23 +627,124 @@ func (p *Client) Notify(route string, val interface{}) error {
 	return nil
 }
 
-// On listener route
+// NotifyTTL sends a notify to the server, it will be dropped by the server after the ttl elapsed
+func (p *Client) NotifyTTL(route string, val interface{}, ttl time.Duration) error {
+	_, err := p.SendTTL(pomeloMessage.Notify, route, val, ttl)
+	return err
+}
+
+// On listener route, the push message without handler is delivered to PushChan if WithPushChan is set
 func (p *Client) On(route string, fn OnMessageFn) {
 	p.pushBindMaps.Store(route, fn)
 }
 
+// Off remove the listener of route
+func (p *Client) Off(route string) {
+	p.pushBindMaps.Delete(route)
+}
+
 // IsConnected return the connection status
 func (p *Client) IsConnected() bool {
-	return p.connected
+	return atomic.LoadInt32(&p.connected) == 1
+}
+
+// IsReconnecting return true if the client is reconnecting
+func (p *Client) IsReconnecting() bool {
+	return atomic.LoadInt32(&p.reconnecting) == 1
+}
+
+// ReconnectChan receive the reconnect result, see WithReconnect
+func (p *Client) ReconnectChan() <-chan ReconnectEvent {
+	return p.reconnectChan
+}
+
+// Conn returns the underlying connection, it is replaced after reconnected
+func (p *Client) Conn() net.Conn {
+	return p.getConn()
+}
+
+// RemoteAddr returns the remote address of the connection, empty if not connected
+func (p *Client) RemoteAddr() string {
+	conn := p.getConn()
+	if conn == nil || conn.RemoteAddr() == nil {
+		return ""
+	}
+
+	return conn.RemoteAddr().String()
+}
+
+func (p *Client) getConn() net.Conn {
+	p.connMutex.RLock()
+	defer p.connMutex.RUnlock()
+
+	return p.conn
+}
+
+func (p *Client) setConn(conn net.Conn) {
+	p.connMutex.Lock()
+	defer p.connMutex.Unlock()
+
+	p.conn = conn
+}
+
+// getSerializer returns the serializer, it may be switched by the handshake of the server
+func (p *Client) getSerializer() cfacade.ISerializer {
+	p.connMutex.RLock()
+	defer p.connMutex.RUnlock()
+
+	return p.serializer
+}
+
+// heartbeat returns the heartbeat interval(seconds) negotiated by the handshake
+func (p *Client) heartbeat() int {
+	p.connMutex.RLock()
+	defer p.connMutex.RUnlock()
+
+	return p.heartBeat
+}
+
+// Metrics returns the metrics sink, see WithMetrics
+func (p *Client) Metrics() IMetrics {
+	return p.metrics
 }
 
 func (p *Client) HandshakeData() *HandshakeData {
+	p.connMutex.RLock()
+	defer p.connMutex.RUnlock()
+
 	return p.handshakeData
 }
 
 func (p *Client) handleHandshake() error {
+	return p.handleHandshakeContext(context.Background())
+}
+
+func (p *Client) handleHandshakeContext(ctx context.Context) error {
+	if err := p.shakeHandsContext(ctx, p.getConn()); err != nil {
+		return err
+	}
+
+	p.run()
+
+	if p.OnConnected != nil {
+		p.callback(p.OnConnected)
+	}
+
+	return nil
+}
+
+func (p *Client) shakeHands() error {
+	if p.skipHandshake {
+		return nil
+	}
+
+	handshake, err := p.handshakeContent()
+	if err != nil {
+		return err
+	}
+
 	// send handshake message
-	if err := p.SendRaw(pomeloPacket.Handshake, []byte(p.handshake)); err != nil {
+	if err = p.sendPacket(pomeloPacket.Handshake, handshake); err != nil {
 		return err
 	}
 
@@ -217,85 +766,260 @@ func (p *Client) handleHandshake() error {
 		handshakePacket.SetData(data)
 	}
 
-	err = jsoniter.Unmarshal(handshakePacket.Data(), p.handshakeData)
+	handshakeData := &HandshakeData{}
+	err = jsoniter.Unmarshal(handshakePacket.Data(), handshakeData)
 	if err != nil {
 		return err
 	}
 
-	if p.handshakeData.Sys.Dict != nil {
-		pomeloMessage.SetDictionary(p.handshakeData.Sys.Dict)
+	// the server rejects the handshake, such as version mismatch or server full
+	if err = handshakeError(handshakeData.Code); err != nil {
+		return err
 	}
 
-	if p.handshakeData.Sys.Heartbeat > 1 {
-		p.heartBeat = p.handshakeData.Sys.Heartbeat / 2
+	if handshakeData.Sys.Dict != nil {
+		pomeloMessage.SetDictionary(handshakeData.Sys.Dict)
 	}
 
-	err = p.SendRaw(pomeloPacket.HandshakeAck, []byte{})
-	if err != nil {
-		return err
+	// use the serializer advertised by the server, fall back to json if the name is empty or unknown
+	current := p.getSerializer()
+	serializer, ok := serializerByName(handshakeData.Sys.Serializer)
+	if !ok {
+		logger().Warnf("[%s] unknown serializer in handshake, fall back to json. [serializer = %s, current = %s]",
+			p.TagName,
+			handshakeData.Sys.Serializer,
+			current.Name(),
+		)
+		serializer = cserializer.NewJSON()
 	}
 
-	p.connected = true // is connected
+	if serializer.Name() == current.Name() {
+		serializer = current
+	} else {
+		logger().Infof("[%s] switch serializer by handshake. [%s -> %s]",
+			p.TagName,
+			current.Name(),
+			serializer.Name(),
+		)
+	}
+
+	heartBeat := p.heartbeatInterval(handshakeData.Sys.Heartbeat)
+	logger().Debugf("[%s] heartbeat interval = %ds. [server = %ds]", p.TagName, heartBeat, handshakeData.Sys.Heartbeat)
+
+	// Request, Notify and the heartbeat loop read them concurrently while reconnecting
+	p.connMutex.Lock()
+	p.handshakeData = handshakeData
+	p.serializer = serializer
+	p.heartBeat = heartBeat
+	p.connMutex.Unlock()
+
+	return p.sendPacket(pomeloPacket.HandshakeAck, []byte{})
+}
+
+func (p *Client) run() {
+	atomic.StoreInt32(&p.connected, 1) // is connected
+	p.setLastAt()
 
 	go p.handlePackets()
 	go p.handleData()
 
-	return nil
+	if p.pushChan != nil {
+		go p.handlePush()
+	}
+}
+
+func (p *Client) handlePush() {
+	for {
+		select {
+		case item := <-p.pushChan:
+			p.processPush(item)
+		case <-p.closeChan:
+			return
+		}
+	}
+}
+
+// enqueuePush put the push message into the queue, drop the oldest message if the queue is full and pushDropOldest is true
+func (p *Client) enqueuePush(item pushItem) {
+	for {
+		select {
+		case p.pushChan <- item:
+			return
+		default:
+		}
+
+		if !p.pushDropOldest {
+			select {
+			case p.pushChan <- item:
+			case <-p.closeChan:
+			}
+			return
+		}
+
+		select {
+		case oldest := <-p.pushChan:
+			dropped := atomic.AddInt64(&p.pushDropped, 1)
+			logger().Warnf("[%s] push queue is full, drop the oldest message. [route = %s, dropped = %d]",
+				p.TagName,
+				oldest.msg.Route,
+				dropped,
+			)
+		default:
+		}
+	}
+}
+
+// PushDropped returns the number of push messages dropped because the queue is full
+func (p *Client) PushDropped() int64 {
+	return atomic.LoadInt64(&p.pushDropped)
 }
 
 func (p *Client) handlePackets() {
-	for p.connected {
+	for p.IsConnected() {
 		packets, err := p.getPackets()
 		if err != nil {
-			clog.Warn(err)
+			logger().Warn(err)
+
+			if p.IsConnected() && p.reconnectMax > 0 && p.reconnect() {
+				continue
+			}
+
+			p.disconnect(err)
 			break
 		}
 
+		p.setLastAt()
+
+		kicked := false
 		for _, pkg := range packets {
 			switch pkg.Type() {
-			case pomeloPacket.Data:
+			case pomeloPacket.Fragment, pomeloPacket.Data:
 				{
-					m, err := pomeloMessage.Decode(pkg.Data())
-					if err != nil {
-						clog.Warnf("[%s] error decoding msg from sv: %s", p.TagName, string(m.Data))
+					if !p.processDataPacket(pkg) {
 						return
 					}
-
-					p.processMessage(&m)
 				}
 			case pomeloPacket.Kick:
 				{
-					clog.Warnf("[%s] got kick packet from the server! disconnecting...", p.TagName)
-					p.Disconnect()
+					logger().Warnf("[%s] got kick packet from the server! disconnecting...", p.TagName)
+					p.metrics.IncKick()
+					if p.OnKicked != nil {
+						reason := pkg.Data()
+						p.callback(func() {
+							p.OnKicked(reason)
+						})
+					}
+					kicked = true
 				}
 			}
+
+			pomeloPacket.Release(pkg)
+		}
+
+		if kicked {
+			p.drain()
+			p.Disconnect()
+			break
+		}
+	}
+}
+
+// drain process the packets which have been sent by the server before the connection is closed, bounded by kickDrainTimeout
+func (p *Client) drain() {
+	conn := p.getConn()
+	if err := conn.SetReadDeadline(time.Now().Add(kickDrainTimeout)); err != nil {
+		return
+	}
+
+	for {
+		packets, isBreak, err := p.codec.Read(conn, p.maxPacketSize)
+		if isBreak || err != nil {
+			return
+		}
+
+		for _, pkg := range packets {
+			if !p.processDataPacket(pkg) {
+				return
+			}
+			pomeloPacket.Release(pkg)
 		}
 	}
 }
 
+// processDataPacket process the data or fragment packet, other packets are ignored
+func (p *Client) processDataPacket(pkg *pomeloPacket.Packet) bool {
+	switch pkg.Type() {
+	case pomeloPacket.Fragment:
+		{
+			packet, err := p.fragments.Add(pkg)
+			if err != nil {
+				logger().Warnf("[%s] error reassembling fragment from sv: %s", p.TagName, err)
+				return false
+			}
+
+			if packet != nil && packet.Type() == pomeloPacket.Data {
+				return p.processData(packet)
+			}
+		}
+	case pomeloPacket.Data:
+		{
+			return p.processData(pkg)
+		}
+	}
+
+	return true
+}
+
+func (p *Client) processData(pkg *pomeloPacket.Packet) bool {
+	m, err := pomeloMessage.Decode(pkg.Data())
+	if err != nil {
+		logger().Warnf("[%s] error decoding msg from sv: %s", p.TagName, string(m.Data))
+		return false
+	}
+
+	p.processMessage(&m)
+	return true
+}
+
 func (p *Client) handleData() {
-	heartBeatTicker := time.NewTicker(time.Duration(p.heartBeat) * time.Second)
+	// heartbeat is disabled when heartBeat < 1
+	var heartBeatChan <-chan time.Time
+	if heartBeat := p.heartbeat(); heartBeat > 0 {
+		heartBeatTicker := time.NewTicker(time.Duration(heartBeat) * time.Second)
+		heartBeatChan = heartBeatTicker.C
+		defer heartBeatTicker.Stop()
+	}
 
+	var err error
 	defer func() {
-		heartBeatTicker.Stop()
-		defer p.Disconnect()
+		p.disconnect(err)
 	}()
 
 	for {
 		select {
 		case actionFn := <-p.actionChan:
 			{
-				if err := actionFn(); err != nil {
-					clog.Warn(err)
+				if err = actionFn(); err != nil {
+					logger().Warn(err)
 					if p.isErrorBreak {
 						return
 					}
 				}
 			}
-		case <-heartBeatTicker.C:
+		case <-heartBeatChan:
 			{
-				if err := p.SendRaw(pomeloPacket.Heartbeat, []byte{}); err != nil {
-					clog.Warnf("[%s] packet encode error. %s", p.TagName, err.Error())
+				if p.IsReconnecting() {
+					continue
+				}
+
+				if p.isHeartbeatTimeout() {
+					logger().Warnf("[%s] heartbeat timeout, disconnecting... [lastAt = %d]", p.TagName, atomic.LoadInt64(&p.lastAt))
+					err = cerr.ClientHeartbeatTimeout
+					return
+				}
+
+				if err = p.sendHeartbeat(); err != nil {
+					logger().Warnf("[%s] packet encode error. %s", p.TagName, err.Error())
 					return
 				}
 			}
@@ -305,22 +1029,36 @@ func (p *Client) handleData() {
 	}
 }
 
+func (p *Client) setLastAt() {
+	atomic.StoreInt64(&p.lastAt, time.Now().UnixMilli())
+}
+
+func (p *Client) isHeartbeatTimeout() bool {
+	if p.heartBeatTimes < 1 {
+		return false
+	}
+
+	timeout := time.Duration(p.heartbeat()*p.heartBeatTimes) * time.Second
+	return time.Now().UnixMilli()-atomic.LoadInt64(&p.lastAt) > timeout.Milliseconds()
+}
+
 func (p *Client) processMessage(msg *pomeloMessage.Message) {
 	defer func() {
 		if r := recover(); r != nil {
-			clog.Errorf("[%s] recover in executor. %s", p.TagName, string(debug.Stack()))
+			logger().Errorf("[%s] recover in executor. %s", p.TagName, string(debug.Stack()))
 		}
 	}()
 
 	if msg.Type == pomeloMessage.Response {
 		value, found := p.responseMaps.LoadAndDelete(msg.ID)
 		if !found {
-			clog.Warnf("callback not found. [msg = %v]", msg)
+			logger().Warnf("callback not found. [msg = %v]", msg)
 			return
 		}
 
 		reqCtx, ok := value.(*RequestContext)
 		if ok {
+			p.metrics.ObserveLatency(reqCtx.Route, time.Since(reqCtx.SentAt))
 			reqCtx.Chan <- msg
 		}
 
@@ -328,25 +1066,84 @@ func (p *Client) processMessage(msg *pomeloMessage.Message) {
 	}
 
 	if msg.Type == pomeloMessage.Push {
-		value, found := p.pushBindMaps.Load(msg.Route)
-		if found {
-			fn, ok := value.(OnMessageFn)
-			if ok {
-				fn(msg)
-			}
+		p.pushSeq++
+		item := pushItem{seq: p.pushSeq, msg: msg}
+
+		if p.pushChan != nil {
+			p.enqueuePush(item)
 			return
 		}
+
+		p.processPush(item)
+	}
+}
+
+// processPush dispatch the push message in the order of the server sent,
+// the messages dropped by the full queue make gaps of the sequence but never reorder.
+func (p *Client) processPush(item pushItem) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger().Errorf("[%s] recover in push. %s", p.TagName, string(debug.Stack()))
+		}
+	}()
+
+	msg := item.msg
+	if last := atomic.SwapUint64(&p.pushDelivered, item.seq); item.seq <= last {
+		logger().Warnf("[%s] push message is out of order. [route = %s, seq = %d, last = %d]",
+			p.TagName,
+			msg.Route,
+			item.seq,
+			last,
+		)
+	}
+
+	value, found := p.pushBindMaps.Load(msg.Route)
+	if found {
+		fn, ok := value.(OnMessageFn)
+		if ok {
+			fn(msg)
+		}
+		return
+	}
+
+	if p.pushOutChan == nil {
+		return
+	}
+
+	select {
+	case p.pushOutChan <- msg:
+	default:
+		dropped := atomic.AddInt64(&p.pushDropped, 1)
+		logger().Warnf("[%s] push chan is full, drop the message. [route = %s, dropped = %d]",
+			p.TagName,
+			msg.Route,
+			dropped,
+		)
 	}
 }
 
+// PushChan returns the push messages which have no handler registered by On, nil if WithPushChan is not set
+func (p *Client) PushChan() <-chan *pomeloMessage.Message {
+	return p.pushOutChan
+}
+
 func (p *Client) getPackets() ([]*pomeloPacket.Packet, error) {
-	packets, isBreak, err := pomeloPacket.Read(p.conn)
+	conn := p.getConn()
+	if p.readTimeout > 0 {
+		if err := conn.SetReadDeadline(time.Now().Add(p.readTimeout)); err != nil {
+			return nil, err
+		}
+	}
+
+	packets, isBreak, err := p.codec.Read(conn, p.maxPacketSize)
 	if err != nil {
-		clog.Errorf("[%s] error decoding packet from server: %s", p.TagName, err.Error())
+		// the stream can't be resynced after a decode error, disconnect instead of reading the garbage
+		logger().Errorf("[%s] error decoding packet from server: %s", p.TagName, err.Error())
+		return nil, err
 	}
 
 	if isBreak {
-		return nil, err
+		return nil, cerr.PacketConnectClosed
 	}
 
 	return packets, nil
@@ -354,37 +1151,239 @@ func (p *Client) getPackets() ([]*pomeloPacket.Packet, error) {
 
 // Send the message to the server
 func (p *Client) Send(msgType pomeloMessage.Type, route string, val interface{}) (uint, error) {
-	data, err := p.serializer.Marshal(val)
+	return p.SendTTL(msgType, route, val, 0)
+}
+
+// SendTTL send the message to the server with a ttl.
+// the server drops the message if it has not been dispatched before the ttl elapsed.
+// the expire time is build by the client clock, see pomeloMessage.Message.SetTTL
+func (p *Client) SendTTL(msgType pomeloMessage.Type, route string, val interface{}, ttl time.Duration) (uint, error) {
+	data, err := p.getSerializer().Marshal(val)
 	if err != nil {
 		return 0, cerr.Errorf("serializer error.[route = %s, val =%v]", route, val)
 	}
 
+	return p.sendData(msgType, route, data, ttl)
+}
+
+func (p *Client) sendData(msgType pomeloMessage.Type, route string, data []byte, ttl time.Duration) (uint, error) {
+	id, bytes, err := p.encodeData(msgType, route, data, ttl, false)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, p.write(bytes)
+}
+
+// nextMessageID returns the next message id, the id is wrapped after math.MaxUint32.
+// zero is skipped when wrapping, and the id of a pending request is skipped to avoid collision.
+func (p *Client) nextMessageID() uint {
+	for {
+		id := uint(atomic.AddUint32(&p.nextID, 1))
+		if id == 0 {
+			continue
+		}
+
+		if _, found := p.responseMaps.Load(id); found {
+			continue
+		}
+
+		return id
+	}
+}
+
+// encodeData build the message and encode it into packets
+func (p *Client) encodeData(msgType pomeloMessage.Type, route string, data []byte, ttl time.Duration, compress bool) (uint, []byte, error) {
 	m := &pomeloMessage.Message{
-		ID:    uint(atomic.AddUint32(&p.nextID, 1)),
+		ID:    p.nextMessageID(),
 		Type:  msgType,
 		Route: route,
 		Data:  data,
 	}
+	m.SetTTL(ttl)
+
+	var (
+		encMsg []byte
+		err    error
+	)
+
+	if compress {
+		encMsg, err = pomeloMessage.EncodeCompressed(m)
+	} else {
+		encMsg, err = pomeloMessage.Encode(m)
+	}
 
-	encMsg, err := pomeloMessage.Encode(m)
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 
-	bytes, err := pomeloPacket.Encode(pomeloPacket.Data, encMsg)
+	bytes, err := p.codec.EncodeData(encMsg)
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 
-	_, err = p.conn.Write(bytes)
-	return m.ID, err
+	return m.ID, bytes, nil
 }
 
 func (p *Client) SendRaw(typ pomeloPacket.Type, data []byte) error {
-	pkg, err := pomeloPacket.Encode(typ, data)
+	pkg, err := p.codec.Encode(typ, data)
+	if err != nil {
+		return err
+	}
+
+	return p.write(pkg)
+}
+
+func (p *Client) sendHeartbeat() error {
+	pkg, err := p.codec.Encode(pomeloPacket.Heartbeat, []byte{})
+	if err != nil {
+		return err
+	}
+
+	return p.writeData(pkg)
+}
+
+// sendPacket write the packet to the connection directly, used by handshake
+func (p *Client) sendPacket(typ pomeloPacket.Type, data []byte) error {
+	pkg, err := p.codec.Encode(typ, data)
 	if err != nil {
 		return err
 	}
-	_, err = p.conn.Write(pkg)
+
+	return p.writeConn(pkg)
+}
+
+func (p *Client) write(bytes []byte) error {
+	if p.isClosing() {
+		return cerr.ClientClosing
+	}
+
+	return p.writeData(bytes)
+}
+
+// writeData write or queue the data regardless of closing, the heartbeat is kept during graceful close
+func (p *Client) writeData(bytes []byte) error {
+	p.writeMutex.Lock()
+	defer p.writeMutex.Unlock()
+
+	// queued until reconnected
+	if p.IsReconnecting() {
+		p.writeQueue = append(p.writeQueue, bytes)
+		return nil
+	}
+
+	return p.writeConn(bytes)
+}
+
+func (p *Client) writeConn(bytes []byte) error {
+	conn := p.getConn()
+	if p.writeTimeout > 0 {
+		if err := conn.SetWriteDeadline(time.Now().Add(p.writeTimeout)); err != nil {
+			return err
+		}
+	}
+
+	_, err := conn.Write(bytes)
+	if err != nil && cconnector.IsTimeout(err) {
+		logger().Warnf("[%s] write timeout, disconnecting... [err = %v]", p.TagName, err)
+		p.disconnect(err)
+	}
+
 	return err
 }
+
+// reconnect dial and handshake again with exponential backoff delay.
+// returns false if retries are exhausted or the client is disconnected.
+func (p *Client) reconnect() bool {
+	p.writeMutex.Lock()
+	atomic.StoreInt32(&p.reconnecting, 1)
+	p.writeMutex.Unlock()
+
+	if err := p.getConn().Close(); err != nil {
+		logger().Debug(err)
+	}
+
+	var (
+		delay = p.reconnectDelay
+		event = ReconnectEvent{}
+	)
+
+	for event.Retries < p.reconnectMax && p.IsConnected() {
+		time.Sleep(delay)
+
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+
+		event.Retries++
+		event.Err = p.redial()
+		if event.Err == nil {
+			event.Succeed = true
+			break
+		}
+
+		logger().Warnf("[%s] reconnect fail. [retries = %d, err = %v]", p.TagName, event.Retries, event.Err)
+	}
+
+	p.writeMutex.Lock()
+	if event.Succeed {
+		// flush the queued packets
+		for _, bytes := range p.writeQueue {
+			if err := p.writeConn(bytes); err != nil {
+				logger().Warn(err)
+				break
+			}
+		}
+	}
+	p.writeQueue = nil
+	atomic.StoreInt32(&p.reconnecting, 0)
+	p.writeMutex.Unlock()
+
+	select {
+	case p.reconnectChan <- event:
+	default:
+	}
+
+	if !event.Succeed {
+		p.disconnect(event.Err)
+		return false
+	}
+
+	logger().Infof("[%s] reconnect succeed. [retries = %d]", p.TagName, event.Retries)
+
+	if p.OnConnected != nil {
+		p.callback(p.OnConnected)
+	}
+
+	return true
+}
+
+func (p *Client) redial() error {
+	conn, err := p.dialFn()
+	if err != nil {
+		return err
+	}
+
+	// Disconnect closes the current conn, so the new conn is closed here if it is called while dialing
+	p.connMutex.Lock()
+	if !p.IsConnected() {
+		p.connMutex.Unlock()
+		if e := conn.Close(); e != nil {
+			logger().Debug(e)
+		}
+		return cerr.ClientDisconnected
+	}
+	p.conn = conn
+	p.connMutex.Unlock()
+
+	p.setLastAt()
+
+	if err = p.shakeHands(); err != nil {
+		if e := conn.Close(); e != nil {
+			logger().Debug(e)
+		}
+		return err
+	}
+
+	return nil
+}
@@ -1,9 +1,35 @@
 package pomeloClient
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	cerr "github.com/cherry-game/cherry/error"
+	cconnector "github.com/cherry-game/cherry/net/connector"
+	pomeloMessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	pomeloPacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+	cproto "github.com/cherry-game/cherry/net/proto"
+	"github.com/gorilla/websocket"
+	jsoniter "github.com/json-iterator/go"
 )
 
 func TestClient(t *testing.T) {
@@ -19,6 +45,1007 @@ func TestClient(t *testing.T) {
 
 }
 
+type (
+	// testServer the server side of a test connection, the packets are framed by codec
+	testServer struct {
+		conn  net.Conn
+		codec ICodec
+	}
+
+	// testHandler handle a data packet received by the test server, m is the decoded message of pkg
+	testHandler func(s *testServer, pkg *pomeloPacket.Packet, m *pomeloMessage.Message)
+)
+
+// newTestServer connect a client to a test server by pipe, handler is called for each data packet received by the server
+func newTestServer(t *testing.T, handler testHandler, opts ...Option) *Client {
+	client := New(opts...)
+
+	err := client.ConnectWith(func() (net.Conn, error) {
+		conn, peer := net.Pipe()
+		go serveTest(peer, client.codec, handler)
+		return conn, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client
+}
+
+// serveTest read the packets until the connection is broken, the handshake is accepted and the data packets are passed to handler
+func serveTest(conn net.Conn, codec ICodec, handler testHandler) {
+	s := &testServer{conn: conn, codec: codec}
+	defer conn.Close()
+
+	for {
+		packets, isBreak, err := codec.Read(conn, 0)
+		if isBreak || err != nil {
+			return
+		}
+
+		for _, pkg := range packets {
+			switch pkg.Type() {
+			case pomeloPacket.Handshake:
+				if err = s.write(pomeloPacket.Handshake, []byte(`{"code":200,"sys":{"heartbeat":30}}`)); err != nil {
+					return
+				}
+			case pomeloPacket.Data:
+				m, err := pomeloMessage.Decode(pkg.Data())
+				if err != nil {
+					return
+				}
+				handler(s, pkg, &m)
+			}
+		}
+	}
+}
+
+// echoHandler response the request data
+func echoHandler(s *testServer, _ *pomeloPacket.Packet, m *pomeloMessage.Message) {
+	_ = s.respond(m)
+}
+
+func (s *testServer) write(typ pomeloPacket.Type, data []byte) error {
+	bytes, err := s.codec.Encode(typ, data)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.conn.Write(bytes)
+	return err
+}
+
+// respond write m back as the response
+func (s *testServer) respond(m *pomeloMessage.Message) error {
+	m.Type = pomeloMessage.Response
+	data, err := pomeloMessage.Encode(m)
+	if err != nil {
+		return err
+	}
+
+	bytes, err := s.codec.EncodeData(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.conn.Write(bytes)
+	return err
+}
+
+func TestClientDisconnect(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer peer.Close()
+
+	client := New(
+		WithSkipHandshake(true),
+		WithHeartbeat(1),
+	)
+	client.conn = conn
+
+	if err := client.handleHandshake(); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.Disconnect()
+		}()
+	}
+	wg.Wait()
+
+	if client.IsConnected() {
+		t.Fatal("client is still connected")
+	}
+}
+
+func TestClientReadTimeout(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer peer.Close()
+
+	client := New(
+		WithSkipHandshake(true),
+		WithHeartbeat(0),
+		WithReadTimeout(100*time.Millisecond),
+	)
+	client.conn = conn
+
+	errChan := make(chan error, 1)
+	client.OnDisconnected = func(err error) {
+		errChan <- err
+	}
+
+	if err := client.handleHandshake(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-errChan:
+		if !cconnector.IsTimeout(err) {
+			t.Fatalf("disconnect err = %v, want timeout", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("client should be disconnected after read timeout")
+	}
+}
+
+func TestClientConnectContext(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// accept the connection but never response the handshake
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	client := New(WithHeartbeat(0))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	begin := time.Now()
+	err = client.ConnectToTCPContext(ctx, listener.Addr().String())
+	if err != context.DeadlineExceeded {
+		t.Fatalf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if elapsed := time.Since(begin); elapsed > 3*time.Second {
+		t.Fatalf("handshake is not aborted. [elapsed = %v]", elapsed)
+	}
+
+	if client.IsConnected() {
+		t.Fatal("client should not be connected")
+	}
+}
+
+func TestClientRequestCompressed(t *testing.T) {
+	// server stub, inflate the request data and echo it uncompressed
+	compressedChan := make(chan bool, 2)
+	handler := func(s *testServer, pkg *pomeloPacket.Packet, m *pomeloMessage.Message) {
+		compressedChan <- pkg.Data()[0]&pomeloMessage.GZIPMask != 0
+		_ = s.respond(m)
+	}
+
+	client := newTestServer(t, handler,
+		WithSkipHandshake(true),
+		WithHeartbeat(0),
+		WithRequestTimeout(3*time.Second),
+	)
+	defer client.Disconnect()
+
+	replay := []byte(strings.Repeat("replay frame data,", 1024))
+
+	rsp, err := client.RequestCompressed("game.replay.upload", replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !<-compressedChan {
+		t.Fatal("request data should be compressed")
+	}
+
+	if string(rsp.Data) != string(replay) {
+		t.Fatalf("response data length = %d, want %d", len(rsp.Data), len(replay))
+	}
+
+	// the default request is sent uncompressed
+	if _, err = client.RequestRaw("game.replay.upload", replay); err != nil {
+		t.Fatal(err)
+	}
+
+	if <-compressedChan {
+		t.Fatal("request data should not be compressed")
+	}
+}
+
+func TestClientHandshakeCode(t *testing.T) {
+	tests := []struct {
+		code int
+		err  error
+	}{
+		{code: HandshakeOK},
+		{code: HandshakeFail, err: cerr.ClientHandshakeFail},
+		{code: HandshakeVersionTooOld, err: cerr.ClientVersionTooOld},
+		{code: HandshakeServerFull, err: cerr.ClientServerFull},
+	}
+
+	for _, tt := range tests {
+		client := New(WithHeartbeat(0))
+
+		err := client.ConnectWith(func() (net.Conn, error) {
+			conn, peer := net.Pipe()
+
+			// response the handshake with the code
+			go func() {
+				defer peer.Close()
+				if _, _, err := pomeloPacket.Read(peer); err != nil {
+					return
+				}
+
+				data := fmt.Sprintf(`{"code":%d,"sys":{"heartbeat":30}}`, tt.code)
+				bytes, _ := pomeloPacket.Encode(pomeloPacket.Handshake, []byte(data))
+				if _, err := peer.Write(bytes); err != nil {
+					return
+				}
+
+				// handshake ack
+				_, _, _ = pomeloPacket.Read(peer)
+			}()
+
+			return conn, nil
+		})
+
+		if tt.err == nil {
+			if err != nil || !client.IsConnected() {
+				t.Fatalf("code = %d, err = %v, connected = %v", tt.code, err, client.IsConnected())
+			}
+			client.Disconnect()
+			continue
+		}
+
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("code = %d, err = %v, want %v", tt.code, err, tt.err)
+		}
+
+		if client.IsConnected() {
+			t.Fatalf("code = %d, client should not be connected", tt.code)
+		}
+	}
+}
+
+func TestClientSerializerNegotiation(t *testing.T) {
+	tests := []struct {
+		serializer string
+		want       string
+	}{
+		{serializer: "protobuf", want: "protobuf"},
+		{serializer: "json", want: "json"},
+		{serializer: "", want: "json"},
+		{serializer: "msgpack", want: "json"},
+	}
+
+	for _, tt := range tests {
+		client := New(WithHeartbeat(0))
+
+		err := client.ConnectWith(func() (net.Conn, error) {
+			conn, peer := net.Pipe()
+
+			// response the handshake with the serializer
+			go func() {
+				defer peer.Close()
+				if _, _, err := pomeloPacket.Read(peer); err != nil {
+					return
+				}
+
+				data := fmt.Sprintf(`{"code":200,"sys":{"heartbeat":30,"serializer":"%s"}}`, tt.serializer)
+				bytes, _ := pomeloPacket.Encode(pomeloPacket.Handshake, []byte(data))
+				if _, err := peer.Write(bytes); err != nil {
+					return
+				}
+
+				// handshake ack
+				_, _, _ = pomeloPacket.Read(peer)
+			}()
+
+			return conn, nil
+		})
+
+		if err != nil {
+			t.Fatalf("serializer = %s, err = %v", tt.serializer, err)
+		}
+
+		if name := client.getSerializer().Name(); name != tt.want {
+			t.Fatalf("serializer = %s, got %s, want %s", tt.serializer, name, tt.want)
+		}
+
+		client.Disconnect()
+	}
+}
+
+// testCodec a variant framing: 1 byte type(offset 0x10) + 2 bytes length + data
+type testCodec struct{}
+
+func (testCodec) Read(conn net.Conn, _ int) ([]*pomeloPacket.Packet, bool, error) {
+	header := make([]byte, 3)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, true, err
+	}
+
+	data := make([]byte, binary.BigEndian.Uint16(header[1:]))
+	if _, err := io.ReadFull(conn, data); err != nil {
+		return nil, true, err
+	}
+
+	typ := header[0] - 0x10
+	if pomeloPacket.InvalidType(typ) {
+		return nil, false, cerr.PacketWrongType
+	}
+
+	return []*pomeloPacket.Packet{pomeloPacket.New(typ, data)}, false, nil
+}
+
+func (testCodec) Encode(typ pomeloPacket.Type, data []byte) ([]byte, error) {
+	buf := []byte{typ + 0x10, 0, 0}
+	binary.BigEndian.PutUint16(buf[1:], uint16(len(data)))
+	return append(buf, data...), nil
+}
+
+func (c testCodec) EncodeData(data []byte) ([]byte, error) {
+	return c.Encode(pomeloPacket.Data, data)
+}
+
+func TestClientCodec(t *testing.T) {
+	// the test server frames the packets by the client codec
+	client := newTestServer(t, echoHandler,
+		WithHeartbeat(0),
+		WithRequestTimeout(3*time.Second),
+		WithCodec(testCodec{}),
+	)
+	defer client.Disconnect()
+
+	rsp, err := client.RequestRaw("game.player.ping", []byte("ping"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(rsp.Data) != "ping" {
+		t.Fatalf("response data = %s", rsp.Data)
+	}
+}
+
+func TestClientCorruptPacket(t *testing.T) {
+	codec := testCodec{}
+
+	client := New(
+		WithHeartbeat(0),
+		WithCodec(codec),
+	)
+
+	errChan := make(chan error, 1)
+	client.OnDisconnected = func(err error) {
+		errChan <- err
+	}
+
+	err := client.ConnectWith(func() (net.Conn, error) {
+		conn, peer := net.Pipe()
+
+		go func() {
+			defer peer.Close()
+
+			if _, _, err := codec.Read(peer, 0); err != nil {
+				return
+			}
+
+			bytes, _ := codec.Encode(pomeloPacket.Handshake, []byte(`{"code":200,"sys":{"heartbeat":30}}`))
+			if _, err := peer.Write(bytes); err != nil {
+				return
+			}
+
+			// handshake ack
+			if _, _, err := codec.Read(peer, 0); err != nil {
+				return
+			}
+
+			// corrupt framing, the packet type is invalid
+			_, _ = peer.Write([]byte{0x7f, 0x00, 0x01, 0x00})
+
+			// the client must not read the following bytes
+			_, _ = io.Copy(io.Discard, peer)
+		}()
+
+		return conn, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err = <-errChan:
+		if !errors.Is(err, cerr.PacketWrongType) {
+			t.Fatalf("err = %v, want %v", err, cerr.PacketWrongType)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("client should be disconnected by the corrupt packet")
+	}
+}
+
+func TestClientVersion(t *testing.T) {
+	// server stub, reject the client version less than 1.2
+	dialFn := func() (net.Conn, error) {
+		conn, peer := net.Pipe()
+
+		go func() {
+			defer peer.Close()
+
+			packets, _, err := pomeloPacket.Read(peer)
+			if err != nil {
+				return
+			}
+
+			var handshake struct {
+				Sys struct {
+					Version    string `json:"version"`
+					LibVersion string `json:"libVersion"`
+					Build      int    `json:"build"`
+				} `json:"sys"`
+				User map[string]interface{} `json:"user"`
+			}
+			if err = jsoniter.Unmarshal(packets[0].Data(), &handshake); err != nil {
+				return
+			}
+
+			code := HandshakeOK
+			if handshake.Sys.Version < "1.2" || handshake.Sys.LibVersion != "cherry-1.3" ||
+				handshake.Sys.Build != 1024 || handshake.User["channel"] != "test" {
+				code = HandshakeVersionTooOld
+			}
+
+			data := fmt.Sprintf(`{"code":%d,"sys":{"heartbeat":30}}`, code)
+			bytes, _ := pomeloPacket.Encode(pomeloPacket.Handshake, []byte(data))
+			if _, err = peer.Write(bytes); err != nil {
+				return
+			}
+
+			_, _, _ = pomeloPacket.Read(peer)
+		}()
+
+		return conn, nil
+	}
+
+	newClient := func(version string) *Client {
+		return New(
+			WithHeartbeat(0),
+			WithHandshakeData(nil, map[string]interface{}{"channel": "test"}),
+			WithClientVersion(version),
+			WithLibVersion("cherry-1.3"),
+			WithBuildNumber(1024),
+		)
+	}
+
+	client := newClient("1.0")
+	if err := client.ConnectWith(dialFn); !errors.Is(err, cerr.ClientVersionTooOld) {
+		t.Fatalf("err = %v, want %v", err, cerr.ClientVersionTooOld)
+	}
+
+	client = newClient("1.2")
+	if err := client.ConnectWith(dialFn); err != nil {
+		t.Fatal(err)
+	}
+	client.Disconnect()
+}
+
+func TestClientConnectWith(t *testing.T) {
+	// newTestServer connects by ConnectWith
+	client := newTestServer(t, echoHandler,
+		WithSkipHandshake(true),
+		WithHeartbeat(0),
+		WithRequestTimeout(3*time.Second),
+	)
+	defer client.Disconnect()
+
+	rsp, err := client.RequestRaw("game.player.ping", []byte("ping"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(rsp.Data) != "ping" {
+		t.Fatalf("response data = %s", rsp.Data)
+	}
+}
+
+func TestClientWS(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+
+	// echo server, response the request data
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		wsConn := cconnector.NewWSConn(c)
+		serveTest(&wsConn, NewPomeloCodec(), echoHandler)
+	}))
+	defer server.Close()
+
+	client := New(
+		WithSkipHandshake(true),
+		WithHeartbeat(0),
+		WithRequestTimeout(3*time.Second),
+	)
+
+	if err := client.ConnectToWS(strings.TrimPrefix(server.URL, "http://"), "/"); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect()
+
+	rsp, err := client.RequestRaw("game.player.ping", []byte("ping"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(rsp.Data) != "ping" {
+		t.Fatalf("response data = %s", rsp.Data)
+	}
+}
+
+func TestClientKickDrain(t *testing.T) {
+	// response the ping request after the kick packet, the wait request has no response
+	var requests []*pomeloMessage.Message
+	handler := func(s *testServer, _ *pomeloPacket.Packet, m *pomeloMessage.Message) {
+		if requests = append(requests, m); len(requests) < 2 {
+			return
+		}
+
+		_ = s.write(pomeloPacket.Kick, []byte("kick"))
+
+		for _, request := range requests {
+			if request.Route == "game.player.ping" {
+				_ = s.respond(request)
+			}
+		}
+	}
+
+	client := newTestServer(t, handler,
+		WithSkipHandshake(true),
+		WithHeartbeat(0),
+		WithRequestTimeout(10*time.Second),
+	)
+
+	var (
+		wg      sync.WaitGroup
+		pingErr error
+		waitErr error
+	)
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, pingErr = client.RequestRaw("game.player.ping", []byte("ping"))
+	}()
+	go func() {
+		defer wg.Done()
+		_, waitErr = client.RequestRaw("game.player.wait", []byte("wait"))
+	}()
+
+	begin := time.Now()
+	wg.Wait()
+
+	if pingErr != nil {
+		t.Fatalf("response before disconnect should be received. err = %v", pingErr)
+	}
+
+	if !errors.Is(waitErr, cerr.ClientDisconnected) {
+		t.Fatalf("pending request err = %v, want ClientDisconnected", waitErr)
+	}
+
+	if time.Since(begin) > 5*time.Second {
+		t.Fatal("pending request should be released when disconnected")
+	}
+}
+
+func TestClientEncodeError(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer peer.Close()
+
+	client := New(
+		WithSkipHandshake(true),
+		WithHeartbeat(0),
+	)
+	client.conn = conn
+
+	if err := client.handleHandshake(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect()
+
+	longRoute := strings.Repeat("a", 256)
+
+	if err := client.Notify("game.player.notify", "not a proto message"); err == nil {
+		t.Fatal("notify serialize error should be returned")
+	}
+
+	if _, err := client.Send(pomeloMessage.Notify, longRoute, &cproto.Response{}); !errors.Is(err, cerr.MessageRouteTooLong) {
+		t.Fatalf("notify err = %v, want MessageRouteTooLong", err)
+	}
+
+	if _, err := client.Request("game.player.request", "not a proto message"); err == nil {
+		t.Fatal("request serialize error should be returned")
+	}
+
+	if _, err := client.RequestRaw(longRoute, []byte("data")); !errors.Is(err, cerr.MessageRouteTooLong) {
+		t.Fatalf("request err = %v, want MessageRouteTooLong", err)
+	}
+
+	if n := client.InflightCount(); n != 0 {
+		t.Fatalf("pending request should not be registered. [count = %d]", n)
+	}
+}
+
+func TestClientMutualTLS(t *testing.T) {
+	ca, caKey := newTestCert(t, nil, nil, "test-ca")
+	serverCert, serverKey := newTestCert(t, ca, caKey, "localhost")
+	clientCert, clientKey := newTestCert(t, ca, caKey, "client")
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{serverCert.Raw}, PrivateKey: serverKey}},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MaxVersion:   tls.VersionTLS12, // client certificate errors are returned by dial
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// echo server, response the request data
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveTest(conn, NewPomeloCodec(), echoHandler)
+		}
+	}()
+
+	// without client certificate
+	client := New(
+		WithSkipHandshake(true),
+		WithHeartbeat(0),
+		WithTLSConfig(&tls.Config{RootCAs: pool, ServerName: "localhost"}),
+	)
+	if err = client.ConnectToTCP(listener.Addr().String()); err == nil {
+		client.Disconnect()
+		t.Fatal("connect without client certificate should fail")
+	}
+
+	client = New(
+		WithSkipHandshake(true),
+		WithHeartbeat(0),
+		WithRequestTimeout(3*time.Second),
+		WithTLSConfig(&tls.Config{
+			Certificates: []tls.Certificate{{Certificate: [][]byte{clientCert.Raw}, PrivateKey: clientKey}},
+			RootCAs:      pool,
+			ServerName:   "localhost",
+		}),
+	)
+	if err = client.ConnectToTCP(listener.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect()
+
+	rsp, err := client.RequestRaw("game.player.ping", []byte("ping"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(rsp.Data) != "ping" {
+		t.Fatalf("response data = %s", rsp.Data)
+	}
+}
+
+// newTestCert create a certificate signed by parent, it is self-signed(CA) if parent is nil
+func newTestCert(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, commonName string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{commonName},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, key
+}
+
+func TestClientSendBatch(t *testing.T) {
+	// response the requests, count the notifies
+	notifyChan := make(chan string, 8)
+	handler := func(s *testServer, _ *pomeloPacket.Packet, m *pomeloMessage.Message) {
+		if m.Type == pomeloMessage.Notify {
+			notifyChan <- m.Route
+			return
+		}
+		_ = s.respond(m)
+	}
+
+	client := newTestServer(t, handler,
+		WithSkipHandshake(true),
+		WithHeartbeat(0),
+		WithRequestTimeout(3*time.Second),
+	)
+	defer client.Disconnect()
+
+	var wg sync.WaitGroup
+	onResponse := func(rsp *pomeloMessage.Message, err error) {
+		defer wg.Done()
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	wg.Add(2)
+	err := client.SendBatch([]OutgoingMessage{
+		{Type: pomeloMessage.Notify, Route: "game.player.move", Val: &cproto.Response{Code: 1}},
+		{Type: pomeloMessage.Request, Route: "game.player.ping", Val: &cproto.Response{Code: 2}, OnResponse: onResponse},
+		{Type: pomeloMessage.Notify, Route: "game.player.move", Val: &cproto.Response{Code: 3}},
+		{Type: pomeloMessage.Request, Route: "game.player.ping", Val: &cproto.Response{Code: 4}, OnResponse: onResponse},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	if n := len(notifyChan); n != 2 {
+		t.Fatalf("notify count = %d, want 2", n)
+	}
+
+	// nothing is sent if any message fails to encode
+	err = client.SendBatch([]OutgoingMessage{
+		{Type: pomeloMessage.Request, Route: "game.player.ping", Val: &cproto.Response{}},
+		{Type: pomeloMessage.Notify, Route: strings.Repeat("a", 256), Val: &cproto.Response{}},
+	})
+	if !errors.Is(err, cerr.MessageRouteTooLong) {
+		t.Fatalf("err = %v, want MessageRouteTooLong", err)
+	}
+
+	if n := client.InflightCount(); n != 0 {
+		t.Fatalf("pending request should not be registered. [count = %d]", n)
+	}
+}
+
+func newBenchmarkClient(b *testing.B) *Client {
+	conn, peer := net.Pipe()
+	go func() {
+		_, _ = io.Copy(io.Discard, peer)
+	}()
+
+	client := New(
+		WithSkipHandshake(true),
+		WithHeartbeat(0),
+	)
+	client.conn = conn
+
+	if err := client.handleHandshake(); err != nil {
+		b.Fatal(err)
+	}
+
+	return client
+}
+
+func BenchmarkClientNotify(b *testing.B) {
+	client := newBenchmarkClient(b)
+	defer client.Disconnect()
+
+	val := &cproto.Response{Code: 1}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 10; j++ {
+			_ = client.Notify("game.player.move", val)
+		}
+	}
+}
+
+func BenchmarkClientSendBatch(b *testing.B) {
+	client := newBenchmarkClient(b)
+	defer client.Disconnect()
+
+	msgs := make([]OutgoingMessage, 10)
+	for j := range msgs {
+		msgs[j] = OutgoingMessage{Type: pomeloMessage.Notify, Route: "game.player.move", Val: &cproto.Response{Code: 1}}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = client.SendBatch(msgs)
+	}
+}
+
+func TestClientPushBacklog(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer peer.Close()
+
+	client := New(
+		WithSkipHandshake(true),
+		WithHeartbeat(0),
+		WithPushBacklog(2, true),
+	)
+	client.conn = conn
+
+	gate := make(chan struct{})
+	entered := make(chan struct{}, 8)
+	received := make(chan uint, 8)
+	client.On("game.player.push", func(msg *pomeloMessage.Message) {
+		entered <- struct{}{}
+		<-gate
+		received <- uint(msg.Data[0])
+	})
+
+	if err := client.handleHandshake(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect()
+
+	// the read loop doesn't block when the push handler is slow
+	for i := 0; i < 5; i++ {
+		m := &pomeloMessage.Message{Type: pomeloMessage.Push, Route: "game.player.push", Data: []byte{byte(i)}}
+		data, _ := pomeloMessage.Encode(m)
+		bytes, _ := pomeloPacket.Encode(pomeloPacket.Data, data)
+
+		_ = peer.SetWriteDeadline(time.Now().Add(time.Second))
+		if _, err := peer.Write(bytes); err != nil {
+			t.Fatalf("read loop is blocked. err = %v", err)
+		}
+
+		// wait until the first message is being processed
+		if i == 0 {
+			<-entered
+		}
+	}
+
+	for i := 0; client.PushDropped() < 2 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if n := client.PushDropped(); n != 2 {
+		t.Fatalf("dropped = %d, want 2", n)
+	}
+
+	close(gate)
+
+	// the first message is being processed, the oldest queued messages are dropped
+	for _, want := range []uint{0, 3, 4} {
+		select {
+		case got := <-received:
+			if got != want {
+				t.Fatalf("received = %d, want %d", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("push message not received")
+		}
+	}
+}
+
+func TestClientTimeoutWithFullPushQueue(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer peer.Close()
+
+	client := New(
+		WithSkipHandshake(true),
+		WithHeartbeat(0),
+		WithRequestTimeout(200*time.Millisecond),
+		WithPushBacklog(1, false),
+	)
+	client.conn = conn
+
+	gate := make(chan struct{})
+	defer close(gate)
+
+	client.On("game.player.push", func(_ *pomeloMessage.Message) {
+		<-gate
+	})
+
+	if err := client.handleHandshake(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the read loop blocks on the full push queue, requests are not read by the peer
+	go func() {
+		m := &pomeloMessage.Message{Type: pomeloMessage.Push, Route: "game.player.push"}
+		data, _ := pomeloMessage.Encode(m)
+		bytes, _ := pomeloPacket.Encode(pomeloPacket.Data, data)
+		for i := 0; i < 3; i++ {
+			if _, err := peer.Write(bytes); err != nil {
+				return
+			}
+		}
+
+		_, _ = io.Copy(io.Discard, peer)
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.RequestRaw("game.player.ping", []byte("ping"))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, cerr.ClientRequestTimeout) {
+			t.Fatalf("err = %v, want ClientRequestTimeout", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("request timeout is blocked by the full push queue")
+	}
+
+	// disconnect releases the blocked read loop
+	client.Disconnect()
+	if client.IsConnected() {
+		t.Fatal("client is still connected")
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram(0)
+	for i := 1; i <= 100; i++ {
+		h.ObserveLatency("game.player.ping", time.Duration(i)*time.Millisecond)
+	}
+	h.IncTimeout("game.player.ping")
+	h.IncKick()
+
+	snapshot := h.Snapshot()
+	s := snapshot.Routes["game.player.ping"]
+
+	if s.Count != 100 || s.Timeouts != 1 || snapshot.Kicks != 1 {
+		t.Fatalf("snapshot = %+v", snapshot)
+	}
+
+	if s.P50 != 50*time.Millisecond || s.P95 != 95*time.Millisecond || s.P99 != 99*time.Millisecond || s.Max != 100*time.Millisecond {
+		t.Fatalf("percentile error. snapshot = %+v", s)
+	}
+}
+
 func BenchmarkClient(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		client := New(
@@ -30,3 +1057,464 @@ func BenchmarkClient(b *testing.B) {
 		client.Disconnect()
 	}
 }
+
+func TestClientHeartbeatInterval(t *testing.T) {
+	tests := []struct {
+		opts   []Option
+		server int
+		want   int
+	}{
+		{server: 60, want: 30},
+		{server: 0, want: 30},
+		{opts: []Option{WithHeartbeat(0)}, server: 0, want: 0},
+		{opts: []Option{WithHeartbeatInterval(5)}, server: 60, want: 5},
+		{opts: []Option{WithHeartbeatInterval(-1)}, server: 60, want: 30},
+		{opts: []Option{WithHeartbeatClamp(10, 20)}, server: 60, want: 20},
+		{opts: []Option{WithHeartbeatClamp(10, 20)}, server: 4, want: 10},
+		{opts: []Option{WithHeartbeatClamp(30, 20)}, server: 4, want: 2},
+	}
+
+	for i, tt := range tests {
+		client := New(tt.opts...)
+		if got := client.heartbeatInterval(tt.server); got != tt.want {
+			t.Fatalf("[%d] heartbeat = %d, want %d", i, got, tt.want)
+		}
+	}
+}
+
+func TestClientClose(t *testing.T) {
+	// response the request after the logout notify is received
+	var (
+		request   *pomeloMessage.Message
+		routeChan = make(chan string, 4)
+	)
+
+	handler := func(s *testServer, _ *pomeloPacket.Packet, m *pomeloMessage.Message) {
+		routeChan <- m.Route
+
+		if m.Type == pomeloMessage.Request {
+			request = m
+			return
+		}
+
+		if m.Route == "game.player.logout" && request != nil {
+			time.Sleep(100 * time.Millisecond)
+			_ = s.respond(request)
+		}
+	}
+
+	client := newTestServer(t, handler,
+		WithSkipHandshake(true),
+		WithHeartbeat(0),
+		WithRequestTimeout(5*time.Second),
+	)
+
+	rspChan := make(chan error, 1)
+	go func() {
+		_, err := client.RequestRaw("game.player.save", []byte("save"))
+		rspChan <- err
+	}()
+
+	if route := <-routeChan; route != "game.player.save" {
+		t.Fatalf("route = %s", route)
+	}
+
+	if err := client.Notify("game.player.logout", []byte("logout")); err != nil {
+		t.Fatal(err)
+	}
+
+	closeChan := make(chan error, 1)
+	go func() {
+		closeChan <- client.Close(3 * time.Second)
+	}()
+
+	// wait for closing
+	for !client.isClosing() {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := client.Notify("game.player.chat", []byte("chat")); !errors.Is(err, cerr.ClientClosing) {
+		t.Fatalf("notify err = %v, want %v", err, cerr.ClientClosing)
+	}
+
+	if err := <-rspChan; err != nil {
+		t.Fatalf("pending request should be responded. err = %v", err)
+	}
+
+	if err := <-closeChan; err != nil {
+		t.Fatal(err)
+	}
+
+	if client.IsConnected() {
+		t.Fatal("client should be disconnected")
+	}
+}
+
+func TestClientCloseTimeout(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer peer.Close()
+
+	client := New(
+		WithSkipHandshake(true),
+		WithHeartbeat(0),
+		WithRequestTimeout(5*time.Second),
+	)
+	client.conn = conn
+
+	if err := client.handleHandshake(); err != nil {
+		t.Fatal(err)
+	}
+
+	// read and never response
+	go func() {
+		_, _ = io.Copy(io.Discard, peer)
+	}()
+
+	go func() {
+		_, _ = client.RequestRaw("game.player.wait", []byte("wait"))
+	}()
+
+	for client.InflightCount() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := client.Close(100 * time.Millisecond); !errors.Is(err, cerr.ClientCloseTimeout) {
+		t.Fatalf("close err = %v, want %v", err, cerr.ClientCloseTimeout)
+	}
+
+	if client.IsConnected() {
+		t.Fatal("client should be disconnected")
+	}
+}
+
+func TestClientMessageIDWrap(t *testing.T) {
+	client := New()
+	client.nextID = math.MaxUint32 - 1
+
+	// id 1 is waiting for response
+	reqCtx := NewRequestContext(time.Second)
+	client.responseMaps.Store(uint(1), &reqCtx)
+
+	want := []uint{math.MaxUint32, 2, 3}
+	for _, w := range want {
+		if id := client.nextMessageID(); id != w {
+			t.Fatalf("id = %d, want %d", id, w)
+		}
+	}
+}
+
+func TestClientPushChan(t *testing.T) {
+	client := New(WithPushChan(1))
+
+	handled := make(chan string, 1)
+	client.On("game.player.handled", func(msg *pomeloMessage.Message) {
+		handled <- msg.Route
+	})
+
+	for _, route := range []string{"game.player.handled", "game.player.first", "game.player.dropped"} {
+		client.processMessage(&pomeloMessage.Message{Type: pomeloMessage.Push, Route: route})
+	}
+
+	if route := <-handled; route != "game.player.handled" {
+		t.Fatalf("handled route = %s", route)
+	}
+
+	if msg := <-client.PushChan(); msg.Route != "game.player.first" {
+		t.Fatalf("push chan route = %s", msg.Route)
+	}
+
+	if client.PushDropped() != 1 {
+		t.Fatalf("dropped = %d", client.PushDropped())
+	}
+
+	// removed handler falls back to PushChan
+	client.Off("game.player.handled")
+	client.processMessage(&pomeloMessage.Message{Type: pomeloMessage.Push, Route: "game.player.handled"})
+
+	if msg := <-client.PushChan(); msg.Route != "game.player.handled" {
+		t.Fatalf("push chan route = %s", msg.Route)
+	}
+}
+
+func TestClientRequestRetry(t *testing.T) {
+	// drop the first dropCount requests, then response
+	var (
+		dropCount int32 = 2
+		idChan          = make(chan uint, 8)
+	)
+
+	handler := func(s *testServer, _ *pomeloPacket.Packet, m *pomeloMessage.Message) {
+		idChan <- m.ID
+
+		if atomic.AddInt32(&dropCount, -1) >= 0 {
+			return
+		}
+		_ = s.respond(m)
+	}
+
+	client := newTestServer(t, handler,
+		WithSkipHandshake(true),
+		WithHeartbeat(0),
+		WithRequestTimeout(100*time.Millisecond),
+		WithRequestRetry(3, 20*time.Millisecond),
+	)
+
+	rsp, err := client.RequestRaw("game.player.enter", []byte("enter"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(rsp.Data) != "enter" {
+		t.Fatalf("rsp data = %s", rsp.Data)
+	}
+
+	// each attempt uses a new message id
+	ids := map[uint]bool{}
+	for i := 0; i < 3; i++ {
+		ids[<-idChan] = true
+	}
+
+	if len(ids) != 3 {
+		t.Fatalf("ids = %v, want 3 different ids", ids)
+	}
+
+	// the timeout error is returned after the last attempt
+	atomic.StoreInt32(&dropCount, 3)
+	if _, err = client.RequestRaw("game.player.enter", []byte("enter")); !errors.Is(err, cerr.ClientRequestTimeout) {
+		t.Fatalf("err = %v, want %v", err, cerr.ClientRequestTimeout)
+	}
+
+	client.Disconnect()
+}
+
+func TestClientPushOrder(t *testing.T) {
+	const count = 2000
+
+	for _, backlog := range []int{0, 16} {
+		conn, peer := net.Pipe()
+
+		client := New(
+			WithSkipHandshake(true),
+			WithHeartbeat(0),
+			WithPushBacklog(backlog, false),
+		)
+		client.conn = conn
+
+		received := make(chan uint32, count)
+		client.On("game.room.sync", func(msg *pomeloMessage.Message) {
+			received <- binary.BigEndian.Uint32(msg.Data)
+		})
+
+		if err := client.handleHandshake(); err != nil {
+			t.Fatal(err)
+		}
+
+		// several pushes in one write, and a push per write
+		go func() {
+			var batch []byte
+			for i := uint32(0); i < count; i++ {
+				data := make([]byte, 4)
+				binary.BigEndian.PutUint32(data, i)
+
+				m := &pomeloMessage.Message{Type: pomeloMessage.Push, Route: "game.room.sync", Data: data}
+				encoded, _ := pomeloMessage.Encode(m)
+				bytes, _ := pomeloPacket.Encode(pomeloPacket.Data, encoded)
+
+				if batch = append(batch, bytes...); i%3 == 0 {
+					continue
+				}
+
+				if _, err := peer.Write(batch); err != nil {
+					return
+				}
+				batch = batch[:0]
+			}
+			_, _ = peer.Write(batch)
+		}()
+
+		for want := uint32(0); want < count; want++ {
+			select {
+			case got := <-received:
+				if got != want {
+					t.Fatalf("backlog = %d, received = %d, want %d", backlog, got, want)
+				}
+			case <-time.After(3 * time.Second):
+				t.Fatalf("backlog = %d, push message %d not received", backlog, want)
+			}
+		}
+
+		if seq := atomic.LoadUint64(&client.pushDelivered); seq != count {
+			t.Fatalf("backlog = %d, delivered seq = %d, want %d", backlog, seq, count)
+		}
+
+		client.Disconnect()
+		_ = peer.Close()
+	}
+}
+
+func TestClientReconnect(t *testing.T) {
+	var (
+		dials   int32
+		peers   = make(chan net.Conn, 4)
+		release = make(chan struct{})
+	)
+
+	// the first redial fails, the second one waits for release
+	dialFn := func() (net.Conn, error) {
+		switch atomic.AddInt32(&dials, 1) {
+		case 2:
+			return nil, errors.New("dial fail")
+		case 3:
+			<-release
+		}
+
+		conn, peer := net.Pipe()
+		peers <- peer
+		go serveTest(peer, NewPomeloCodec(), echoHandler)
+		return conn, nil
+	}
+
+	client := New(
+		WithHeartbeat(0),
+		WithRequestTimeout(3*time.Second),
+		WithReconnect(3, 50*time.Millisecond),
+	)
+
+	if err := client.ConnectWith(dialFn); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect()
+
+	// kill the server connection
+	begin := time.Now()
+	_ = (<-peers).Close()
+
+	for !client.IsReconnecting() {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the request is held in the write queue until reconnected
+	rspChan := make(chan *pomeloMessage.Message, 1)
+	go func() {
+		rsp, err := client.RequestRaw("game.player.ping", []byte("held"))
+		if err != nil {
+			t.Error(err)
+		}
+		rspChan <- rsp
+	}()
+
+	for client.queuedCount() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	event := <-client.ReconnectChan()
+	if !event.Succeed || event.Retries != 2 {
+		t.Fatalf("event = %+v", event)
+	}
+
+	// backoff delay 50ms + 100ms
+	if elapsed := time.Since(begin); elapsed < 150*time.Millisecond {
+		t.Fatalf("reconnect without backoff. [elapsed = %v]", elapsed)
+	}
+
+	if rsp := <-rspChan; rsp == nil || string(rsp.Data) != "held" {
+		t.Fatalf("rsp = %v", rsp)
+	}
+
+	if n := client.queuedCount(); n != 0 || client.RemoteAddr() == "" {
+		t.Fatalf("queued = %d, addr = %s", n, client.RemoteAddr())
+	}
+}
+
+// testKCPSession a kcp session over the pipe, records the tuning
+type testKCPSession struct {
+	net.Conn
+	window  [2]int
+	noDelay [4]int
+	stream  bool
+}
+
+func (s *testKCPSession) SetWindowSize(sndwnd, rcvwnd int) {
+	s.window = [2]int{sndwnd, rcvwnd}
+}
+
+func (s *testKCPSession) SetNoDelay(nodelay, interval, resend, nc int) {
+	s.noDelay = [4]int{nodelay, interval, resend, nc}
+}
+
+func (s *testKCPSession) SetStreamMode(enable bool) {
+	s.stream = enable
+}
+
+func TestClientKCP(t *testing.T) {
+	var session *testKCPSession
+	dialFn := func(addr string) (IKCPSession, error) {
+		if addr != "127.0.0.1:3250" {
+			return nil, fmt.Errorf("addr = %s", addr)
+		}
+
+		conn, peer := net.Pipe()
+		go serveTest(peer, NewPomeloCodec(), echoHandler)
+
+		session = &testKCPSession{Conn: conn}
+		return session, nil
+	}
+
+	client := New(
+		WithHeartbeat(0),
+		WithRequestTimeout(3*time.Second),
+	)
+
+	if err := client.ConnectToKCP("127.0.0.1:3250", dialFn, DefaultKCPConfig()); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect()
+
+	if session.window != [2]int{128, 128} || session.noDelay != [4]int{1, 10, 2, 1} || !session.stream {
+		t.Fatalf("session = %+v", session)
+	}
+
+	rsp, err := client.RequestRaw("game.player.ping", []byte("ping"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(rsp.Data) != "ping" {
+		t.Fatalf("response data = %s", rsp.Data)
+	}
+}
+
+func TestClientMaxPacketSizeDefault(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer peer.Close()
+
+	client := New(
+		WithSkipHandshake(true),
+		WithHeartbeat(0),
+	)
+	client.conn = conn
+
+	errChan := make(chan error, 1)
+	client.OnDisconnected = func(err error) {
+		errChan <- err
+	}
+
+	if err := client.handleHandshake(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the header claims 1mb, only the header is sent
+	if _, err := peer.Write([]byte{pomeloPacket.Data, 0x10, 0x00, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-errChan:
+		if !errors.Is(err, cerr.PacketSizeExceed) {
+			t.Fatalf("disconnect err = %v, want %v", err, cerr.PacketSizeExceed)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("client should be disconnected by the oversized header")
+	}
+}
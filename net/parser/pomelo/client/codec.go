@@ -0,0 +1,39 @@
+package pomeloClient
+
+import (
+	"net"
+
+	pomeloPacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+)
+
+type (
+	// ICodec packet framing of the connection, the default is the pomelo packet codec.
+	// the client uses the pomelo packet types(Handshake, Heartbeat, Data...),
+	// a variant protocol maps its own type values in Read and Encode.
+	// Read returns an error if the framing is corrupt, the client disconnects since the stream can't be resynced.
+	// an incomplete packet must be kept by the codec(e.g. io.ReadFull or pomeloPacket.DecodeStream) rather than reported.
+	ICodec interface {
+		Read(conn net.Conn, maxSize int) ([]*pomeloPacket.Packet, bool, error) // read and decode the packets, isBreak is true if the connection is broken
+		Encode(typ pomeloPacket.Type, data []byte) ([]byte, error)             // encode a packet
+		EncodeData(data []byte) ([]byte, error)                                // encode the data packet, may be split into fragments
+	}
+
+	pomeloCodec struct{}
+)
+
+// NewPomeloCodec returns the default pomelo packet codec
+func NewPomeloCodec() ICodec {
+	return pomeloCodec{}
+}
+
+func (pomeloCodec) Read(conn net.Conn, maxSize int) ([]*pomeloPacket.Packet, bool, error) {
+	return pomeloPacket.ReadWithLimit(conn, maxSize)
+}
+
+func (pomeloCodec) Encode(typ pomeloPacket.Type, data []byte) ([]byte, error) {
+	return pomeloPacket.Encode(typ, data)
+}
+
+func (pomeloCodec) EncodeData(data []byte) ([]byte, error) {
+	return pomeloPacket.EncodeFragments(pomeloPacket.Data, data)
+}
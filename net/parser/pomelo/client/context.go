@@ -9,7 +9,16 @@ import (
 type (
 	RequestContext struct {
 		*time.Ticker
-		Chan chan *cmsg.Message
+		Chan   chan *cmsg.Message
+		Route  string    // request route
+		SentAt time.Time // request send time
+	}
+
+	// PendingInfo 等待响应的请求快照
+	PendingInfo struct {
+		ID    uint          // message id
+		Route string        // request route
+		Age   time.Duration // elapsed time since sent
 	}
 )
 
@@ -17,6 +26,7 @@ func NewRequestContext(t time.Duration) RequestContext {
 	return RequestContext{
 		Ticker: time.NewTicker(t),
 		Chan:   make(chan *cmsg.Message, 1),
+		SentAt: time.Now(),
 	}
 }
 
@@ -0,0 +1,86 @@
+package pomeloClient
+
+import (
+	"net"
+
+	cerr "github.com/cherry-game/cherry/error"
+)
+
+type (
+	// IKCPSession the kcp session, such as *kcp.UDPSession of github.com/xtaci/kcp-go.
+	// the kcp library is not a dependency of the client, the session is created by KCPDialFn.
+	IKCPSession interface {
+		net.Conn
+		SetWindowSize(sndwnd, rcvwnd int)             // send and receive window size(packets)
+		SetNoDelay(nodelay, interval, resend, nc int) // nodelay mode, update interval(ms), fast resend, no congestion control
+		SetStreamMode(enable bool)                    // stream mode, the pomelo packets are framed by the codec
+	}
+
+	// KCPDialFn dial the kcp session to addr, e.g. kcp.DialWithOptions(addr, nil, 0, 0)
+	KCPDialFn func(addr string) (IKCPSession, error)
+
+	// KCPConfig tuning of the kcp session
+	KCPConfig struct {
+		SendWindow   int  // send window size, zero keeps the session default
+		RecvWindow   int  // receive window size, zero keeps the session default
+		NoDelay      bool // enable nodelay mode
+		Interval     int  // internal update interval(ms)
+		Resend       int  // fast resend after the number of ACK skipped, zero is disabled
+		NoCongestion bool // disable congestion control
+	}
+
+	// kcpConn the kcp session tuned by KCPConfig, packets are framed over the kcp stream
+	// by the client codec, so the Client logic is the same as the tcp connection.
+	kcpConn struct {
+		IKCPSession
+	}
+)
+
+// DefaultKCPConfig returns the fast mode config for the low-latency games
+func DefaultKCPConfig() KCPConfig {
+	return KCPConfig{
+		SendWindow:   128,
+		RecvWindow:   128,
+		NoDelay:      true,
+		Interval:     10,
+		Resend:       2,
+		NoCongestion: true,
+	}
+}
+
+func newKCPConn(session IKCPSession, config KCPConfig) *kcpConn {
+	if config.SendWindow > 0 || config.RecvWindow > 0 {
+		session.SetWindowSize(config.SendWindow, config.RecvWindow)
+	}
+
+	session.SetNoDelay(boolToInt(config.NoDelay), config.Interval, config.Resend, boolToInt(config.NoCongestion))
+	session.SetStreamMode(true)
+
+	return &kcpConn{
+		IKCPSession: session,
+	}
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// ConnectToKCP connect to the kcp server by the session of dialFn, the session is tuned by config.
+// dialFn is called again when reconnecting, see WithReconnect.
+func (p *Client) ConnectToKCP(addr string, dialFn KCPDialFn, config KCPConfig) error {
+	if dialFn == nil {
+		return cerr.Error("kcp dial function is nil.")
+	}
+
+	return p.ConnectWith(func() (net.Conn, error) {
+		session, err := dialFn(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		return newKCPConn(session, config), nil
+	})
+}
@@ -0,0 +1,154 @@
+package pomeloClient
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultMaxSamples = 10000 // 每个路由保留的最近延迟样本数量
+)
+
+type (
+	// IMetrics 客户端指标收集
+	IMetrics interface {
+		ObserveLatency(route string, d time.Duration) // 请求往返延迟
+		IncTimeout(route string)                      // 请求超时
+		IncKick()                                     // 被服务端踢下线
+	}
+
+	noopMetrics struct{}
+
+	// Histogram 内存中的延迟统计,按路由保留最近的样本用于计算百分位
+	Histogram struct {
+		sync.Mutex
+		maxSamples int
+		routes     map[string]*routeSamples
+		kicks      int64
+	}
+
+	routeSamples struct {
+		samples  []time.Duration
+		next     int   // 样本写入位置(环形)
+		count    int64 // 总请求数
+		timeouts int64 // 超时数
+	}
+
+	// LatencySnapshot 路由的延迟快照
+	LatencySnapshot struct {
+		Count    int64
+		Timeouts int64
+		P50      time.Duration
+		P95      time.Duration
+		P99      time.Duration
+		Max      time.Duration
+	}
+
+	// MetricsSnapshot Histogram快照
+	MetricsSnapshot struct {
+		Routes map[string]LatencySnapshot
+		Kicks  int64
+	}
+)
+
+func (noopMetrics) ObserveLatency(string, time.Duration) {}
+
+func (noopMetrics) IncTimeout(string) {}
+
+func (noopMetrics) IncKick() {}
+
+// NewHistogram maxSamples为每个路由保留的样本数量,小于1时使用默认值
+func NewHistogram(maxSamples int) *Histogram {
+	if maxSamples < 1 {
+		maxSamples = defaultMaxSamples
+	}
+
+	return &Histogram{
+		maxSamples: maxSamples,
+		routes:     make(map[string]*routeSamples),
+	}
+}
+
+func (h *Histogram) route(route string) *routeSamples {
+	r, found := h.routes[route]
+	if !found {
+		r = &routeSamples{}
+		h.routes[route] = r
+	}
+	return r
+}
+
+func (h *Histogram) ObserveLatency(route string, d time.Duration) {
+	h.Lock()
+	defer h.Unlock()
+
+	r := h.route(route)
+	r.count++
+
+	if len(r.samples) < h.maxSamples {
+		r.samples = append(r.samples, d)
+		return
+	}
+
+	r.samples[r.next] = d
+	r.next = (r.next + 1) % h.maxSamples
+}
+
+func (h *Histogram) IncTimeout(route string) {
+	h.Lock()
+	defer h.Unlock()
+
+	h.route(route).timeouts++
+}
+
+func (h *Histogram) IncKick() {
+	atomic.AddInt64(&h.kicks, 1)
+}
+
+// Snapshot 返回各路由的延迟百分位
+func (h *Histogram) Snapshot() MetricsSnapshot {
+	h.Lock()
+	defer h.Unlock()
+
+	snapshot := MetricsSnapshot{
+		Routes: make(map[string]LatencySnapshot, len(h.routes)),
+		Kicks:  atomic.LoadInt64(&h.kicks),
+	}
+
+	for route, r := range h.routes {
+		sorted := make([]time.Duration, len(r.samples))
+		copy(sorted, r.samples)
+		sort.Slice(sorted, func(i, j int) bool {
+			return sorted[i] < sorted[j]
+		})
+
+		s := LatencySnapshot{
+			Count:    r.count,
+			Timeouts: r.timeouts,
+		}
+
+		if len(sorted) > 0 {
+			s.P50 = percentile(sorted, 0.50)
+			s.P95 = percentile(sorted, 0.95)
+			s.P99 = percentile(sorted, 0.99)
+			s.Max = sorted[len(sorted)-1]
+		}
+
+		snapshot.Routes[route] = s
+	}
+
+	return snapshot
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	idx := int(float64(len(sorted))*p+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
@@ -1,18 +1,54 @@
 package pomeloClient
 
 import (
+	"crypto/tls"
 	"time"
 
+	cerr "github.com/cherry-game/cherry/error"
 	cfacade "github.com/cherry-game/cherry/facade"
+	cserializer "github.com/cherry-game/cherry/net/serializer"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// handshake response code
+const (
+	HandshakeOK            = 200 // handshake success
+	HandshakeFail          = 500 // handshake fail
+	HandshakeVersionTooOld = 501 // client version is too old
+	HandshakeServerFull    = 503 // the server is full, reject the new connection
 )
 
 type (
 	options struct {
 		serializer     cfacade.ISerializer // protocol serializer
 		heartBeat      int                 // second
+		heartBeatTimes int                 // disconnect if no packet received in heartBeat * heartBeatTimes
+		heartBeatFixed int                 // second, takes precedence over the heartbeat of the server
+		heartBeatMin   int                 // second, the minimum heartbeat interval, zero is unlimited
+		heartBeatMax   int                 // second, the maximum heartbeat interval, zero is unlimited
 		requestTimeout time.Duration       // Send request timeout
+		writeTimeout   time.Duration       // packet write timeout, zero is no timeout
+		readTimeout    time.Duration       // packet read timeout, refreshed before each read, zero is no timeout
+		dialTimeout    time.Duration       // dial timeout, zero is no timeout
+		keepAlive      time.Duration       // tcp keepalive period, zero is the default of net.Dialer, negative is disabled
 		handshake      string              // handshake content
+		clientVersion  string              // client version, sent in the sys block of the handshake
+		libVersion     string              // client library version, sent in the sys block of the handshake
+		buildNumber    int                 // client build number, sent in the sys block of the handshake
 		isErrorBreak   bool                // an error occurs,is it break
+		skipHandshake  bool                // skip handshake, send/receive data packet only
+		reconnectMax   int                 // max reconnect retries, zero is disabled
+		reconnectDelay time.Duration       // reconnect base delay, doubled on each retry
+		maxInflight    int                 // max concurrent requests, zero is unlimited
+		retryAttempts  int                 // max attempts of a timed out request, zero or one is no retry
+		retryJitter    time.Duration       // random delay in [0, retryJitter) before each retry
+		maxPacketSize  int                 // max length of the received packet, default is DefaultMaxPacketSize, zero is pomeloPacket.MaxPacketSize
+		metrics        IMetrics            // request latency, timeout and kick metrics
+		tlsConfig      *tls.Config         // tls config used by ConnectToTCP/ConnectToWS if not passed in
+		pushBacklog    int                 // push message queue size, zero is dispatched in the read loop
+		pushChanSize   int                 // size of PushChan, zero is disabled
+		pushDropOldest bool                // drop the oldest push message if the queue is full, otherwise block
+		codec          ICodec              // packet codec, default is the pomelo packet codec
 	}
 
 	Option func(options *options)
@@ -31,10 +67,36 @@ type (
 	}
 )
 
+// handshakeError returns the typed error of the handshake code, nil if success.
+// zero code is treated as success for the server which does not set the code.
+func handshakeError(code int) error {
+	switch code {
+	case 0, HandshakeOK:
+		return nil
+	case HandshakeVersionTooOld:
+		return cerr.Errorf("%w [code = %d]", cerr.ClientVersionTooOld, code)
+	case HandshakeServerFull:
+		return cerr.Errorf("%w [code = %d]", cerr.ClientServerFull, code)
+	default:
+		return cerr.Errorf("%w [code = %d]", cerr.ClientHandshakeFail, code)
+	}
+}
+
 func (p *options) Serializer() cfacade.ISerializer {
 	return p.serializer
 }
 
+func serializerByName(name string) (cfacade.ISerializer, bool) {
+	switch name {
+	case "json":
+		return cserializer.NewJSON(), true
+	case "protobuf":
+		return cserializer.NewProtobuf(), true
+	}
+
+	return nil, false
+}
+
 func WithSerializer(serializer cfacade.ISerializer) Option {
 	return func(options *options) {
 		options.serializer = serializer
@@ -47,18 +109,282 @@ func WithHeartbeat(heartBeat int) Option {
 	}
 }
 
+// WithHeartbeatInterval set the heartbeat interval(second) which takes precedence over the value of the server,
+// e.g. a shorter keepalive behind the load balancer with an aggressive idle timeout
+func WithHeartbeatInterval(interval int) Option {
+	return func(options *options) {
+		if interval < 1 {
+			logger().Warnf("heartbeat interval must be positive. [interval = %d]", interval)
+			return
+		}
+
+		options.heartBeat = interval
+		options.heartBeatFixed = interval
+	}
+}
+
+// WithHeartbeatClamp clamp the heartbeat interval(second) of the server into [min, max], zero is unlimited
+func WithHeartbeatClamp(min, max int) Option {
+	return func(options *options) {
+		if min < 0 || max < 0 || (max > 0 && min > max) {
+			logger().Warnf("heartbeat clamp is invalid. [min = %d, max = %d]", min, max)
+			return
+		}
+
+		options.heartBeatMin = min
+		options.heartBeatMax = max
+	}
+}
+
+// heartbeatInterval returns the effective heartbeat interval by the heartbeat of the server
+func (p *options) heartbeatInterval(serverHeartbeat int) int {
+	if p.heartBeatFixed > 0 {
+		return p.heartBeatFixed
+	}
+
+	heartBeat := p.heartBeat
+	if serverHeartbeat > 1 {
+		heartBeat = serverHeartbeat / 2
+	}
+
+	// heartbeat is disabled
+	if heartBeat < 1 {
+		return heartBeat
+	}
+
+	if p.heartBeatMin > 0 && heartBeat < p.heartBeatMin {
+		heartBeat = p.heartBeatMin
+	}
+
+	if p.heartBeatMax > 0 && heartBeat > p.heartBeatMax {
+		heartBeat = p.heartBeatMax
+	}
+
+	return heartBeat
+}
+
+// WithHeartbeatTimeout disconnect if no packet received in heartbeat interval * times, zero is disabled
+func WithHeartbeatTimeout(times int) Option {
+	return func(options *options) {
+		options.heartBeatTimes = times
+	}
+}
+
 func WithRequestTimeout(requestTimeout time.Duration) Option {
 	return func(options *options) {
 		options.requestTimeout = requestTimeout
 	}
 }
 
+// WithWriteTimeout set the deadline of each packet write, the client disconnect when the write timeout
+func WithWriteTimeout(writeTimeout time.Duration) Option {
+	return func(options *options) {
+		options.writeTimeout = writeTimeout
+	}
+}
+
+// WithReadTimeout disconnect if no packet received in readTimeout,
+// it should be greater than the heartbeat interval of the server
+func WithReadTimeout(readTimeout time.Duration) Option {
+	return func(options *options) {
+		options.readTimeout = readTimeout
+	}
+}
+
+// WithDialTimeout set the timeout of dial(including the tls/websocket handshake)
+func WithDialTimeout(dialTimeout time.Duration) Option {
+	return func(options *options) {
+		options.dialTimeout = dialTimeout
+	}
+}
+
+// WithKeepAlive set the tcp keepalive period, negative is disabled
+func WithKeepAlive(keepAlive time.Duration) Option {
+	return func(options *options) {
+		options.keepAlive = keepAlive
+	}
+}
+
 func WithHandshake(handshake string) Option {
 	return func(options *options) {
 		options.handshake = handshake
 	}
 }
 
+// WithHandshakeData build the handshake content by sys and user blocks,
+// e.g. sys = {"type": "ios", "version": "1.0.2"}, user = {"build": 1024}.
+// the handshake is resent when reconnecting.
+func WithHandshakeData(sys, user map[string]interface{}) Option {
+	return func(options *options) {
+		handshake := map[string]interface{}{
+			"sys":  sys,
+			"user": user,
+		}
+
+		bytes, err := jsoniter.Marshal(handshake)
+		if err != nil {
+			logger().Warnf("marshal handshake data error. [handshake = %v, err = %v]", handshake, err)
+			return
+		}
+
+		options.handshake = string(bytes)
+	}
+}
+
+// WithClientVersion set the client version in the handshake sys block,
+// the server rejects the old client with cerr.ClientVersionTooOld
+func WithClientVersion(version string) Option {
+	return func(options *options) {
+		options.clientVersion = version
+	}
+}
+
+// WithLibVersion set the client library version in the handshake sys block
+func WithLibVersion(version string) Option {
+	return func(options *options) {
+		options.libVersion = version
+	}
+}
+
+// WithBuildNumber set the client build number in the handshake sys block
+func WithBuildNumber(build int) Option {
+	return func(options *options) {
+		options.buildNumber = build
+	}
+}
+
+// handshakeContent returns the handshake content, the version fields are merged into the sys block
+func (p *options) handshakeContent() ([]byte, error) {
+	if p.clientVersion == "" && p.libVersion == "" && p.buildNumber == 0 {
+		return []byte(p.handshake), nil
+	}
+
+	handshake := map[string]interface{}{}
+	if p.handshake != "" {
+		if err := jsoniter.Unmarshal([]byte(p.handshake), &handshake); err != nil {
+			return nil, cerr.Errorf("handshake content is not json. [handshake = %s, err = %v]", p.handshake, err)
+		}
+	}
+
+	sys, ok := handshake["sys"].(map[string]interface{})
+	if !ok {
+		sys = map[string]interface{}{}
+	}
+
+	if p.clientVersion != "" {
+		sys["version"] = p.clientVersion
+	}
+
+	if p.libVersion != "" {
+		sys["libVersion"] = p.libVersion
+	}
+
+	if p.buildNumber != 0 {
+		sys["build"] = p.buildNumber
+	}
+
+	handshake["sys"] = sys
+	return jsoniter.Marshal(handshake)
+}
+
+// WithSkipHandshake skip the pomelo handshake after connected, the client is working in raw data mode.
+// dictionary and serializer negotiation will not happen in this mode,
+// use WithHeartbeat(0) to disable heartbeat if the server doesn't support it.
+func WithSkipHandshake(skip bool) Option {
+	return func(options *options) {
+		options.skipHandshake = skip
+	}
+}
+
+// WithReconnect enable auto reconnect when the connection is lost.
+// the delay of the n-th retry is baseDelay * 2^(n-1), up to maxReconnectDelay.
+// requests are held and packets are queued until reconnected.
+func WithReconnect(maxRetries int, baseDelay time.Duration) Option {
+	return func(options *options) {
+		options.reconnectMax = maxRetries
+		options.reconnectDelay = baseDelay
+	}
+}
+
+// WithMaxInflight limit the number of concurrent requests waiting for response,
+// Request blocks until a slot is released or the request timeout.
+func WithMaxInflight(n int) Option {
+	return func(options *options) {
+		if n < 1 {
+			logger().Warnf("max inflight must be greater than 0. [n = %d]", n)
+			return
+		}
+		options.maxInflight = n
+	}
+}
+
+// WithRequestRetry re-issue the timed out request with a new message id, up to maxAttempts in total,
+// and the timeout error is returned after the last attempt. a random delay in [0, jitter) is waited before each retry.
+// the server may have handled the timed out request, so only use it for idempotent requests.
+func WithRequestRetry(maxAttempts int, jitter time.Duration) Option {
+	return func(options *options) {
+		if maxAttempts < 1 || jitter < 0 {
+			logger().Warnf("request retry is invalid. [maxAttempts = %d, jitter = %v]", maxAttempts, jitter)
+			return
+		}
+
+		options.retryAttempts = maxAttempts
+		options.retryJitter = jitter
+	}
+}
+
+// WithMaxPacketSize disconnect if the length of a received packet, or of the reassembled fragments, exceed maxSize.
+// default is DefaultMaxPacketSize, zero accepts the full range of the packet header(pomeloPacket.MaxPacketSize).
+func WithMaxPacketSize(maxSize int) Option {
+	return func(options *options) {
+		options.maxPacketSize = maxSize
+	}
+}
+
+// WithTLSConfig set the tls config, such as client certificates(mTLS), RootCAs and ServerName
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(options *options) {
+		options.tlsConfig = tlsConfig
+	}
+}
+
+// WithPushBacklog dispatch the push messages in a separate goroutine with a queue of size,
+// so that a slow OnMessageFn doesn't stall the read loop.
+// if the queue is full, the oldest message is dropped when dropOldest is true, otherwise the read loop blocks.
+func WithPushBacklog(size int, dropOldest bool) Option {
+	return func(options *options) {
+		options.pushBacklog = size
+		options.pushDropOldest = dropOldest
+	}
+}
+
+// WithPushChan deliver the push messages which have no handler registered by On to PushChan,
+// the message is dropped if the channel is full. the responses are returned by Request and
+// the errors by the returned error or OnDisconnected, so only the pushes need a channel.
+func WithPushChan(size int) Option {
+	return func(options *options) {
+		options.pushChanSize = size
+	}
+}
+
+// WithMetrics set the metrics sink, see NewHistogram
+func WithMetrics(metrics IMetrics) Option {
+	return func(options *options) {
+		if metrics != nil {
+			options.metrics = metrics
+		}
+	}
+}
+
+// WithCodec set the packet codec for the variant framing protocol, default is the pomelo packet codec
+func WithCodec(codec ICodec) Option {
+	return func(options *options) {
+		if codec != nil {
+			options.codec = codec
+		}
+	}
+}
+
 func WithErrorBreak(isBreak bool) Option {
 	return func(options *options) {
 		options.isErrorBreak = isBreak
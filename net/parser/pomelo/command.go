@@ -1,12 +1,17 @@
 package pomelo
 
 import (
+	"errors"
+	"sync/atomic"
 	"time"
 
+	ccode "github.com/cherry-game/cherry/code"
+	cerr "github.com/cherry-game/cherry/error"
 	cfacade "github.com/cherry-game/cherry/facade"
 	clog "github.com/cherry-game/cherry/logger"
 	pmessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
 	ppacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+	cproto "github.com/cherry-game/cherry/net/proto"
 	jsoniter "github.com/json-iterator/go"
 	"go.uber.org/zap/zapcore"
 )
@@ -20,10 +25,27 @@ type (
 		heartbeatBytes  []byte
 		onPacketFuncMap map[ppacket.Type]PacketFunc
 		onDataRouteFunc DataRouteFunc
+		childIDFunc     ChildIDFunc
+		rateLimits      rateLimits
+		routeMatchers   []routeMatcher
+		fragmentLimit   fragmentLimit
+	}
+
+	// fragmentLimit 每个agent的分片重组限制,maxSize为0时不接收分片包
+	fragmentLimit struct {
+		maxSize   int
+		maxBytes  int
+		maxGroups int
+	}
+
+	routeMatcher struct {
+		pattern string
+		fn      DataRouteFunc
 	}
 
 	PacketFunc    func(agent *Agent, packet *ppacket.Packet)
 	DataRouteFunc func(agent *Agent, route *pmessage.Route, msg *pmessage.Message)
+	ChildIDFunc   func(session *cproto.Session) string // 本地消息路由到哪个子actor,相同childID的消息串行处理
 )
 
 const (
@@ -32,6 +54,11 @@ const (
 	DataSerializer = "serializer"
 )
 
+var (
+	expiredCount         int64 // 已过期丢弃的消息数量
+	unresolvedRouteCount int64 // 无法解析路由的消息数量
+)
+
 var (
 	cmd = Command{
 		writeBacklog:    64,
@@ -40,7 +67,6 @@ var (
 		handshakeBytes:  make([]byte, 0),
 		heartbeatBytes:  make([]byte, 0),
 		onPacketFuncMap: make(map[ppacket.Type]PacketFunc, 4),
-		onDataRouteFunc: DefaultDataRoute,
 	}
 )
 
@@ -54,6 +80,13 @@ func (p *Command) init(app cfacade.IApplication) {
 
 	p.setOnPacketFunc()
 
+	if p.onDataRouteFunc == nil {
+		p.onDataRouteFunc = DefaultDataRoute
+	}
+
+	if p.childIDFunc == nil {
+		p.childIDFunc = DefaultChildID
+	}
 }
 
 func (p *Command) setData(name string, value interface{}) {
@@ -99,6 +132,7 @@ func (p *Command) setOnPacketFunc() {
 		ppacket.HandshakeAck: handshakeACKCommand,
 		ppacket.Heartbeat:    heartbeatCommand,
 		ppacket.Data:         dataCommand,
+		ppacket.Fragment:     fragmentCommand,
 	}
 
 	for name, packetFunc := range packetFuncMaps {
@@ -138,6 +172,49 @@ func heartbeatCommand(agent *Agent, _ *ppacket.Packet) {
 	agent.SendRaw(cmd.heartbeatBytes)
 }
 
+func fragmentCommand(agent *Agent, pkg *ppacket.Packet) {
+	if agent.fragments == nil {
+		clog.Warnf("[sid = %s,uid = %d] Fragment packet is not enabled, close the agent.",
+			agent.SID(),
+			agent.UID(),
+		)
+		agent.Close()
+		return
+	}
+
+	packet, err := agent.fragments.Add(pkg)
+	if err != nil {
+		clog.Warnf("[sid = %s,uid = %d] Fragment packet error. [error = %s]",
+			agent.SID(),
+			agent.UID(),
+			err,
+		)
+
+		// 超过分片重组的限制时断开连接,避免客户端持续占用内存
+		if errors.Is(err, cerr.PacketSizeExceed) {
+			agent.Close()
+		}
+		return
+	}
+
+	// waiting for the other fragments
+	if packet == nil {
+		return
+	}
+
+	process, found := cmd.onPacketFuncMap[packet.Type()]
+	if !found {
+		clog.Warnf("[sid = %s,uid = %d] Fragment packet type not found. [type = %d]",
+			agent.SID(),
+			agent.UID(),
+			packet.Type(),
+		)
+		return
+	}
+
+	process(agent, packet)
+}
+
 func dataCommand(agent *Agent, pkg *ppacket.Packet) {
 	if agent.State() != AgentWorking {
 		if clog.PrintLevel(zapcore.DebugLevel) {
@@ -163,18 +240,72 @@ func dataCommand(agent *Agent, pkg *ppacket.Packet) {
 		return
 	}
 
-	route, err := pmessage.DecodeRoute(msg.Route)
-	if err != nil {
+	if msg.IsExpired(time.Now().UnixMilli()) {
+		atomic.AddInt64(&expiredCount, 1)
+
 		if clog.PrintLevel(zapcore.DebugLevel) {
-			clog.Warnf("[sid = %s,uid = %d] Data Message decode route error. [data = %s, error = %s]",
+			clog.Debugf("[sid = %s,uid = %d] Data message is expired, dropped. [route = %s, expireAt = %d]",
 				agent.SID(),
 				agent.UID(),
-				pkg.Data(),
-				err,
+				msg.Route,
+				msg.ExpireAt,
 			)
 		}
 		return
 	}
 
-	cmd.onDataRouteFunc(agent, route, &msg)
+	if !allowRoute(agent, msg.Route) {
+		routeRateLimited(agent, &msg)
+		return
+	}
+
+	route, err := pmessage.DecodeRoute(msg.Route)
+	if err != nil {
+		RouteNotFound(agent, &msg)
+		return
+	}
+
+	cmd.dataRoute(route)(agent, route, &msg)
+}
+
+// dataRoute 按注册顺序查找匹配路由的DataRouteFunc,未匹配时使用onDataRouteFunc
+func (p *Command) dataRoute(route *pmessage.Route) DataRouteFunc {
+	for _, matcher := range p.routeMatchers {
+		if route.Match(matcher.pattern) {
+			return matcher.fn
+		}
+	}
+
+	return p.onDataRouteFunc
+}
+
+// RouteNotFound 无法解析路由时,request消息响应RouteNotFound错误码(data为route),其他消息打印警告日志
+func RouteNotFound(agent *Agent, msg *pmessage.Message) {
+	atomic.AddInt64(&unresolvedRouteCount, 1)
+
+	clog.Warnf("[sid = %s,uid = %d] Route not found. [route = %s, type = %s, mid = %d]",
+		agent.SID(),
+		agent.UID(),
+		msg.Route,
+		msg.Type.String(),
+		msg.ID,
+	)
+
+	if msg.Type == pmessage.Request {
+		rsp := &cproto.Response{
+			Code: ccode.RouteNotFound,
+			Data: []byte(msg.Route),
+		}
+		agent.ResponseMID(uint32(msg.ID), rsp, true)
+	}
+}
+
+// UnresolvedRouteCount 返回无法解析路由的消息数量
+func UnresolvedRouteCount() int64 {
+	return atomic.LoadInt64(&unresolvedRouteCount)
+}
+
+// ExpiredCount 返回因ttl过期而被丢弃的消息数量
+func ExpiredCount() int64 {
+	return atomic.LoadInt64(&expiredCount)
 }
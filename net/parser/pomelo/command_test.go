@@ -0,0 +1,120 @@
+package pomelo
+
+import (
+	"sync"
+	"testing"
+
+	ccode "github.com/cherry-game/cherry/code"
+	pmessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	ppacket "github.com/cherry-game/cherry/net/parser/pomelo/packet"
+	cproto "github.com/cherry-game/cherry/net/proto"
+)
+
+func TestFragmentCommand(t *testing.T) {
+	cmd.setOnPacketFunc()
+
+	maxPacketSize := ppacket.MaxPacketSize
+	ppacket.MaxPacketSize = 32
+	defer func() {
+		ppacket.MaxPacketSize = maxPacketSize
+		cmd.fragmentLimit = fragmentLimit{}
+	}()
+
+	// a heartbeat packet split into fragments
+	buf, err := ppacket.EncodeFragments(ppacket.Heartbeat, make([]byte, 40))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packets, err := ppacket.Decode(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the fragment packet is rejected by default
+	agent := newTestAgent("1")
+	fragmentCommand(agent, packets[0])
+	if agent.State() != AgentClosed {
+		t.Fatal("agent should be closed")
+	}
+
+	(&actor{}).SetFragmentLimit(64, 0, 0)
+
+	agent = newTestAgent("2")
+	for _, pkg := range packets {
+		fragmentCommand(agent, pkg)
+	}
+
+	if agent.State() == AgentClosed || len(agent.chWrite) != 1 {
+		t.Fatalf("state = %d, write queue = %d", agent.State(), len(agent.chWrite))
+	}
+}
+
+func TestRouteRateLimit(t *testing.T) {
+	const route = "game.room.chat"
+
+	var routed int
+	onDataRouteFunc := cmd.onDataRouteFunc
+	cmd.onDataRouteFunc = func(_ *Agent, _ *pmessage.Route, _ *pmessage.Message) {
+		routed++
+	}
+	defer func() {
+		cmd.onDataRouteFunc = onDataRouteFunc
+		cmd.rateLimits = rateLimits{}
+	}()
+
+	(&actor{}).SetRateLimit(route, 1, true)
+
+	agent := newTestAgent("1")
+	agent.SetState(AgentWorking)
+
+	newPacket := func(typ pmessage.Type, mid uint) *ppacket.Packet {
+		data, err := pmessage.Encode(&pmessage.Message{Type: typ, ID: mid, Route: route})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ppacket.New(ppacket.Data, data)
+	}
+
+	count := RateLimitedCount()
+	dataCommand(agent, newPacket(pmessage.Request, 1))
+	dataCommand(agent, newPacket(pmessage.Request, 2))
+	dataCommand(agent, newPacket(pmessage.Notify, 0))
+
+	if routed != 1 || RateLimitedCount()-count != 2 {
+		t.Fatalf("routed = %d, rate limited = %d", routed, RateLimitedCount()-count)
+	}
+
+	// the rate limited request is responded with the error code, the notify is dropped silently
+	if len(agent.chPending) != 1 {
+		t.Fatalf("pending queue = %d", len(agent.chPending))
+	}
+
+	pending := <-agent.chPending
+	rsp, ok := pending.payload.(*cproto.Response)
+	if !ok || pending.mid != 2 || !pending.err || rsp.Code != ccode.RouteRateLimited || string(rsp.Data) != route {
+		t.Fatalf("pending = %s", pending)
+	}
+}
+
+func TestSetRateLimitConcurrent(t *testing.T) {
+	defer func() {
+		cmd.rateLimits = rateLimits{}
+	}()
+
+	agent := newTestAgent("1")
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			(&actor{}).SetRateLimit("game.room.chat", i+1, false)
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		allowRoute(agent, "game.room.chat")
+	}
+	wg.Wait()
+}
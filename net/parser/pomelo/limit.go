@@ -0,0 +1,141 @@
+package pomelo
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ccode "github.com/cherry-game/cherry/code"
+	clog "github.com/cherry-game/cherry/logger"
+	pmessage "github.com/cherry-game/cherry/net/parser/pomelo/message"
+	cproto "github.com/cherry-game/cherry/net/proto"
+)
+
+type (
+	// rateLimits 所有路由的限流配置,SetRateLimit可以在运行时调用,读写加锁
+	rateLimits struct {
+		sync.RWMutex
+		routes map[string]*rateLimit // key:route
+	}
+
+	// rateLimit 路由限流配置
+	rateLimit struct {
+		perSecond  int          // 每秒允许的消息数量
+		perSession bool         // true:每个session独立计数, false:所有session共享计数
+		bucket     *tokenBucket // 所有session共享的令牌桶
+	}
+
+	// tokenBucket 令牌桶,容量为每秒允许的消息数量
+	tokenBucket struct {
+		sync.Mutex
+		rate   float64   // 每秒生成的令牌数量
+		tokens float64   // 当前令牌数量
+		lastAt time.Time // 最后生成令牌的时间
+	}
+)
+
+const (
+	// rateLimitLogSample 每限流rateLimitLogSample条消息打印一次warn日志
+	rateLimitLogSample = 1000
+)
+
+var (
+	rateLimitedCount int64 // 被限流丢弃的消息数量
+)
+
+func (p *rateLimits) get(route string) (*rateLimit, bool) {
+	p.RLock()
+	defer p.RUnlock()
+
+	limit, found := p.routes[route]
+	return limit, found
+}
+
+func (p *rateLimits) set(route string, limit *rateLimit) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.routes == nil {
+		p.routes = make(map[string]*rateLimit)
+	}
+	p.routes[route] = limit
+}
+
+func newTokenBucket(perSecond int) *tokenBucket {
+	return &tokenBucket{
+		rate:   float64(perSecond),
+		tokens: float64(perSecond),
+		lastAt: time.Now(),
+	}
+}
+
+func (p *tokenBucket) allow(now time.Time) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	p.tokens += now.Sub(p.lastAt).Seconds() * p.rate
+	if p.tokens > p.rate {
+		p.tokens = p.rate
+	}
+	p.lastAt = now
+
+	if p.tokens < 1 {
+		return false
+	}
+
+	p.tokens--
+	return true
+}
+
+// allowRoute 检查路由是否超过限流,未配置限流的路由直接放行
+func allowRoute(agent *Agent, route string) bool {
+	limit, found := cmd.rateLimits.get(route)
+	if !found {
+		return true
+	}
+
+	if !limit.perSession {
+		return limit.bucket.allow(time.Now())
+	}
+
+	// agent的消息在同一个goroutine中处理,令牌桶随agent释放
+	if agent.buckets == nil {
+		agent.buckets = make(map[string]*tokenBucket)
+	}
+
+	bucket, found := agent.buckets[route]
+	if !found {
+		bucket = newTokenBucket(limit.perSecond)
+		agent.buckets[route] = bucket
+	}
+
+	return bucket.allow(time.Now())
+}
+
+// routeRateLimited 限流的request消息响应RouteRateLimited错误码(data为route),日志按rateLimitLogSample采样打印
+func routeRateLimited(agent *Agent, msg *pmessage.Message) {
+	count := atomic.AddInt64(&rateLimitedCount, 1)
+	if count%rateLimitLogSample == 1 {
+		clog.Warnf("[sid = %s,uid = %d] Route rate limit exceeded, dropped. [route = %s, type = %s, mid = %d, count = %d]",
+			agent.SID(),
+			agent.UID(),
+			msg.Route,
+			msg.Type.String(),
+			msg.ID,
+			count,
+		)
+	}
+
+	if msg.Type == pmessage.Request {
+		rsp := &cproto.Response{
+			Code: ccode.RouteRateLimited,
+			Data: []byte(msg.Route),
+		}
+		agent.ResponseMID(uint32(msg.ID), rsp, true)
+	}
+}
+
+// RateLimitedCount 返回被限流丢弃的消息数量
+func RateLimitedCount() int64 {
+	return atomic.LoadInt64(&rateLimitedCount)
+}
@@ -37,10 +37,13 @@ const (
 	TypeMask          = 0x07 // 获取消息类型 00000111
 	GZIPMask          = 0x10 // data compressed gzip mark
 	ErrorMask         = 0x20 // 响应错误标识 00100000
+	TTLMask           = 0x40 // 消息携带过期时间 01000000
+	TTLLength         = 0x08 // 过期时间的长度(unix毫秒,8byte)
 )
 
 var (
-	dataCompression = false // encode message is compression
+	dataCompression          = false // encode message is compression
+	dataCompressionThreshold = 0     // compress the data only if len(data) >= threshold
 )
 
 func IsDataCompression() bool {
@@ -50,3 +53,8 @@ func IsDataCompression() bool {
 func SetDataCompression(compression bool) {
 	dataCompression = compression
 }
+
+// SetDataCompressionThreshold compress the data only if the length of data is greater than or equal to threshold(bytes)
+func SetDataCompressionThreshold(threshold int) {
+	dataCompressionThreshold = threshold
+}
@@ -1,9 +1,11 @@
 package pomeloMessage
 
 import (
+	"io"
 	"strings"
 
 	clog "github.com/cherry-game/cherry/logger"
+	jsoniter "github.com/json-iterator/go"
 )
 
 var (
@@ -20,6 +22,11 @@ func SetDictionary(dict map[string]uint16) {
 	for route, code := range dict {
 		r := strings.TrimSpace(route) //去掉开头结尾的空格
 
+		// the same entry, e.g. set again after client reconnected
+		if c, ok := routes[r]; ok && c == code {
+			continue
+		}
+
 		// duplication check
 		if _, ok := routes[r]; ok {
 			clog.Errorf("duplicated route(route: %s, code: %d)", r, code)
@@ -51,3 +58,19 @@ func GetCode(route string) (uint16, bool) {
 	code, found := routes[route]
 	return code, found
 }
+
+// ExportDictionary write the routes map to w in json format
+func ExportDictionary(w io.Writer) error {
+	return jsoniter.NewEncoder(w).Encode(routes)
+}
+
+// ImportDictionary read the routes map in json format from r, and set it by SetDictionary
+func ImportDictionary(r io.Reader) error {
+	dict := make(map[string]uint16)
+	if err := jsoniter.NewDecoder(r).Decode(&dict); err != nil {
+		return err
+	}
+
+	SetDictionary(dict)
+	return nil
+}
@@ -0,0 +1,45 @@
+package pomeloMessage
+
+import (
+	"bytes"
+	"testing"
+)
+
+// restoreDictionary restore the global dictionary after the test,
+// so the routes set by the test do not leak into other tests
+func restoreDictionary(t *testing.T) {
+	oldRoutes, oldCodes := routes, codes
+
+	routes = make(map[string]uint16, len(oldRoutes))
+	codes = make(map[uint16]string, len(oldCodes))
+	for route, code := range oldRoutes {
+		routes[route] = code
+		codes[code] = route
+	}
+
+	t.Cleanup(func() {
+		routes, codes = oldRoutes, oldCodes
+	})
+}
+
+func TestImportDictionary(t *testing.T) {
+	restoreDictionary(t)
+
+	buf := bytes.NewBufferString(`{"game.room.enter": 2001, "game.room.leave": 2002}`)
+	if err := ImportDictionary(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if route, found := GetRoute(2002); !found || route != "game.room.leave" {
+		t.Fatalf("route = %s, found = %t", route, found)
+	}
+
+	buf.Reset()
+	if err := ExportDictionary(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(buf.Bytes(), []byte(`"game.room.enter":2001`)) {
+		t.Fatalf("export = %s", buf.String())
+	}
+}
@@ -3,6 +3,7 @@ package pomeloMessage
 import (
 	"encoding/binary"
 	"fmt"
+	"time"
 
 	cerr "github.com/cherry-game/cherry/error"
 	ccompress "github.com/cherry-game/cherry/extend/compress"
@@ -51,6 +52,7 @@ type Message struct {
 	Data            []byte // payload  消息体的原始数据
 	routeCompressed bool   // is route Compressed 是否启用路由压缩
 	Error           bool   // response error
+	ExpireAt        int64  // expire time(unix milli), zero is never expire 过期时间
 }
 
 func New() Message {
@@ -59,14 +61,39 @@ func New() Message {
 
 func (t *Message) String() string {
 	return fmt.Sprintf(
-		"Type: %s, ID: %d, Route: %s, RouteCompressed: %t, Data: %v, BodyLength: %d, Error:%v",
+		"Type: %s, ID: %d, Route: %s, RouteCompressed: %t, Data: %v, BodyLength: %d, Error:%v, ExpireAt: %d",
 		t.Type.String(),
 		t.ID,
 		t.Route,
 		t.routeCompressed,
 		t.Data,
 		len(t.Data),
-		t.Error)
+		t.Error,
+		t.ExpireAt)
+}
+
+// SetTTL set the message expire time as now + ttl. zero or negative ttl is never expire.
+//
+// The expire time is an absolute unix millisecond timestamp build by the sender clock,
+// so the receiver compare it with its own clock. If the clocks of client and server are skewed,
+// the ttl is stretched or shortened by the skew, set a ttl greater than the expected skew.
+func (t *Message) SetTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		t.ExpireAt = 0
+		return
+	}
+
+	t.ExpireAt = time.Now().Add(ttl).UnixMilli()
+}
+
+// RouteCompressed returns true if the route is decoded from the dictionary code
+func (t *Message) RouteCompressed() bool {
+	return t.routeCompressed
+}
+
+// IsExpired returns true if the message carries an expire time and it has elapsed.
+func (t *Message) IsExpired(nowMilli int64) bool {
+	return t.ExpireAt > 0 && nowMilli > t.ExpireAt
 }
 
 // Encode marshals message to binary format. Different message types is corresponding to
@@ -84,6 +111,16 @@ func (t *Message) String() string {
 // See ref: https://github.com/lonnng/nano/blob/master/docs/communication_protocol.md
 // See ref: https://github.com/NetEase/pomelo/wiki/%E5%8D%8F%E8%AE%AE%E6%A0%BC%E5%BC%8F
 func Encode(m *Message) ([]byte, error) {
+	return encode(m, IsDataCompression() && len(m.Data) >= dataCompressionThreshold)
+}
+
+// EncodeCompressed marshals the message and deflates the data regardless of the global compression setting,
+// the data is sent uncompressed if the deflated data is not smaller.
+func EncodeCompressed(m *Message) ([]byte, error) {
+	return encode(m, true)
+}
+
+func encode(m *Message, compress bool) ([]byte, error) {
 	if InvalidType(m.Type) {
 		return nil, cerr.MessageWrongType
 	}
@@ -101,6 +138,10 @@ func Encode(m *Message) ([]byte, error) {
 		flag |= ErrorMask
 	}
 
+	if m.ExpireAt > 0 {
+		flag |= TTLMask
+	}
+
 	buf = append(buf, flag)
 
 	if m.Type == Request || m.Type == Response {
@@ -123,12 +164,21 @@ func Encode(m *Message) ([]byte, error) {
 			buf = append(buf, byte((code>>8)&0xFF))
 			buf = append(buf, byte(code&0xFF))
 		} else {
+			if len(m.Route) > 255 {
+				return nil, cerr.MessageRouteTooLong
+			}
 			buf = append(buf, byte(len(m.Route)))
 			buf = append(buf, []byte(m.Route)...)
 		}
 	}
 
-	if IsDataCompression() {
+	if m.ExpireAt > 0 {
+		expireAt := make([]byte, TTLLength)
+		binary.BigEndian.PutUint64(expireAt, uint64(m.ExpireAt))
+		buf = append(buf, expireAt...)
+	}
+
+	if compress {
 		d, err := ccompress.DeflateData(m.Data)
 		if err != nil {
 			return nil, err
@@ -202,6 +252,15 @@ func Decode(data []byte) (Message, error) {
 		}
 	}
 
+	if flag&TTLMask == TTLMask {
+		if offset+TTLLength > len(data) {
+			return nilMessage, cerr.MessageInvalid
+		}
+
+		m.ExpireAt = int64(binary.BigEndian.Uint64(data[offset:(offset + TTLLength)]))
+		offset += TTLLength
+	}
+
 	if offset > len(data) {
 		return nilMessage, cerr.MessageInvalid
 	}
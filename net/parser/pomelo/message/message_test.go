@@ -1,7 +1,13 @@
 package pomeloMessage
 
 import (
+	"bytes"
 	"testing"
+	"time"
+
+	cfacade "github.com/cherry-game/cherry/facade"
+	cproto "github.com/cherry-game/cherry/net/proto"
+	cserializer "github.com/cherry-game/cherry/net/serializer"
 )
 
 func TestResponseMessageEncode1(t *testing.T) {
@@ -19,3 +25,154 @@ func TestResponseMessageEncode1(t *testing.T) {
 	decode, err := Decode(encode)
 	t.Log(decode, err)
 }
+
+func TestMessageTTL(t *testing.T) {
+	m := &Message{
+		Type:  Notify,
+		Route: "game.player.move",
+		Data:  []byte(`{"x":1}`),
+	}
+	m.SetTTL(time.Second)
+
+	encode, err := Encode(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decode, err := Decode(encode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decode.ExpireAt != m.ExpireAt || string(decode.Data) != string(m.Data) {
+		t.Fatalf("decode ttl message fail. %s", decode.String())
+	}
+
+	if decode.IsExpired(time.Now().UnixMilli()) {
+		t.Fatal("message should not be expired")
+	}
+
+	if !decode.IsExpired(time.Now().Add(2 * time.Second).UnixMilli()) {
+		t.Fatal("message should be expired")
+	}
+}
+
+func TestRouteCompress(t *testing.T) {
+	restoreDictionary(t)
+
+	SetDictionary(map[string]uint16{
+		"game.player.login": 1001,
+	})
+
+	tests := []struct {
+		typ        Type
+		route      string
+		compressed bool
+	}{
+		{Request, "game.player.login", true},
+		{Notify, "game.player.login", true},
+		{Request, "game.player.logout", false},
+		{Notify, "game.player.logout", false},
+	}
+
+	for _, tt := range tests {
+		m := &Message{
+			Type:  tt.typ,
+			ID:    1,
+			Route: tt.route,
+			Data:  []byte(`hello world`),
+		}
+
+		encode, err := Encode(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decode, err := Decode(encode)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if decode.Route != tt.route || decode.RouteCompressed() != tt.compressed {
+			t.Fatalf("route = %s, compressed = %t, expected = %+v", decode.Route, decode.RouteCompressed(), tt)
+		}
+	}
+}
+
+func TestMessageSerializer(t *testing.T) {
+	serializers := []cfacade.ISerializer{
+		cserializer.NewProtobuf(),
+		cserializer.NewJSON(),
+	}
+
+	for _, serializer := range serializers {
+		data, err := serializer.Marshal(&cproto.Response{Code: 33, Data: []byte("game.player.login")})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		encode, err := Encode(&Message{
+			Type: Response,
+			ID:   10,
+			Data: data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decode, err := Decode(encode)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp := &cproto.Response{}
+		if err = serializer.Unmarshal(decode.Data, rsp); err != nil {
+			t.Fatal(err)
+		}
+
+		if decode.ID != 10 || rsp.Code != 33 || string(rsp.Data) != "game.player.login" {
+			t.Fatalf("[%s] id = %d, rsp = %+v", serializer.Name(), decode.ID, rsp)
+		}
+	}
+}
+
+func TestDataCompressionThreshold(t *testing.T) {
+	SetDataCompression(true)
+	SetDataCompressionThreshold(128)
+	defer func() {
+		SetDataCompression(false)
+		SetDataCompressionThreshold(0)
+	}()
+
+	tests := []struct {
+		data       []byte
+		compressed bool
+	}{
+		{[]byte("hello world"), false},
+		{bytes.Repeat([]byte("hello world"), 64), true},
+	}
+
+	for _, tt := range tests {
+		encode, err := Encode(&Message{
+			Type:  Push,
+			Route: "game.room.sync",
+			Data:  append([]byte(nil), tt.data...),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if compressed := encode[0]&GZIPMask == GZIPMask; compressed != tt.compressed {
+			t.Fatalf("len = %d, compressed = %t", len(tt.data), compressed)
+		}
+
+		decode, err := Decode(encode)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(decode.Data, tt.data) {
+			t.Fatalf("decode data = %s", decode.Data)
+		}
+	}
+}
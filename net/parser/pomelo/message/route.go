@@ -1,13 +1,25 @@
 package pomeloMessage
 
 import (
+	"path"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	cconst "github.com/cherry-game/cherry/const"
 	cerr "github.com/cherry-game/cherry/error"
 )
 
-// Route struct
+const (
+	maxRouteCache = 4096 // 最多缓存的路由数量,防止客户端发送大量随机路由导致内存增长
+)
+
+var (
+	routeCache      sync.Map // key:route string, value:*Route
+	routeCacheCount int32
+)
+
+// Route struct,解析后的路由会被缓存共享,只读
 type Route struct {
 	nodeType   string // node server type name
 	handleName string // handle name
@@ -36,8 +48,66 @@ func (r *Route) String() string {
 	return r.nodeType + cconst.DOT + r.handleName + cconst.DOT + r.method
 }
 
+// RouteGroup 同一节点类型的路由组,自动添加nodeType前缀,避免多模块的节点重复书写路由前缀
+type RouteGroup struct {
+	nodeType string
+}
+
+// NewRouteGroup create a route group of the node type, e.g. NewRouteGroup("game").Route("player", "login")
+func NewRouteGroup(nodeType string) *RouteGroup {
+	return &RouteGroup{
+		nodeType: strings.TrimSpace(nodeType),
+	}
+}
+
+func (g *RouteGroup) NodeType() string {
+	return g.nodeType
+}
+
+// Route create the route with the group prefix
+func (g *RouteGroup) Route(handleName, method string) *Route {
+	return NewRoute(g.nodeType, strings.TrimSpace(handleName), strings.TrimSpace(method))
+}
+
+// SetDictionary set the routes map of the group, the key is "handleName.method" without the node type,
+// e.g. {"player.login": 1} is set as {"game.player.login": 1}
+func (g *RouteGroup) SetDictionary(dict map[string]uint16) error {
+	fullDict := make(map[string]uint16, len(dict))
+
+	for route, code := range dict {
+		r := strings.Split(strings.TrimSpace(route), cconst.DOT)
+		if len(r) != 2 {
+			return cerr.Errorf("%w [nodeType = %s, route = %s]", cerr.RouteInvalid, g.nodeType, route)
+		}
+
+		fullDict[g.Route(r[0], r[1]).String()] = code
+	}
+
+	SetDictionary(fullDict)
+	return nil
+}
+
 // DecodeRoute decodes the route
 func DecodeRoute(route string) (*Route, error) {
+	if v, found := routeCache.Load(route); found {
+		return v.(*Route), nil
+	}
+
+	r, err := decodeRoute(route)
+	if err != nil {
+		return nil, err
+	}
+
+	if atomic.LoadInt32(&routeCacheCount) < maxRouteCache {
+		if _, loaded := routeCache.LoadOrStore(route, r); !loaded {
+			atomic.AddInt32(&routeCacheCount, 1)
+		}
+	}
+
+	return r, nil
+}
+
+func decodeRoute(route string) (*Route, error) {
 	if route == "" {
 		return nil, cerr.RouteFieldCantEmpty
 	}
@@ -55,3 +125,31 @@ func DecodeRoute(route string) (*Route, error) {
 
 	return NewRoute(r[0], r[1], r[2]), nil
 }
+
+// Match 判断路由是否匹配pattern,pattern以"."分隔
+// "*"匹配单个字段,字段内的"*"为通配符(如"test*"),"**"匹配剩余的所有字段
+func (r *Route) Match(pattern string) bool {
+	return MatchRoute(pattern, r.String())
+}
+
+// MatchRoute 判断route是否匹配pattern
+func MatchRoute(pattern, route string) bool {
+	ps := strings.Split(pattern, cconst.DOT)
+	rs := strings.Split(route, cconst.DOT)
+
+	for i, p := range ps {
+		if p == "**" {
+			return i == len(ps)-1 && i < len(rs)
+		}
+
+		if i >= len(rs) {
+			return false
+		}
+
+		if matched, err := path.Match(p, rs[i]); err != nil || !matched {
+			return false
+		}
+	}
+
+	return len(ps) == len(rs)
+}
@@ -0,0 +1,103 @@
+package pomeloMessage
+
+import (
+	"errors"
+	"testing"
+
+	cerr "github.com/cherry-game/cherry/error"
+)
+
+func TestRouteMatch(t *testing.T) {
+	route := NewRoute("game", "testHandler", "test11111")
+
+	tests := []struct {
+		pattern string
+		want    bool
+	}{
+		{"game.testHandler.test11111", true},
+		{"game.testHandler.test22222", false},
+		{"game.*.test11111", true},
+		{"game.*.test*", true},
+		{"game.*.login*", false},
+		{"*.*.*", true},
+		{"*.*", false},
+		{"game.**", true},
+		{"game.testHandler.**", true},
+		{"game.testHandler.test11111.**", false},
+		{"gate.**", false},
+		{"**", true},
+	}
+
+	for _, tt := range tests {
+		if got := route.Match(tt.pattern); got != tt.want {
+			t.Errorf("Match(%s) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestRouteGroup(t *testing.T) {
+	restoreDictionary(t)
+
+	group := NewRouteGroup(" game ")
+
+	route := group.Route("player", "login")
+	if route.String() != "game.player.login" {
+		t.Fatalf("route = %s", route)
+	}
+
+	// the group route resolves to the same route as the full route
+	decoded, err := DecodeRoute(route.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.NodeType() != "game" || decoded.HandleName() != "player" || decoded.Method() != "login" {
+		t.Fatalf("decoded = %s", decoded)
+	}
+
+	if err = group.SetDictionary(map[string]uint16{" player.logout ": 3001, "mail.read": 3002}); err != nil {
+		t.Fatal(err)
+	}
+
+	if code, found := GetCode("game.player.logout"); !found || code != 3001 {
+		t.Fatalf("code = %d, found = %t", code, found)
+	}
+
+	if r, found := GetRoute(3002); !found || r != "game.mail.read" {
+		t.Fatalf("route = %s, found = %t", r, found)
+	}
+
+	if err = group.SetDictionary(map[string]uint16{"game.room.leave": 3003}); !errors.Is(err, cerr.RouteInvalid) {
+		t.Fatalf("err = %v, want %v", err, cerr.RouteInvalid)
+	}
+}
+
+func TestDecodeRouteCache(t *testing.T) {
+	r1, err := DecodeRoute("game.testHandler.test11111")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r2, _ := DecodeRoute("game.testHandler.test11111")
+	if r1 != r2 {
+		t.Fatal("route should be cached")
+	}
+
+	if _, err = DecodeRoute("game.testHandler"); err == nil {
+		t.Fatal("invalid route should return error")
+	}
+}
+
+func BenchmarkDecodeRoute(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = DecodeRoute("game.testHandler.test11111")
+	}
+}
+
+func BenchmarkDecodeRouteNoCache(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = decodeRoute("game.testHandler.test11111")
+	}
+}
@@ -1,8 +1,6 @@
 package pomeloPacket
 
 import (
-	"bytes"
-
 	cerr "github.com/cherry-game/cherry/error"
 )
 
@@ -17,6 +15,7 @@ const (
 	Heartbeat    Type = 0x03 // Heartbeat represents a heartbeat
 	Data         Type = 0x04 // settings represents a common data packet
 	Kick         Type = 0x05 // Kick represents a kick off packet
+	Fragment     Type = 0x06 // Fragment represents a part of the packet which exceed MaxPacketSize
 )
 
 var (
@@ -30,6 +29,7 @@ var (
 		Heartbeat:    "Heartbeat",
 		Data:         "Data",
 		Kick:         "Kick",
+		Fragment:     "Fragment",
 	}
 )
 
@@ -38,7 +38,7 @@ func TypeName(t Type) string {
 }
 
 func InvalidType(t Type) bool {
-	return t < Handshake || t > Kick
+	return t < Handshake || t > Fragment
 }
 
 // ParseHeader parses a packet header and returns its dataLen and packetType or an error
@@ -78,22 +78,3 @@ func IntToBytes(n int) []byte {
 	buf[2] = byte(n & 0xFF)
 	return buf
 }
-
-func forward(buf *bytes.Buffer) (int, Type, error) {
-	header := buf.Next(HeadLength)
-
-	typ := header[0]
-	if InvalidType(typ) {
-		return 0, None, cerr.PacketSizeExceed
-	}
-
-	// get 2,3,4 byte
-	size := BytesToInt(header[1:])
-
-	// packet length limitation
-	if size > MaxPacketSize {
-		return 0, None, cerr.PacketSizeExceed
-	}
-
-	return size, typ, nil
-}
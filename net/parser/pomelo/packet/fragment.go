@@ -0,0 +1,228 @@
+package pomeloPacket
+
+import (
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cerr "github.com/cherry-game/cherry/error"
+	clog "github.com/cherry-game/cherry/logger"
+)
+
+// 超过MaxPacketSize的数据被拆分为多个Fragment包发送,接收方重组后再按原始包类型处理
+// fragment data = fragment id(4byte) + index(2byte) + total(2byte) + packet type(1byte) + chunk
+const (
+	FragmentHeadLength = 9
+	MaxFragmentCount   = 1<<16 - 1
+	FragmentTimeout    = 30 * time.Second // 分片组从收到第一个分片开始的最长等待时间
+)
+
+var (
+	fragmentID uint32 // 自增的分片组id
+
+	MaxFragmentSize   = 1 << 25 // 32mb, 重组后的包最大长度
+	MaxFragmentBytes  = 1 << 25 // 32mb, 所有未收齐的分片组缓存的最大字节数
+	MaxFragmentGroups = 4       // 同时未收齐的分片组最大数量
+)
+
+type (
+	// Fragments 分片重组,超时未收齐的分片组会被丢弃
+	Fragments struct {
+		sync.Mutex
+		timeout   time.Duration
+		groups    map[uint32]*fragmentGroup
+		size      int // 所有分片组已缓存的字节数
+		maxSize   int // 重组后的包最大长度
+		maxBytes  int // 所有分片组缓存的最大字节数
+		maxGroups int // 分片组最大数量
+	}
+
+	fragmentGroup struct {
+		typ   Type
+		count int
+		size  int
+		parts [][]byte
+		timer *time.Timer
+	}
+)
+
+// EncodeFragments encode data to a packet, split into fragment packets if len(data) >= MaxPacketSize.
+// the 3 bytes length field can't hold MaxPacketSize(1<<24), so the body of each packet is less than it.
+// returns the bytes of all packets.
+func EncodeFragments(typ Type, data []byte) ([]byte, error) {
+	if len(data) < MaxPacketSize {
+		return Encode(typ, data)
+	}
+
+	chunkSize := MaxPacketSize - FragmentHeadLength - 1
+	total := (len(data) + chunkSize - 1) / chunkSize
+	if total > MaxFragmentCount {
+		return nil, cerr.PacketSizeExceed
+	}
+
+	id := atomic.AddUint32(&fragmentID, 1)
+	buf := make([]byte, 0, len(data)+total*(HeadLength+FragmentHeadLength))
+
+	for i := 0; i < total; i++ {
+		end := (i + 1) * chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := data[i*chunkSize : end]
+
+		body := make([]byte, FragmentHeadLength, FragmentHeadLength+len(chunk))
+		binary.BigEndian.PutUint32(body[0:4], id)
+		binary.BigEndian.PutUint16(body[4:6], uint16(i))
+		binary.BigEndian.PutUint16(body[6:8], uint16(total))
+		body[8] = typ
+		body = append(body, chunk...)
+
+		pkg, err := Encode(Fragment, body)
+		if err != nil {
+			return nil, err
+		}
+
+		buf = append(buf, pkg...)
+	}
+
+	return buf, nil
+}
+
+func NewFragments(timeout time.Duration) *Fragments {
+	return &Fragments{
+		timeout:   timeout,
+		groups:    make(map[uint32]*fragmentGroup),
+		maxSize:   MaxFragmentSize,
+		maxBytes:  MaxFragmentBytes,
+		maxGroups: MaxFragmentGroups,
+	}
+}
+
+// SetLimit set the max size of the reassembled packet, the max bytes and the max count of the incomplete groups.
+// zero keeps the current value.
+func (p *Fragments) SetLimit(maxSize, maxBytes, maxGroups int) {
+	p.Lock()
+	defer p.Unlock()
+
+	if maxSize > 0 {
+		p.maxSize = maxSize
+	}
+
+	if maxBytes > 0 {
+		p.maxBytes = maxBytes
+	}
+
+	if maxGroups > 0 {
+		p.maxGroups = maxGroups
+	}
+}
+
+// Add a fragment packet, returns the reassembled packet when all fragments are received.
+// the group is discarded and cerr.PacketSizeExceed is returned if any limit is exceeded.
+func (p *Fragments) Add(pkg *Packet) (*Packet, error) {
+	data := pkg.Data()
+	if len(data) < FragmentHeadLength {
+		return nil, cerr.PacketInvalidHeader
+	}
+
+	id := binary.BigEndian.Uint32(data[0:4])
+	index := int(binary.BigEndian.Uint16(data[4:6]))
+	total := int(binary.BigEndian.Uint16(data[6:8]))
+	typ := data[8]
+	chunk := data[FragmentHeadLength:]
+
+	if total < 1 || index >= total || InvalidType(typ) || typ == Fragment {
+		return nil, cerr.PacketInvalidHeader
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	group, found := p.groups[id]
+	if !found {
+		if len(p.groups) >= p.maxGroups {
+			return nil, cerr.PacketSizeExceed
+		}
+
+		group = &fragmentGroup{
+			typ:   typ,
+			parts: make([][]byte, total),
+		}
+		p.groups[id] = group
+
+		if p.timeout > 0 {
+			group.timer = time.AfterFunc(p.timeout, func() {
+				p.expire(id, group)
+			})
+		}
+	}
+
+	if len(group.parts) != total || group.typ != typ {
+		p.remove(id, group)
+		return nil, cerr.PacketInvalidHeader
+	}
+
+	if group.parts[index] != nil {
+		return nil, nil
+	}
+
+	if group.size+len(chunk) > p.maxSize || p.size+len(chunk) > p.maxBytes {
+		p.remove(id, group)
+		return nil, cerr.PacketSizeExceed
+	}
+
+	group.parts[index] = chunk
+	group.count++
+	group.size += len(chunk)
+	p.size += len(chunk)
+
+	if group.count < total {
+		return nil, nil
+	}
+
+	p.remove(id, group)
+
+	body := make([]byte, 0, group.size)
+	for _, part := range group.parts {
+		body = append(body, part...)
+	}
+
+	return &Packet{
+		typ:  group.typ,
+		len:  len(body),
+		data: body,
+	}, nil
+}
+
+// remove the group and release its buffered bytes
+func (p *Fragments) remove(id uint32, group *fragmentGroup) {
+	if p.groups[id] != group {
+		return
+	}
+
+	if group.timer != nil {
+		group.timer.Stop()
+	}
+
+	delete(p.groups, id)
+	p.size -= group.size
+}
+
+// expire discard the fragment group which is not completed before timeout
+func (p *Fragments) expire(id uint32, group *fragmentGroup) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.groups[id] != group {
+		return
+	}
+
+	p.remove(id, group)
+
+	clog.Errorf("Fragment timeout, discard the partial packet. [id = %d, received = %d, total = %d]",
+		id,
+		group.count,
+		len(group.parts),
+	)
+}
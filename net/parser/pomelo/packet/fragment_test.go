@@ -0,0 +1,193 @@
+package pomeloPacket
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	cerr "github.com/cherry-game/cherry/error"
+)
+
+func TestFragments(t *testing.T) {
+	maxPacketSize := MaxPacketSize
+	MaxPacketSize = 32
+	defer func() {
+		MaxPacketSize = maxPacketSize
+	}()
+
+	data := bytes.Repeat([]byte("0123456789"), 10)
+
+	buf, err := EncodeFragments(Data, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fragments := NewFragments(time.Second)
+
+	var result *Packet
+	for len(buf) > 0 {
+		size := BytesToInt(buf[1:HeadLength])
+		packets, err := Decode(buf[:HeadLength+size])
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf = buf[HeadLength+size:]
+
+		if packets[0].Type() != Fragment {
+			t.Fatalf("packet type = %s", TypeName(packets[0].Type()))
+		}
+
+		result, err = fragments.Add(packets[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if result == nil || result.Type() != Data || !bytes.Equal(result.Data(), data) {
+		t.Fatalf("reassemble fail. %v", result)
+	}
+}
+
+func TestFragmentsLimit(t *testing.T) {
+	maxPacketSize := MaxPacketSize
+	MaxPacketSize = 32
+	defer func() {
+		MaxPacketSize = maxPacketSize
+	}()
+
+	encode := func(size int) []*Packet {
+		buf, err := EncodeFragments(Data, bytes.Repeat([]byte("0"), size))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var packets []*Packet
+		for len(buf) > 0 {
+			size := BytesToInt(buf[1:HeadLength])
+			decoded, err := Decode(buf[:HeadLength+size])
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf = buf[HeadLength+size:]
+			packets = append(packets, decoded...)
+		}
+		return packets
+	}
+
+	// the reassembled size exceed maxSize
+	fragments := NewFragments(time.Second)
+	fragments.SetLimit(60, 0, 0)
+
+	var err error
+	for _, pkg := range encode(100) {
+		if _, err = fragments.Add(pkg); err != nil {
+			break
+		}
+	}
+
+	if !errors.Is(err, cerr.PacketSizeExceed) || len(fragments.groups) != 0 || fragments.size != 0 {
+		t.Fatalf("err = %v, groups = %d, size = %d", err, len(fragments.groups), fragments.size)
+	}
+
+	// the incomplete groups exceed maxGroups or maxBytes
+	fragments = NewFragments(time.Second)
+	fragments.SetLimit(0, 50, 2)
+
+	for i := 0; i < 2; i++ {
+		if _, err = fragments.Add(encode(40)[0]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err = fragments.Add(encode(40)[0]); !errors.Is(err, cerr.PacketSizeExceed) {
+		t.Fatalf("groups exceed, err = %v", err)
+	}
+
+	fragments.SetLimit(0, 0, 3)
+	if _, err = fragments.Add(encode(40)[0]); !errors.Is(err, cerr.PacketSizeExceed) || fragments.size != 44 {
+		t.Fatalf("bytes exceed, err = %v, size = %d", err, fragments.size)
+	}
+}
+
+func TestFragmentsExpire(t *testing.T) {
+	maxPacketSize := MaxPacketSize
+	MaxPacketSize = 32
+	defer func() {
+		MaxPacketSize = maxPacketSize
+	}()
+
+	buf, err := EncodeFragments(Data, bytes.Repeat([]byte("0"), 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packets, err := Decode(buf[:HeadLength+BytesToInt(buf[1:HeadLength])])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fragments := NewFragments(10 * time.Millisecond)
+	if _, err = fragments.Add(packets[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	// the incomplete group is discarded by the timer without other fragments arriving
+	deadline := time.Now().Add(time.Second)
+	for {
+		fragments.Lock()
+		groups, size := len(fragments.groups), fragments.size
+		fragments.Unlock()
+
+		if groups == 0 && size == 0 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("groups = %d, size = %d", groups, size)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestEncodeFragmentsBoundary(t *testing.T) {
+	// the 3 bytes length field holds MaxPacketSize-1 at most
+	for _, size := range []int{MaxPacketSize - 1, MaxPacketSize, 2*MaxPacketSize + 1} {
+		data := bytes.Repeat([]byte("0"), size)
+
+		buf, err := EncodeFragments(Data, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		packets, n, err := DecodeStream(buf)
+		if err != nil || n != len(buf) {
+			t.Fatalf("[size = %d] n = %d, err = %v", size, n, err)
+		}
+
+		if size < MaxPacketSize {
+			if len(packets) != 1 || packets[0].Type() != Data || len(packets[0].Data()) != size {
+				t.Fatalf("[size = %d] packets = %d", size, len(packets))
+			}
+			continue
+		}
+
+		fragments := NewFragments(time.Second)
+		fragments.SetLimit(3*MaxPacketSize, 3*MaxPacketSize, 0)
+
+		var result *Packet
+		for _, pkg := range packets {
+			if pkg.Type() != Fragment || pkg.Len() >= MaxPacketSize {
+				t.Fatalf("[size = %d] type = %s, len = %d", size, TypeName(pkg.Type()), pkg.Len())
+			}
+
+			if result, err = fragments.Add(pkg); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if result == nil || result.Type() != Data || !bytes.Equal(result.Data(), data) {
+			t.Fatalf("[size = %d] reassemble fail.", size)
+		}
+	}
+}
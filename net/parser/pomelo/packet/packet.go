@@ -1,10 +1,10 @@
 package pomeloPacket
 
 import (
-	"bytes"
 	"fmt"
 	"io"
 	"net"
+	"sync"
 
 	cerr "github.com/cherry-game/cherry/error"
 )
@@ -17,6 +17,52 @@ type (
 	}
 )
 
+var (
+	enablePool = false // 是否启用Packet对象池
+	packetPool = &sync.Pool{
+		New: func() interface{} {
+			return new(Packet)
+		},
+	}
+)
+
+// SetPool enable the packet pool, the decoded packets must be released by Release after consumed.
+// do not hold the packet(or its data) after Release.
+func SetPool(enable bool) {
+	enablePool = enable
+}
+
+func newPacket(typ Type, size int, data []byte) *Packet {
+	var pkg *Packet
+	if enablePool {
+		pkg = packetPool.Get().(*Packet)
+	} else {
+		pkg = new(Packet)
+	}
+
+	pkg.typ = typ
+	pkg.len = size
+	pkg.data = data
+	return pkg
+}
+
+// New returns a packet of the type, used by the custom codec to build the decoded packet
+func New(typ Type, data []byte) *Packet {
+	return newPacket(typ, len(data), data)
+}
+
+// Release put the packet back to the pool, it's a no-op if the pool is disabled
+func Release(p *Packet) {
+	if !enablePool || p == nil {
+		return
+	}
+
+	p.typ = None
+	p.len = 0
+	p.data = nil
+	packetPool.Put(p)
+}
+
 func (p *Packet) Type() Type {
 	return p.typ
 }
@@ -38,44 +84,39 @@ func (p *Packet) String() string {
 	return fmt.Sprintf("packet type: %s, length: %d, data: %s", TypeName(p.typ), p.len, string(p.data))
 }
 
+// Decode decodes the complete packets in data, an incomplete packet at the end is ignored,
+// use DecodeStream to keep it for the next read.
 func Decode(data []byte) ([]*Packet, error) {
-	buf := bytes.NewBuffer(data)
+	packets, _, err := DecodeStream(data)
+	return packets, err
+}
 
+// DecodeStream decodes the complete packets in the buffered stream data and returns the consumed length.
+// an incomplete packet at the end is not consumed, the caller keeps data[n:] and appends the next read.
+// the error is returned if the framing is corrupt(wrong type or size exceed),
+// the stream can't be resynced and the connection should be closed.
+func DecodeStream(data []byte) ([]*Packet, int, error) {
 	var (
 		packets []*Packet
-		err     error
+		n       int
 	)
 
-	// check length
-	if buf.Len() < HeadLength {
-		return nil, err
-	}
-
-	size, typ, err := forward(buf)
-	if err != nil {
-		return nil, err
-	}
-
-	for size <= buf.Len() {
-		pkg := &Packet{
-			typ:  typ,
-			len:  size,
-			data: buf.Next(size),
+	for len(data)-n >= HeadLength {
+		size, err := ParseHeader(data[n : n+HeadLength])
+		if err != nil {
+			return packets, n, err
 		}
 
-		packets = append(packets, pkg)
-
-		if buf.Len() < HeadLength {
+		end := n + HeadLength + size
+		if end > len(data) {
 			break
 		}
 
-		size, typ, err = forward(buf)
-		if err != nil {
-			return nil, err
-		}
+		packets = append(packets, newPacket(data[n], size, data[n+HeadLength:end]))
+		n = end
 	}
 
-	return packets, nil
+	return packets, n, nil
 }
 
 // Encode create a packet.Packet from  the raw bytes slice and then encode to network bytes slice
@@ -85,7 +126,7 @@ func Decode(data []byte) ([]*Packet, error) {
 // --------|------------------------|--------
 // 1 byte packet type, 3 bytes packet data length(big end), and data segment
 func Encode(typ byte, data []byte) ([]byte, error) {
-	if typ < Handshake || typ > Kick {
+	if InvalidType(typ) {
 		return nil, cerr.PacketWrongType
 	}
 
@@ -114,35 +155,47 @@ func Encode(typ byte, data []byte) ([]byte, error) {
 }
 
 func Read(conn net.Conn) ([]*Packet, bool, error) {
-	header, err := io.ReadAll(io.LimitReader(conn, int64(HeadLength)))
-	if err != nil {
-		return nil, true, err
-	}
+	return ReadWithLimit(conn, MaxPacketSize)
+}
 
-	// if the header has no data, we can consider it as a closed connection
-	if len(header) == 0 {
-		return nil, true, cerr.PacketConnectClosed
-	}
+// ReadWithLimit read a packet from conn, returns cerr.PacketSizeExceed if the length of header exceed maxSize.
+// the returned bool is true if the connection should be closed.
+// the header and data are read into their own buffers and the packet data refers the data buffer directly,
+// a packet spanning multiple reads is accumulated by io.ReadFull.
+func ReadWithLimit(conn net.Conn, maxSize int) ([]*Packet, bool, error) {
+	header := make([]byte, HeadLength)
 
-	msgSize, err := ParseHeader(header)
+	n, err := io.ReadFull(conn, header)
 	if err != nil {
+		// if the header has no data, we can consider it as a closed connection
+		if n == 0 && err == io.EOF {
+			return nil, true, cerr.PacketConnectClosed
+		}
+
+		if err == io.ErrUnexpectedEOF {
+			return nil, true, cerr.PacketInvalidHeader
+		}
+
 		return nil, true, err
 	}
 
-	msgData, err := io.ReadAll(io.LimitReader(conn, int64(msgSize)))
+	msgSize, err := ParseHeader(header)
 	if err != nil {
 		return nil, true, err
 	}
 
-	if len(msgData) < msgSize {
-		return nil, true, cerr.PacketMsgSmallerThanExpected
+	if maxSize > 0 && msgSize > maxSize {
+		return nil, true, cerr.PacketSizeExceed
 	}
 
-	header = append(header, msgData...)
-	packets, err := Decode(header)
-	if err != nil {
-		return nil, false, err
+	msgData := make([]byte, msgSize)
+	if _, err = io.ReadFull(conn, msgData); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, true, cerr.PacketMsgSmallerThanExpected
+		}
+
+		return nil, true, err
 	}
 
-	return packets, false, nil
+	return []*Packet{newPacket(header[0], msgSize, msgData)}, false, nil
 }
@@ -0,0 +1,189 @@
+package pomeloPacket
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
+	cerr "github.com/cherry-game/cherry/error"
+)
+
+func TestReadWithLimit(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	defer peer.Close()
+
+	go func() {
+		// header only, length = 1mb
+		_, _ = peer.Write([]byte{Data, 0x10, 0x00, 0x00})
+	}()
+
+	_, isBreak, err := ReadWithLimit(conn, 64*1024)
+	if err != cerr.PacketSizeExceed || !isBreak {
+		t.Fatalf("isBreak = %t, err = %v", isBreak, err)
+	}
+}
+
+// streamConn reads a stream of packets, a packet may span multiple reads if chunkSize is less than the packet length
+type streamConn struct {
+	net.Conn
+	reader    *bytes.Reader
+	chunkSize int
+}
+
+func (c *streamConn) Read(b []byte) (int, error) {
+	if c.chunkSize > 0 && len(b) > c.chunkSize {
+		b = b[:c.chunkSize]
+	}
+	return c.reader.Read(b)
+}
+
+func TestReadWithLimitStream(t *testing.T) {
+	var (
+		stream   []byte
+		payloads = [][]byte{[]byte("hello"), nil, []byte("cherry game"), bytes.Repeat([]byte("x"), 1024)}
+	)
+
+	for _, payload := range payloads {
+		data, _ := Encode(Data, payload)
+		stream = append(stream, data...)
+	}
+
+	for _, chunkSize := range []int{0, 1, 3, 7} {
+		conn := &streamConn{reader: bytes.NewReader(stream), chunkSize: chunkSize}
+
+		for _, payload := range payloads {
+			packets, isBreak, err := ReadWithLimit(conn, MaxPacketSize)
+			if err != nil || isBreak {
+				t.Fatalf("chunkSize = %d, isBreak = %t, err = %v", chunkSize, isBreak, err)
+			}
+
+			if len(packets) != 1 || packets[0].Type() != Data || !bytes.Equal(packets[0].Data(), payload) {
+				t.Fatalf("chunkSize = %d, packets = %v, want %s", chunkSize, packets, payload)
+			}
+		}
+
+		if _, isBreak, err := ReadWithLimit(conn, MaxPacketSize); err != cerr.PacketConnectClosed || !isBreak {
+			t.Fatalf("chunkSize = %d, isBreak = %t, err = %v", chunkSize, isBreak, err)
+		}
+	}
+
+	// the connection is closed in the middle of a packet
+	data, _ := Encode(Data, []byte("hello"))
+	conn := &streamConn{reader: bytes.NewReader(data[:len(data)-1])}
+	if _, isBreak, err := ReadWithLimit(conn, MaxPacketSize); err != cerr.PacketMsgSmallerThanExpected || !isBreak {
+		t.Fatalf("isBreak = %t, err = %v", isBreak, err)
+	}
+}
+
+func TestDecodeStream(t *testing.T) {
+	var stream []byte
+	for _, payload := range []string{"hello", "cherry", "game"} {
+		data, _ := Encode(Data, []byte(payload))
+		stream = append(stream, data...)
+	}
+
+	// the stream is split into chunks, the incomplete packet is kept for the next read
+	for _, chunkSize := range []int{1, 3, 5, 10} {
+		var (
+			buf     []byte
+			results []string
+		)
+
+		for i := 0; i < len(stream); i += chunkSize {
+			end := i + chunkSize
+			if end > len(stream) {
+				end = len(stream)
+			}
+			buf = append(buf, stream[i:end]...)
+
+			packets, n, err := DecodeStream(buf)
+			if err != nil {
+				t.Fatalf("chunkSize = %d, err = %v", chunkSize, err)
+			}
+
+			for _, pkg := range packets {
+				results = append(results, string(pkg.Data()))
+			}
+			buf = buf[n:]
+		}
+
+		if len(buf) != 0 || len(results) != 3 || results[0] != "hello" || results[1] != "cherry" || results[2] != "game" {
+			t.Fatalf("chunkSize = %d, results = %v, remain = %d", chunkSize, results, len(buf))
+		}
+	}
+
+	// corrupt framing after the first packet
+	data, _ := Encode(Data, []byte("hello"))
+	corrupt := append(data, 0x7f, 0x00, 0x00, 0x01, 0x00)
+
+	packets, n, err := DecodeStream(corrupt)
+	if err != cerr.PacketWrongType || len(packets) != 1 || n != len(data) {
+		t.Fatalf("packets = %d, n = %d, err = %v", len(packets), n, err)
+	}
+
+	// corrupt framing with an incomplete body, the type is checked before waiting for more bytes
+	if _, n, err = DecodeStream([]byte{None, 0x00, 0x01, 0x00}); err != cerr.PacketWrongType || n != 0 {
+		t.Fatalf("n = %d, err = %v", n, err)
+	}
+}
+
+// BenchmarkReadWithLimit reads a stream of many small packets
+func BenchmarkReadWithLimit(b *testing.B) {
+	const count = 1024
+
+	var stream []byte
+	for i := 0; i < count; i++ {
+		data, _ := Encode(Data, []byte("hello world"))
+		stream = append(stream, data...)
+	}
+
+	var (
+		reader = bytes.NewReader(stream)
+		conn   = &streamConn{reader: reader}
+	)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(stream) / count))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if i%count == 0 {
+			_, _ = reader.Seek(0, io.SeekStart)
+		}
+
+		if _, _, err := ReadWithLimit(conn, MaxPacketSize); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkDecode(b *testing.B, pool bool) {
+	SetPool(pool)
+	defer SetPool(false)
+
+	data, _ := Encode(Data, []byte("hello world"))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		packets, err := Decode(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for _, pkg := range packets {
+			Release(pkg)
+		}
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	benchmarkDecode(b, false)
+}
+
+func BenchmarkDecodePool(b *testing.B) {
+	benchmarkDecode(b, true)
+}
@@ -13,7 +13,7 @@ func DefaultDataRoute(agent *Agent, route *pmessage.Route, msg *pmessage.Message
 
 	// current node
 	if agent.NodeType() == route.NodeType() {
-		targetPath := cfacade.NewChildPath(agent.NodeId(), route.HandleName(), session.Sid)
+		targetPath := cfacade.NewChildPath(agent.NodeId(), route.HandleName(), cmd.childIDFunc(session))
 		LocalDataRoute(agent, session, route, msg, targetPath)
 		return
 	}
@@ -29,6 +29,7 @@ func DefaultDataRoute(agent *Agent, route *pmessage.Route, msg *pmessage.Message
 
 	member, found := agent.Discovery().Random(route.NodeType())
 	if !found {
+		RouteNotFound(agent, msg)
 		return
 	}
 
@@ -44,6 +45,11 @@ func DefaultDataRoute(agent *Agent, route *pmessage.Route, msg *pmessage.Message
 	}
 }
 
+// DefaultChildID 默认按sid路由到子actor,同一个session的消息按发送顺序处理
+func DefaultChildID(session *cproto.Session) string {
+	return session.Sid
+}
+
 func LocalDataRoute(agent *Agent, session *cproto.Session, route *pmessage.Route, msg *pmessage.Message, targetPath string) {
 	message := cfacade.GetMessage()
 	message.Source = session.AgentPath
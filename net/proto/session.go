@@ -1,10 +1,24 @@
 package cherryProto
 
 import (
+	"sync"
+	"unsafe"
+
 	cconst "github.com/cherry-game/cherry/const"
 	cstring "github.com/cherry-game/cherry/extend/string"
 )
 
+// Session为protobuf生成的结构,无法添加mutex字段,
+// Data的读写方法按session指针使用分段锁保护,可在多个goroutine中调用;
+// 直接读写x.Data或序列化session(proto.Marshal)时不加锁,需在持有session的goroutine中执行
+const sessionLockCount = 64
+
+var sessionLocks [sessionLockCount]sync.RWMutex
+
+func (x *Session) dataLock() *sync.RWMutex {
+	return &sessionLocks[(uintptr(unsafe.Pointer(x))>>4)%sessionLockCount]
+}
+
 func (x *Session) IsBind() bool {
 	return x.Uid > 0
 }
@@ -14,10 +28,18 @@ func (x *Session) ActorPath() string {
 }
 
 func (x *Session) Add(key string, value interface{}) {
-	x.Data[key] = cstring.ToString(value)
+	lock := x.dataLock()
+	lock.Lock()
+	defer lock.Unlock()
+
+	x.set(key, cstring.ToString(value))
 }
 
 func (x *Session) Remove(key string) {
+	lock := x.dataLock()
+	lock.Lock()
+	defer lock.Unlock()
+
 	delete(x.Data, key)
 }
 
@@ -26,9 +48,45 @@ func (x *Session) Set(key string, value string) {
 		return
 	}
 
+	lock := x.dataLock()
+	lock.Lock()
+	defer lock.Unlock()
+
+	x.set(key, value)
+}
+
+// set lazily init the data map, must be locked
+func (x *Session) set(key string, value string) {
+	if x.Data == nil {
+		x.Data = map[string]string{}
+	}
+
 	x.Data[key] = value
 }
 
+// Get returns the value associated with the key
+func (x *Session) Get(key string) (string, bool) {
+	lock := x.dataLock()
+	lock.RLock()
+	defer lock.RUnlock()
+
+	v, found := x.Data[key]
+	return v, found
+}
+
+// Keys returns all keys of the session data
+func (x *Session) Keys() []string {
+	lock := x.dataLock()
+	lock.RLock()
+	defer lock.RUnlock()
+
+	keys := make([]string, 0, len(x.Data))
+	for k := range x.Data {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
 func (x *Session) ImportAll(data map[string]string) {
 	for k, v := range data {
 		x.Set(k, v)
@@ -36,27 +94,41 @@ func (x *Session) ImportAll(data map[string]string) {
 }
 
 func (x *Session) Contains(key string) bool {
-	_, found := x.Data[key]
+	_, found := x.Get(key)
 	return found
 }
 
 func (x *Session) Restore(data map[string]string) {
-	x.Clear()
+	lock := x.dataLock()
+	lock.Lock()
+	defer lock.Unlock()
+
+	x.clear()
 
 	for k, v := range data {
-		x.Set(k, v)
+		if k != "" && v != "" {
+			x.set(k, v)
+		}
 	}
 }
 
 // Clear releases all settings related to current sc
 func (x *Session) Clear() {
+	lock := x.dataLock()
+	lock.Lock()
+	defer lock.Unlock()
+
+	x.clear()
+}
+
+func (x *Session) clear() {
 	for k := range x.Data {
 		delete(x.Data, k)
 	}
 }
 
 func (x *Session) GetUint(key string) uint {
-	v, ok := x.Data[key]
+	v, ok := x.Get(key)
 	if !ok {
 		return 0
 	}
@@ -69,7 +141,7 @@ func (x *Session) GetUint(key string) uint {
 }
 
 func (x *Session) GetInt(key string) int {
-	v, ok := x.Data[key]
+	v, ok := x.Get(key)
 	if !ok {
 		return 0
 	}
@@ -83,7 +155,7 @@ func (x *Session) GetInt(key string) int {
 
 // GetInt32 returns the value associated with the key as a int32.
 func (x *Session) GetInt32(key string) int32 {
-	v, ok := x.Data[key]
+	v, ok := x.Get(key)
 	if !ok {
 		return 0
 	}
@@ -97,7 +169,7 @@ func (x *Session) GetInt32(key string) int32 {
 
 // GetInt64 returns the value associated with the key as a int64.
 func (x *Session) GetInt64(key string) int64 {
-	v, ok := x.Data[key]
+	v, ok := x.Get(key)
 	if !ok {
 		return 0
 	}
@@ -111,10 +183,6 @@ func (x *Session) GetInt64(key string) int64 {
 
 // GetString returns the value associated with the key as a string.
 func (x *Session) GetString(key string) string {
-	v, ok := x.Data[key]
-	if !ok {
-		return ""
-	}
-
+	v, _ := x.Get(key)
 	return v
 }
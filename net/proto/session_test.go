@@ -0,0 +1,66 @@
+package cherryProto
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestSessionData(t *testing.T) {
+	session := &Session{}
+
+	// the data map is lazily initialized
+	if _, found := session.Get("roomId"); found || len(session.Keys()) != 0 {
+		t.Fatal("empty session has data")
+	}
+
+	session.Set("roomId", "1001")
+	session.Add("level", 10)
+	session.Set("empty", "")
+
+	keys := session.Keys()
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "level" || keys[1] != "roomId" {
+		t.Fatalf("keys = %v", keys)
+	}
+
+	if session.GetInt("level") != 10 || session.GetString("roomId") != "1001" || !session.Contains("roomId") {
+		t.Fatalf("data = %v", session.Data)
+	}
+
+	session.Remove("roomId")
+	if session.Contains("roomId") {
+		t.Fatalf("data = %v", session.Data)
+	}
+
+	session.Restore(map[string]string{"guild": "7"})
+	if keys = session.Keys(); len(keys) != 1 || session.GetInt64("guild") != 7 {
+		t.Fatalf("data = %v", session.Data)
+	}
+}
+
+func TestSessionDataConcurrent(t *testing.T) {
+	session := &Session{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			key := fmt.Sprintf("key%d", i)
+			for n := 0; n < 100; n++ {
+				session.Add(key, n)
+				session.Get(key)
+				session.Keys()
+				session.GetInt(key)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(session.Keys()) != 8 || session.GetInt("key0") != 99 {
+		t.Fatalf("data = %v", session.Data)
+	}
+}
@@ -1,8 +1,10 @@
 package cherryProfile
 
 import (
+	"strconv"
 	"time"
 
+	cstring "github.com/cherry-game/cherry/extend/string"
 	cfacade "github.com/cherry-game/cherry/facade"
 	jsoniter "github.com/json-iterator/go"
 )
@@ -10,6 +12,7 @@ import (
 type (
 	Config struct {
 		jsoniter.Any
+		path []interface{} // 当前节点在profile中的路径,用于查找环境变量
 	}
 )
 
@@ -20,12 +23,30 @@ func Wrap(val interface{}) *Config {
 }
 
 func (p *Config) GetConfig(path ...interface{}) cfacade.ProfileJSON {
+	fullPath := make([]interface{}, 0, len(p.path)+len(path))
+	fullPath = append(fullPath, p.path...)
+	fullPath = append(fullPath, path...)
+
 	return &Config{
-		Any: p.Any.Get(path...),
+		Any:  p.Any.Get(path...),
+		path: fullPath,
 	}
 }
 
+// env 查找path对应的环境变量
+func (p *Config) env(path interface{}) (string, bool) {
+	fullPath := make([]interface{}, 0, len(p.path)+1)
+	fullPath = append(fullPath, p.path...)
+	fullPath = append(fullPath, path)
+
+	return lookupEnv(fullPath...)
+}
+
 func (p *Config) GetString(path interface{}, defaultVal ...string) string {
+	if v, found := p.env(path); found {
+		return v
+	}
+
 	result := p.Get(path)
 	if result.LastError() != nil {
 		if len(defaultVal) > 0 {
@@ -37,6 +58,12 @@ func (p *Config) GetString(path interface{}, defaultVal ...string) string {
 }
 
 func (p *Config) GetBool(path interface{}, defaultVal ...bool) bool {
+	if v, found := p.env(path); found {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+
 	result := p.Get(path)
 	if result.LastError() != nil {
 		if len(defaultVal) > 0 {
@@ -50,6 +77,12 @@ func (p *Config) GetBool(path interface{}, defaultVal ...bool) bool {
 }
 
 func (p *Config) GetInt(path interface{}, defaultVal ...int) int {
+	if v, found := p.env(path); found {
+		if n, ok := cstring.ToInt(v); ok {
+			return n
+		}
+	}
+
 	result := p.Get(path)
 	if result.LastError() != nil {
 		if len(defaultVal) > 0 {
@@ -62,6 +95,12 @@ func (p *Config) GetInt(path interface{}, defaultVal ...int) int {
 }
 
 func (p *Config) GetInt32(path interface{}, defaultVal ...int32) int32 {
+	if v, found := p.env(path); found {
+		if n, ok := cstring.ToInt32(v); ok {
+			return n
+		}
+	}
+
 	result := p.Get(path)
 	if result.LastError() != nil {
 		if len(defaultVal) > 0 {
@@ -74,6 +113,12 @@ func (p *Config) GetInt32(path interface{}, defaultVal ...int32) int32 {
 }
 
 func (p *Config) GetInt64(path interface{}, defaultVal ...int64) int64 {
+	if v, found := p.env(path); found {
+		if n, ok := cstring.ToInt64(v); ok {
+			return n
+		}
+	}
+
 	result := p.Get(path)
 	if result.LastError() != nil {
 		if len(defaultVal) > 0 {
@@ -86,6 +131,12 @@ func (p *Config) GetInt64(path interface{}, defaultVal ...int64) int64 {
 }
 
 func (p *Config) GetDuration(path interface{}, defaultVal ...time.Duration) time.Duration {
+	if v, found := p.env(path); found {
+		if n, ok := cstring.ToInt64(v); ok {
+			return time.Duration(n)
+		}
+	}
+
 	result := p.Get(path)
 	if result.LastError() != nil {
 		if len(defaultVal) > 0 {
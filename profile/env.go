@@ -0,0 +1,38 @@
+package cherryProfile
+
+import (
+	"os"
+	"strings"
+
+	cstring "github.com/cherry-game/cherry/extend/string"
+)
+
+const (
+	EnvPrefix = "CHERRY" // 环境变量前缀
+)
+
+// EnvName 返回配置路径对应的环境变量名称
+// 规则: CHERRY_ + 路径各节点以"_"连接并转为大写,非字母数字字符替换为"_"
+// 例如: GetConfig("data_config").GetString("data_source") => CHERRY_DATA_CONFIG_DATA_SOURCE
+func EnvName(path ...interface{}) string {
+	var sb strings.Builder
+	sb.WriteString(EnvPrefix)
+
+	for _, p := range path {
+		sb.WriteByte('_')
+		for _, r := range strings.ToUpper(cstring.ToString(p)) {
+			if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+				sb.WriteRune(r)
+			} else {
+				sb.WriteByte('_')
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// lookupEnv 查找配置路径对应的环境变量,环境变量优先于配置文件
+func lookupEnv(path ...interface{}) (string, bool) {
+	return os.LookupEnv(EnvName(path...))
+}
@@ -85,7 +85,7 @@ func GetNodeWithConfig(config *Config, nodeId string) (cfacade.INode, error) {
 }
 
 func LoadNode(nodeId string) (cfacade.INode, error) {
-	return GetNodeWithConfig(cfg.jsonConfig, nodeId)
+	return GetNodeWithConfig(getJsonConfig(), nodeId)
 }
 
 func findNodeId(nodeId string, nodeIdJson cfacade.ProfileJSON) bool {
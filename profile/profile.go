@@ -2,6 +2,7 @@ package cherryProfile
 
 import (
 	"path/filepath"
+	"sync"
 
 	cerror "github.com/cherry-game/cherry/error"
 	cfile "github.com/cherry-game/cherry/extend/file"
@@ -12,6 +13,7 @@ import (
 
 var (
 	cfg = &struct {
+		sync.RWMutex
 		profilePath string  // profile root dir
 		profileName string  // profile name
 		jsonConfig  *Config // profile-x.json parse to json object
@@ -22,22 +24,37 @@ var (
 )
 
 func Path() string {
+	cfg.RLock()
+	defer cfg.RUnlock()
+
 	return cfg.profilePath
 }
 
 func Name() string {
+	cfg.RLock()
+	defer cfg.RUnlock()
+
 	return cfg.profileName
 }
 
 func Env() string {
+	cfg.RLock()
+	defer cfg.RUnlock()
+
 	return cfg.env
 }
 
 func Debug() bool {
+	cfg.RLock()
+	defer cfg.RUnlock()
+
 	return cfg.debug
 }
 
 func PrintLevel() string {
+	cfg.RLock()
+	defer cfg.RUnlock()
+
 	return cfg.printLevel
 }
 
@@ -66,43 +83,100 @@ func Init(filePath, nodeId string) (cfacade.INode, error) {
 		return nil, cerror.Errorf("Failed to get node config from profile file. [err = %v]", err)
 	}
 
-	// init cfg
+	// init cfg, the watcher reads the path concurrently
+	cfg.Lock()
 	cfg.profilePath = p
 	cfg.profileName = f
-	cfg.jsonConfig = jsonConfig
-	cfg.env = jsonConfig.GetString("env", "default")
-	cfg.debug = jsonConfig.GetBool("debug", true)
-	cfg.printLevel = jsonConfig.GetString("print_level", "debug")
+	cfg.Unlock()
+	setJsonConfig(jsonConfig)
 
 	return node, nil
 }
 
 func GetConfig(path ...interface{}) cfacade.ProfileJSON {
-	return cfg.jsonConfig.GetConfig(path...)
+	return getJsonConfig().GetConfig(path...)
+}
+
+func getJsonConfig() *Config {
+	cfg.RLock()
+	defer cfg.RUnlock()
+
+	return cfg.jsonConfig
+}
+
+func setJsonConfig(jsonConfig *Config) {
+	cfg.Lock()
+	defer cfg.Unlock()
+
+	cfg.jsonConfig = jsonConfig
+	cfg.env = jsonConfig.GetString("env", "default")
+	cfg.debug = jsonConfig.GetBool("debug", true)
+	cfg.printLevel = jsonConfig.GetString("print_level", "debug")
 }
 
+// loadFile 加载profile文件
+// 先读取当前文件,再按顺序合并include的文件,后面的文件覆盖前面的文件
+// 即include的文件覆盖当前文件(与之前的优先级一致)
+// 合并规则: 对象递归合并,数组及其他类型直接替换
+// 多个文件include同一个文件(如a include [b, c],b、c都include common)时该文件会被合并多次,只有循环include返回错误
 func loadFile(filePath, fileName string) (*Config, error) {
-	// merge include json file
-	var maps = make(map[string]interface{})
+	maps, err := readMaps(filePath, fileName, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+
+	return Wrap(maps), nil
+}
 
-	// read master json file
+func readMaps(filePath, fileName string, visited map[string]bool) (map[string]interface{}, error) {
 	fileNamePath := filepath.Join(filePath, fileName)
+	if visited[fileNamePath] {
+		return nil, cerror.Errorf("Include file cycle. [path = %s]", fileNamePath)
+	}
+
+	// visited只记录当前include链上的文件,返回后移除,兄弟include可以包含相同的文件
+	visited[fileNamePath] = true
+	defer delete(visited, fileNamePath)
+
+	var maps = make(map[string]interface{})
 	if err := cjson.ReadMaps(fileNamePath, maps); err != nil {
 		return nil, err
 	}
 
+	var merged = make(map[string]interface{})
+	mergeMaps(merged, maps)
+
 	// read include json file
 	if v, found := maps["include"].([]interface{}); found {
-		paths := cstring.ToStringSlice(v)
-		for _, p := range paths {
-			includePath := filepath.Join(filePath, p)
-			if err := cjson.ReadMaps(includePath, maps); err != nil {
+		for _, p := range cstring.ToStringSlice(v) {
+			includeMaps, err := readMaps(filePath, p, visited)
+			if err != nil {
 				return nil, err
 			}
+			mergeMaps(merged, includeMaps)
 		}
 	}
 
-	return Wrap(maps), nil
+	return merged, nil
+}
+
+// mergeMaps 将src合并到dst,对象递归合并,其他类型直接替换
+func mergeMaps(dst, src map[string]interface{}) {
+	for k, v := range src {
+		srcMap, ok := v.(map[string]interface{})
+		if !ok {
+			dst[k] = v
+			continue
+		}
+
+		dstMap, ok := dst[k].(map[string]interface{})
+		if !ok {
+			dstMap = make(map[string]interface{})
+			dst[k] = dstMap
+		}
+
+		mergeMaps(dstMap, srcMap)
+	}
 }
 
 //func judgeNameList(path, name string) ([]string, error) {
@@ -2,7 +2,11 @@ package cherryProfile
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestLoadFile(t *testing.T) {
@@ -10,3 +14,193 @@ func TestLoadFile(t *testing.T) {
 	node, err := Init(path, "game-1")
 	fmt.Println(node, err)
 }
+
+func TestEnvOverride(t *testing.T) {
+	config := Wrap(map[string]interface{}{
+		"data_config": map[string]interface{}{
+			"data_source": "file",
+			"file": map[string]interface{}{
+				"reload_time": 3000,
+			},
+		},
+	})
+
+	dataConfig := config.GetConfig("data_config")
+	if v := dataConfig.GetString("data_source"); v != "file" {
+		t.Fatalf("data_source = %s, want file", v)
+	}
+
+	t.Setenv("CHERRY_DATA_CONFIG_DATA_SOURCE", "redis")
+	t.Setenv("CHERRY_DATA_CONFIG_FILE_RELOAD_TIME", "5000")
+
+	if v := dataConfig.GetString("data_source"); v != "redis" {
+		t.Fatalf("data_source = %s, want redis", v)
+	}
+
+	if v := config.GetConfig("data_config", "file").GetInt("reload_time"); v != 5000 {
+		t.Fatalf("reload_time = %d, want 5000", v)
+	}
+
+	if v := dataConfig.GetConfig("file").GetInt64("reload_time"); v != 5000 {
+		t.Fatalf("reload_time = %d, want 5000", v)
+	}
+
+	if name := EnvName("data_config", "file", "reload-time"); name != "CHERRY_DATA_CONFIG_FILE_RELOAD_TIME" {
+		t.Fatalf("env name = %s", name)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "profile-test.json")
+
+	writeProfile := func(env string) {
+		text := `{"env":"` + env + `","node":{"game":[{"node_id":"game-1","enabled":true}]}}`
+		if err := os.WriteFile(filePath, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeProfile("dev")
+	if _, err := Init(filePath, "game-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	var count int32
+	OnChange(func() {
+		atomic.AddInt32(&count, 1)
+	})
+
+	Watch(50 * time.Millisecond)
+	defer StopWatch()
+
+	// 连续多次写入只触发一次回调
+	for i := 0; i < 5; i++ {
+		writeProfile(fmt.Sprintf("test%d", i))
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	if c := atomic.LoadInt32(&count); c != 1 {
+		t.Fatalf("onChange count = %d, want 1", c)
+	}
+
+	if env := GetConfig("env").ToString(); env != "test4" {
+		t.Fatalf("env = %s, want test4", env)
+	}
+}
+
+func TestWatchNestedInclude(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFile := func(name, text string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile("profile-nested.json", `{"include":["a.json"],"node":{"game":[{"node_id":"game-1","enabled":true}]}}`)
+	writeFile("a.json", `{"include":["b.json"]}`)
+	writeFile("b.json", `{"env":"dev"}`)
+
+	if _, err := Init(filepath.Join(dir, "profile-nested.json"), "game-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	watch.Lock()
+	onChangeFns := watch.onChangeFns
+	watch.onChangeFns = nil
+	watch.Unlock()
+
+	defer func() {
+		watch.Lock()
+		watch.onChangeFns = onChangeFns
+		watch.Unlock()
+	}()
+
+	// the panic of a callback does not break the other callbacks
+	var count int32
+	OnChange(func() {
+		panic("callback error")
+	})
+	OnChange(func() {
+		atomic.AddInt32(&count, 1)
+	})
+
+	Watch(50 * time.Millisecond)
+	defer StopWatch()
+
+	// b.json is only included by a.json
+	writeFile("b.json", `{"env":"prod"}`)
+	time.Sleep(300 * time.Millisecond)
+
+	if c := atomic.LoadInt32(&count); c != 1 {
+		t.Fatalf("onChange count = %d, want 1", c)
+	}
+
+	if env := GetConfig("env").ToString(); env != "prod" {
+		t.Fatalf("env = %s, want prod", env)
+	}
+
+	// the watcher is still running after the panic
+	writeFile("b.json", `{"env":"test"}`)
+	time.Sleep(300 * time.Millisecond)
+
+	if env := GetConfig("env").ToString(); env != "test" {
+		t.Fatalf("env = %s, want test", env)
+	}
+}
+
+func TestLoadFileMerge(t *testing.T) {
+	dir := t.TempDir()
+
+	master := `{"include":["profile-base.json","overlay.json"],"debug":false,"node":"game-1"}`
+	base := `{"debug":true,"logger":{"level":"debug","console":true},"cluster":{"urls":["a","b"]}}`
+	overlay := `{"logger":{"level":"info"},"cluster":{"urls":["c"]}}`
+
+	_ = os.WriteFile(filepath.Join(dir, "profile-prod.json"), []byte(master), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "profile-base.json"), []byte(base), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "overlay.json"), []byte(overlay), 0644)
+
+	config, err := loadFile(dir, "profile-prod.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// include files override the master file
+	if !config.GetBool("debug") || config.GetString("node") != "game-1" {
+		t.Fatalf("include should override master. config = %s", config.ToString())
+	}
+
+	logger := config.GetConfig("logger")
+	if logger.GetString("level") != "info" || !logger.GetBool("console") {
+		t.Fatalf("logger merge fail. logger = %s", logger.ToString())
+	}
+
+	if urls := config.GetConfig("cluster").GetConfig("urls"); urls.Size() != 1 || urls.GetString(0) != "c" {
+		t.Fatalf("array should be replaced. urls = %s", urls.ToString())
+	}
+
+	// diamond: a includes [b, c], both b and c include common
+	_ = os.WriteFile(filepath.Join(dir, "common.json"), []byte(`{"db":{"host":"localhost","port":3306}}`), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "b.json"), []byte(`{"include":["common.json"],"db":{"user":"b"}}`), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "c.json"), []byte(`{"include":["common.json"],"db":{"port":3307},"cache":true}`), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "a.json"), []byte(`{"include":["b.json","c.json"]}`), 0644)
+
+	config, err = loadFile(dir, "a.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// common overrides the port of c, c is merged after b
+	db := config.GetConfig("db")
+	if db.GetString("host") != "localhost" || db.GetInt("port") != 3306 || db.GetString("user") != "b" || !config.GetBool("cache") {
+		t.Fatalf("diamond include merge fail. config = %s", config.ToString())
+	}
+
+	_ = os.WriteFile(filepath.Join(dir, "overlay.json"), []byte(`{"include":["profile-prod.json"]}`), 0644)
+	if _, err = loadFile(dir, "profile-prod.json"); err == nil {
+		t.Fatal("include cycle should return error")
+	}
+}
@@ -0,0 +1,174 @@
+package cherryProfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	cjson "github.com/cherry-game/cherry/extend/json"
+	cstring "github.com/cherry-game/cherry/extend/string"
+	cutils "github.com/cherry-game/cherry/extend/utils"
+)
+
+var (
+	watch = &struct {
+		sync.Mutex
+		onChangeFns []func()      // profile文件变化后的回调函数
+		die         chan struct{} // 停止监听
+	}{}
+)
+
+// FileName 返回当前profile文件的完整路径
+func FileName() string {
+	return filepath.Join(Path(), Name())
+}
+
+// OnChange 注册profile文件变化的回调函数,重新加载成功后触发
+func OnChange(fn func()) {
+	if fn == nil {
+		return
+	}
+
+	watch.Lock()
+	defer watch.Unlock()
+
+	watch.onChangeFns = append(watch.onChangeFns, fn)
+}
+
+// Watch 按interval间隔扫描profile文件(包括include文件)
+// 文件修改后需要在连续两次扫描中保持不变才会重新加载,用于合并编辑器的多次连续写入
+func Watch(interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	watch.Lock()
+	defer watch.Unlock()
+
+	if watch.die != nil {
+		return
+	}
+
+	// 在返回前记录文件签名,Watch之后的修改都会被检测到
+	watch.die = make(chan struct{})
+	go watchFile(interval, watch.die, fileSign())
+}
+
+// StopWatch 停止监听profile文件
+func StopWatch() {
+	watch.Lock()
+	defer watch.Unlock()
+
+	if watch.die != nil {
+		close(watch.die)
+		watch.die = nil
+	}
+}
+
+func watchFile(interval time.Duration, die chan struct{}, last string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	pending := ""
+
+	for {
+		select {
+		case <-die:
+			return
+		case <-ticker.C:
+			{
+				sign := fileSign()
+				if sign == last {
+					pending = ""
+					continue
+				}
+
+				// 文件仍在变化,等待下一次扫描
+				if sign != pending {
+					pending = sign
+					continue
+				}
+
+				if err := reload(); err != nil {
+					fmt.Fprintf(os.Stderr, "Reload profile file error. [file = %s, err = %v]\n", FileName(), err)
+				} else {
+					onChange()
+				}
+
+				last = sign
+				pending = ""
+			}
+		}
+	}
+}
+
+// fileSign 返回profile文件及所有include文件(含嵌套include)的修改时间和大小
+func fileSign() string {
+	var files []string
+	profilePath := Path()
+	includeFiles(profilePath, Name(), map[string]bool{}, &files)
+
+	sign := ""
+	for _, f := range files {
+		stat, err := os.Stat(filepath.Join(profilePath, f))
+		if err != nil {
+			sign += f + ":-;"
+			continue
+		}
+		sign += fmt.Sprintf("%s:%d:%d;", f, stat.ModTime().UnixNano(), stat.Size())
+	}
+
+	return sign
+}
+
+// includeFiles 按深度优先顺序收集文件及其include的文件,每个文件只收集一次
+// 读取文件时直接解析include,不依赖合并后的配置(合并后的include为最后合并的文件的值)
+func includeFiles(filePath, fileName string, visited map[string]bool, files *[]string) {
+	if visited[fileName] {
+		return
+	}
+
+	visited[fileName] = true
+	*files = append(*files, fileName)
+
+	var maps = make(map[string]interface{})
+	if err := cjson.ReadMaps(filepath.Join(filePath, fileName), maps); err != nil {
+		return
+	}
+
+	if v, ok := maps["include"].([]interface{}); ok {
+		for _, f := range cstring.ToStringSlice(v) {
+			includeFiles(filePath, f, visited, files)
+		}
+	}
+}
+
+func reload() error {
+	jsonConfig, err := loadFile(Path(), Name())
+	if err != nil {
+		return err
+	}
+
+	if jsonConfig.Any == nil || jsonConfig.LastError() != nil {
+		return jsonConfig.LastError()
+	}
+
+	setJsonConfig(jsonConfig)
+	return nil
+}
+
+func onChange() {
+	watch.Lock()
+	fns := make([]func(), len(watch.onChangeFns))
+	copy(fns, watch.onChangeFns)
+	watch.Unlock()
+
+	// 回调panic时不影响其他回调及watchFile的运行
+	for _, fn := range fns {
+		cutils.Try(fn, func(errString string) {
+			fmt.Fprintf(os.Stderr, "Profile change callback error. [file = %s, err = %s]\n", FileName(), errString)
+		})
+	}
+}